@@ -0,0 +1,68 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DatabaseColumn is the column added by QueryAcrossDatabases, holding the
+// database of each row.
+const DatabaseColumn = "db"
+
+// QueryAcrossDatabases runs the sql in each database concurrently, like
+// FetchDashboard, and merges the rows in the order of dbs, the database of a row
+// in a first DatabaseColumn. In sqlTemplate {db} stands for the quoted database
+// name, unqualified tables are those of the database anyway:
+//
+//	rs, err := c.QueryAcrossDatabases(ctx, tenants, "select count(*) from {db}.meters")
+//
+// The databases must return the same columns. The error joins the errors of the
+// failed databases, the rows of the others are returned nevertheless.
+func (client *tsdbClient) QueryAcrossDatabases(ctx context.Context, dbs []string, sqlTemplate string) (ResultSet, error) {
+	if len(dbs) == 0 || len(strings.TrimSpace(sqlTemplate)) == 0 {
+		return ResultSet{}, errors.New("miss args: `dbs` or `sqlTemplate`")
+	}
+	queries := make(map[string]Query, len(dbs))
+	for _, db := range dbs {
+		name, err := QuoteIdent(db)
+		if err != nil {
+			return ResultSet{}, fmt.Errorf("invalid args: database %v", err)
+		}
+		queries[db] = NewQuery(strings.ReplaceAll(sqlTemplate, "{db}", name), db, client.dbConfig.Precision)
+	}
+
+	results, err := client.FetchDashboard(ctx, queries)
+	if results == nil {
+		return ResultSet{}, err
+	}
+	errs := []error{err}
+
+	var merged ResultSet
+	for i, db := range dbs {
+		if slices.Contains(dbs[:i], db) {
+			continue
+		}
+		rs := results[db]
+		if rs.Err != nil {
+			continue
+		}
+		if merged.Columns == nil {
+			merged.Columns = append([]string{DatabaseColumn}, rs.Columns...)
+		} else if !slices.Equal(merged.Columns[1:], rs.Columns) {
+			errs = append(errs, fmt.Errorf("%s: columns %v differ from %v", db, rs.Columns, merged.Columns[1:]))
+			continue
+		}
+		for _, row := range rs.Rows {
+			merged.Rows = append(merged.Rows, append([]interface{}{db}, row...))
+		}
+	}
+	return merged, errors.Join(errs...)
+}
+
+// QueryAcrossDatabases runs the sql in each database with the default client.
+func QueryAcrossDatabases(ctx context.Context, dbs []string, sqlTemplate string) (ResultSet, error) {
+	return clientWrapper.QueryAcrossDatabases(ctx, dbs, sqlTemplate)
+}
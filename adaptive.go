@@ -0,0 +1,129 @@
+package tsdbclient
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveWindow is the number of flushes measured before tuning the batch
+	// size again.
+	adaptiveWindow = 20
+
+	// minAdaptiveFlushInterval bounds the flush interval of small batches.
+	minAdaptiveFlushInterval = 10 * time.Millisecond
+)
+
+// BatchingStats is the operating point of an asynchronous writer.
+type BatchingStats struct {
+	// BatchSize and FlushInterval are the current ones, tuned in the adaptive mode.
+	BatchSize     int
+	FlushInterval time.Duration
+
+	// LatencyTarget is the p99 target of the adaptive mode, 0 if the writer is not adaptive.
+	LatencyTarget time.Duration
+
+	// LatencyP99 is the p99 latency of the last flushes measured, 0 before the first
+	// measure of the adaptive mode.
+	LatencyP99 time.Duration
+
+	Flushes uint64
+}
+
+// adaptiveBatching tunes the batch size and the flush interval of an asynchronous
+// writer to keep the p99 latency of its flushes under a target, batching as much
+// as the target allows. Batches start at the min size and grow up to the max
+// while the latency allows it, they are halved when it exceeds the target. The
+// flush interval follows the batch size, the max interval at the max size.
+// Without target the batches stay at the max size and interval.
+type adaptiveBatching struct {
+	target      time.Duration
+	minSize     int
+	maxSize     int
+	maxInterval time.Duration
+
+	lock      sync.Mutex
+	size      int
+	interval  time.Duration
+	p99       time.Duration
+	flushes   uint64
+	latencies []time.Duration
+}
+
+// newAdaptiveBatching returns the batching of batches up to maxSize flushed every
+// maxInterval, tuned to target if positive. minSize defaults to maxSize / 50.
+func newAdaptiveBatching(maxSize int, maxInterval, target time.Duration, minSize int) *adaptiveBatching {
+	if minSize <= 0 {
+		minSize = max(maxSize/50, 1)
+	}
+	b := &adaptiveBatching{
+		target:      max(target, 0),
+		minSize:     min(minSize, maxSize),
+		maxSize:     maxSize,
+		maxInterval: maxInterval,
+		size:        maxSize,
+		interval:    maxInterval,
+	}
+	if b.target > 0 {
+		b.size = b.minSize
+		b.interval = b.intervalOf(b.size)
+	}
+	return b
+}
+
+// operatingPoint returns the current batch size and flush interval.
+func (b *adaptiveBatching) operatingPoint() (int, time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.size, b.interval
+}
+
+// observe records the latency of a flush and returns the batch size and flush
+// interval to use next. In the adaptive mode, every adaptiveWindow flushes the
+// batch is halved when the p99 latency exceeds the target, or grown by a quarter
+// when it stays under 3/4 of it.
+func (b *adaptiveBatching) observe(d time.Duration) (int, time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.flushes++
+
+	if b.target <= 0 {
+		return b.size, b.interval
+	}
+	b.latencies = append(b.latencies, d)
+	if len(b.latencies) < adaptiveWindow {
+		return b.size, b.interval
+	}
+
+	slices.Sort(b.latencies)
+	b.p99 = b.latencies[(len(b.latencies)*99+99)/100-1]
+	b.latencies = b.latencies[:0]
+	switch {
+	case b.p99 > b.target:
+		b.size = max(b.size/2, b.minSize)
+	case b.p99 < b.target*3/4:
+		b.size = min(b.size+max(b.size/4, 1), b.maxSize)
+	}
+	b.interval = b.intervalOf(b.size)
+	return b.size, b.interval
+}
+
+// intervalOf returns the flush interval of the batch size, in proportion to the
+// max interval at the max size.
+func (b *adaptiveBatching) intervalOf(size int) time.Duration {
+	d := time.Duration(int64(b.maxInterval) * int64(size) / int64(b.maxSize))
+	return max(d, min(minAdaptiveFlushInterval, b.maxInterval))
+}
+
+func (b *adaptiveBatching) stats() BatchingStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return BatchingStats{
+		BatchSize:     b.size,
+		FlushInterval: b.interval,
+		LatencyTarget: b.target,
+		LatencyP99:    b.p99,
+		Flushes:       b.flushes,
+	}
+}
@@ -0,0 +1,160 @@
+package tsdbclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// NaNPolicy decides how the aggregation helpers of ResultSet handle NaN values.
+type NaNPolicy int8
+
+const (
+	_ NaNPolicy = iota
+	// NaNSkip ignores NaN values like NULLs.
+	NaNSkip
+	// NaNPropagate makes the aggregate NaN when any value is NaN.
+	NaNPropagate
+)
+
+// Aggregate is the result of an aggregation helper of ResultSet.
+type Aggregate struct {
+	// Value is the aggregate, 0 if Valid is false.
+	Value float64
+
+	// Valid is false when no value was aggregated, e.g. all of them were NULL.
+	Valid bool
+
+	// Count is the number of values aggregated, Nulls and NaNs those skipped or seen.
+	Count int
+	Nulls int
+	NaNs  int
+}
+
+// columnIndex returns the index of the column, failing for unknown columns.
+func (r ResultSet) columnIndex(column string) (int, error) {
+	for i, c := range r.Columns {
+		if c == column {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("invalid args: unknown column %s", column)
+}
+
+// numericValue converts a numeric value of a result, ok is false for NULL.
+func numericValue(v interface{}) (f float64, ok bool, err error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, false, nil
+	case json.Number:
+		f, err = v.Float64()
+		if err != nil {
+			return 0, false, fmt.Errorf("not a number: %s", v)
+		}
+		return f, true, nil
+	case float64:
+		return v, true, nil
+	case float32:
+		return float64(v), true, nil
+	case int:
+		return float64(v), true, nil
+	case int64:
+		return float64(v), true, nil
+	case uint64:
+		return float64(v), true, nil
+	default:
+		return 0, false, fmt.Errorf("not a number: %v of type %T", v, v)
+	}
+}
+
+// aggregate folds the non-NULL values of the column with fn.
+func (r ResultSet) aggregate(column string, nan NaNPolicy, fn func(acc, v float64, first bool) float64) (Aggregate, error) {
+	if nan != NaNSkip && nan != NaNPropagate {
+		return Aggregate{}, fmt.Errorf("invalid args: unknown NaN policy %d", nan)
+	}
+	i, err := r.columnIndex(column)
+	if err != nil {
+		return Aggregate{}, err
+	}
+
+	var agg Aggregate
+	for _, row := range r.Rows {
+		if i >= len(row) {
+			agg.Nulls++
+			continue
+		}
+		v, ok, err := numericValue(row[i])
+		if err != nil {
+			return Aggregate{}, fmt.Errorf("column %s: %v", column, err)
+		}
+		if !ok {
+			agg.Nulls++
+			continue
+		}
+		if math.IsNaN(v) {
+			agg.NaNs++
+			if nan == NaNSkip {
+				continue
+			}
+		}
+		agg.Value = fn(agg.Value, v, agg.Count == 0)
+		agg.Count++
+	}
+	agg.Valid = agg.Count > 0
+	if nan == NaNPropagate && agg.NaNs > 0 {
+		agg.Value = math.NaN()
+	}
+	return agg, nil
+}
+
+// Sum sums the values of the column, skipping NULLs.
+func (r ResultSet) Sum(column string, nan NaNPolicy) (Aggregate, error) {
+	return r.aggregate(column, nan, func(acc, v float64, _ bool) float64 {
+		return acc + v
+	})
+}
+
+// Avg averages the values of the column, skipping NULLs, which don't count as zero.
+func (r ResultSet) Avg(column string, nan NaNPolicy) (Aggregate, error) {
+	agg, err := r.Sum(column, nan)
+	if err != nil || !agg.Valid {
+		return agg, err
+	}
+	agg.Value /= float64(agg.Count)
+	return agg, nil
+}
+
+// Min returns the least value of the column, skipping NULLs.
+func (r ResultSet) Min(column string, nan NaNPolicy) (Aggregate, error) {
+	return r.aggregate(column, nan, func(acc, v float64, first bool) float64 {
+		if first || v < acc {
+			return v
+		}
+		return acc
+	})
+}
+
+// Max returns the greatest value of the column, skipping NULLs.
+func (r ResultSet) Max(column string, nan NaNPolicy) (Aggregate, error) {
+	return r.aggregate(column, nan, func(acc, v float64, first bool) float64 {
+		if first || v > acc {
+			return v
+		}
+		return acc
+	})
+}
+
+// Count returns the number of non-NULL values of the column, of any type.
+func (r ResultSet) Count(column string) (int, error) {
+	i, err := r.columnIndex(column)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, row := range r.Rows {
+		if i < len(row) && row[i] != nil {
+			n++
+		}
+	}
+	return n, nil
+}
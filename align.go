@@ -0,0 +1,216 @@
+package tsdbclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Series is one metric over time, NaN values are missing.
+type Series struct {
+	Name   string
+	Times  []time.Time
+	Values []float64
+}
+
+// SeriesFromResultSet takes the series of the value column over the time column,
+// NULL values are NaN.
+func SeriesFromResultSet(rs ResultSet, timeColumn, valueColumn string) (Series, error) {
+	ti, err := rs.columnIndex(timeColumn)
+	if err != nil {
+		return Series{}, err
+	}
+	vi, err := rs.columnIndex(valueColumn)
+	if err != nil {
+		return Series{}, err
+	}
+
+	s := Series{Name: valueColumn, Times: make([]time.Time, 0, len(rs.Rows)), Values: make([]float64, 0, len(rs.Rows))}
+	for _, row := range rs.Rows {
+		if ti >= len(row) || vi >= len(row) {
+			continue
+		}
+		t, err := timestampValue(row[ti])
+		if err != nil {
+			return Series{}, fmt.Errorf("column %s: %v", timeColumn, err)
+		}
+		v, ok, err := numericValue(row[vi])
+		if err != nil {
+			return Series{}, fmt.Errorf("column %s: %v", valueColumn, err)
+		}
+		if !ok {
+			v = math.NaN()
+		}
+		s.Times = append(s.Times, t)
+		s.Values = append(s.Values, v)
+	}
+	return s, nil
+}
+
+// timestampValue converts a timestamp of a result, RFC 3339 strings as sent by the
+// server or unix milliseconds.
+func timestampValue(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(time.RFC3339Nano, v)
+	case json.Number:
+		ms, err := v.Int64()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("not a timestamp: %s", v)
+		}
+		return time.UnixMilli(ms), nil
+	case int64:
+		return time.UnixMilli(v), nil
+	default:
+		return time.Time{}, fmt.Errorf("not a timestamp: %v of type %T", v, v)
+	}
+}
+
+// sorted returns the series ordered by time, copying it if needed.
+func (s Series) sorted() Series {
+	if sort.SliceIsSorted(s.Times, func(i, j int) bool { return s.Times[i].Before(s.Times[j]) }) {
+		return s
+	}
+	idx := make([]int, len(s.Times))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return s.Times[idx[i]].Before(s.Times[idx[j]]) })
+	c := Series{Name: s.Name, Times: make([]time.Time, len(idx)), Values: make([]float64, len(idx))}
+	for i, k := range idx {
+		c.Times[i], c.Values[i] = s.Times[k], s.Values[k]
+	}
+	return c
+}
+
+// Interpolation decides the value of a series at a time it has no point at.
+type Interpolation int8
+
+const (
+	_ Interpolation = iota
+	// InterpolateNone leaves the value missing, NaN.
+	InterpolateNone
+	// InterpolatePrevious takes the value of the previous point.
+	InterpolatePrevious
+	// InterpolateLinear interpolates between the previous and the next point.
+	InterpolateLinear
+	// InterpolateNearest takes the value of the closest point.
+	InterpolateNearest
+)
+
+// MergedSeries is a table of series on common timestamps, Values[i][j] is the value
+// of series i at Times[j].
+type MergedSeries struct {
+	Names  []string
+	Times  []time.Time
+	Values [][]float64
+}
+
+// at returns the value of the sorted series at t, interpolated when it has no point at t.
+func (s Series) at(t time.Time, interp Interpolation) float64 {
+	i := sort.Search(len(s.Times), func(i int) bool { return !s.Times[i].Before(t) })
+	if i < len(s.Times) && s.Times[i].Equal(t) {
+		return s.Values[i]
+	}
+
+	hasPrev, hasNext := i > 0, i < len(s.Times)
+	switch interp {
+	case InterpolatePrevious:
+		if hasPrev {
+			return s.Values[i-1]
+		}
+	case InterpolateLinear:
+		if hasPrev && hasNext {
+			t0, t1 := s.Times[i-1], s.Times[i]
+			v0, v1 := s.Values[i-1], s.Values[i]
+			f := float64(t.Sub(t0)) / float64(t1.Sub(t0))
+			return v0 + (v1-v0)*f
+		}
+	case InterpolateNearest:
+		switch {
+		case hasPrev && hasNext:
+			if t.Sub(s.Times[i-1]) <= s.Times[i].Sub(t) {
+				return s.Values[i-1]
+			}
+			return s.Values[i]
+		case hasPrev:
+			return s.Values[i-1]
+		case hasNext:
+			return s.Values[i]
+		}
+	}
+	return math.NaN()
+}
+
+// JoinOnTimestamp merges the series on the union of their timestamps, the values of
+// a series at the timestamps of the others are interpolated.
+func JoinOnTimestamp(interp Interpolation, series ...Series) (MergedSeries, error) {
+	if interp < InterpolateNone || interp > InterpolateNearest {
+		return MergedSeries{}, fmt.Errorf("invalid args: unknown interpolation %d", interp)
+	}
+
+	sortedSeries := make([]Series, len(series))
+	seen := make(map[int64]bool)
+	var m MergedSeries
+	for i, s := range series {
+		if len(s.Times) != len(s.Values) {
+			return MergedSeries{}, fmt.Errorf("invalid args: series %s has %d times and %d values", s.Name, len(s.Times), len(s.Values))
+		}
+		sortedSeries[i] = s.sorted()
+		m.Names = append(m.Names, s.Name)
+		for _, t := range s.Times {
+			if !seen[t.UnixNano()] {
+				seen[t.UnixNano()] = true
+				m.Times = append(m.Times, t)
+			}
+		}
+	}
+	sort.Slice(m.Times, func(i, j int) bool { return m.Times[i].Before(m.Times[j]) })
+
+	m.Values = make([][]float64, len(sortedSeries))
+	for i, s := range sortedSeries {
+		m.Values[i] = make([]float64, len(m.Times))
+		for j, t := range m.Times {
+			m.Values[i][j] = s.at(t, interp)
+		}
+	}
+	return m, nil
+}
+
+// AlignSeries pairs each point of a with the closest point of b within tolerance,
+// on the timestamps of a. Points of a without match have a NaN value of b.
+func AlignSeries(a, b Series, tolerance time.Duration) (MergedSeries, error) {
+	if len(a.Times) != len(a.Values) || len(b.Times) != len(b.Values) {
+		return MergedSeries{}, fmt.Errorf("invalid args: series with different numbers of times and values")
+	}
+	a, b = a.sorted(), b.sorted()
+
+	m := MergedSeries{
+		Names:  []string{a.Name, b.Name},
+		Times:  a.Times,
+		Values: [][]float64{a.Values, make([]float64, len(a.Times))},
+	}
+	for j, t := range a.Times {
+		v := math.NaN()
+		i := sort.Search(len(b.Times), func(i int) bool { return !b.Times[i].Before(t) })
+		best := time.Duration(-1)
+		for _, k := range []int{i - 1, i} {
+			if k < 0 || k >= len(b.Times) {
+				continue
+			}
+			d := b.Times[k].Sub(t)
+			if d < 0 {
+				d = -d
+			}
+			if d <= tolerance && (best < 0 || d < best) {
+				best, v = d, b.Values[k]
+			}
+		}
+		m.Values[1][j] = v
+	}
+	return m, nil
+}
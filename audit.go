@@ -0,0 +1,204 @@
+package tsdbclient
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditSTable = "tsdbclient_audit"
+
+	// auditBufferSize is the number of audit rows queued before new ones are dropped.
+	auditBufferSize = 4096
+
+	// auditBatchSize and auditFlushInterval bound the audit rows written in one batch.
+	auditBatchSize     = 500
+	auditFlushInterval = time.Second
+)
+
+// AuditConfig records the metadata of every write batch in an audit super table,
+// one row per measurement of the batch: tags `service`, `measurement` and
+// `instance`, unique to the client so each one writes its own child tables, fields
+// `points`, `request_id` (the req_id the batch was sent with, 0 with StmtBackend
+// which sends none), `status` ("ok" or "error") and `error`. Audit rows are
+// written in line protocol whatever the WriteProtocol, so the super table is
+// created on first use. They are queued and written in batches in the background,
+// every second or 500 rows, the rows are dropped when the queue is full. The rows
+// of a client have distinct timestamps in the precision of its database, at
+// least a unit apart, so none overwrites another.
+type AuditConfig struct {
+	// STable is the audit super table, defaults to "tsdbclient_audit".
+	STable string
+
+	// Database is the database of the audit table, defaults to the batch database.
+	Database string
+
+	// Service identifies the writer in the audit rows.
+	Service string
+}
+
+// auditWriter writes the audit rows of a client and its derived clients in the background.
+type auditWriter struct {
+	conf      AuditConfig
+	client    *tsdbClient
+	instance  string
+	precision string
+	unit      time.Duration
+	rows      chan auditRow
+	stop      chan struct{}
+	done      chan struct{}
+
+	lock sync.Mutex
+	last time.Time
+}
+
+type auditRow struct {
+	database string
+	point    *DataPoint
+}
+
+func newAuditWriter(client *tsdbClient, conf AuditConfig) *auditWriter {
+	if len(conf.STable) == 0 {
+		conf.STable = defaultAuditSTable
+	}
+	a := &auditWriter{
+		conf:      conf,
+		client:    client,
+		instance:  uuid.NewString(),
+		precision: client.dbConfig.Precision,
+		rows:      make(chan auditRow, auditBufferSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	var err error
+	if a.unit, err = precisionUnit(a.precision); err != nil {
+		a.precision, a.unit = "ms", time.Millisecond
+	}
+	go a.run()
+	return a
+}
+
+// audit queues the rows of the batch written with err under reqID.
+func (client *tsdbClient) audit(bps BatchPoints, reqID int64, err error) {
+	a := client.auditor
+	if a == nil {
+		return
+	}
+
+	db := a.conf.Database
+	if len(db) == 0 {
+		db = bps.Database()
+	}
+
+	counts := make(map[string]int64)
+	var order []string
+	for _, p := range bps.Points() {
+		if p == nil {
+			continue
+		}
+		if _, ok := counts[p.Name()]; !ok {
+			order = append(order, p.Name())
+		}
+		counts[p.Name()]++
+	}
+
+	status, errMsg := "ok", ""
+	if err != nil {
+		status, errMsg = "error", err.Error()
+	}
+	for _, m := range order {
+		p, e := NewDataPoint(a.conf.STable,
+			map[string]string{"service": a.conf.Service, "measurement": m, "instance": a.instance},
+			map[string]interface{}{"points": counts[m], "request_id": reqID, "status": status, "error": errMsg},
+			a.nextTime())
+		if e != nil {
+			client.log().Error("audit point failed", "measurement", m, "error", e)
+			return
+		}
+		select {
+		case a.rows <- auditRow{database: db, point: p}:
+		default:
+			client.log().Warn("audit row dropped, queue full", "measurement", m, "request_id", reqID)
+		}
+	}
+}
+
+// nextTime returns the timestamp of a new audit row, after the previous one by at
+// least a unit of the precision so two rows never overwrite each other.
+func (a *auditWriter) nextTime() time.Time {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	t := time.Now().Truncate(a.unit)
+	if !t.After(a.last) {
+		t = a.last.Add(a.unit)
+	}
+	a.last = t
+	return t
+}
+
+func (a *auditWriter) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(auditFlushInterval)
+	defer ticker.Stop()
+
+	var rows []auditRow
+	flush := func() {
+		if len(rows) > 0 {
+			a.write(rows)
+			rows = nil
+		}
+	}
+	for {
+		select {
+		case r := <-a.rows:
+			rows = append(rows, r)
+			if len(rows) >= auditBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-a.stop:
+			for {
+				select {
+				case r := <-a.rows:
+					rows = append(rows, r)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// write writes the rows in one batch per database, in line protocol.
+func (a *auditWriter) write(rows []auditRow) {
+	batches := make(map[string]BatchPoints)
+	var order []string
+	for _, r := range rows {
+		bp, ok := batches[r.database]
+		if !ok {
+			bp, _ = NewBatchPoints(BatchPointsConfig{Database: r.database, Precision: a.precision})
+			batches[r.database] = bp
+			order = append(order, r.database)
+		}
+		bp.AddPoint(r.point)
+	}
+
+	ctx := withWriteProtocol(context.Background(), InfluxLineProtocol)
+	for _, db := range order {
+		if err := a.client.httpClient.WriteContext(ctx, batches[db]); err != nil {
+			a.client.log().Error("audit write failed", "database", db, "rows", len(batches[db].Points()), "error", err)
+		}
+	}
+}
+
+// close writes the queued rows and stops the writer.
+func (a *auditWriter) close() {
+	close(a.stop)
+	<-a.done
+}
@@ -0,0 +1,106 @@
+package tsdbclient
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	taoserrors "github.com/taosdata/driver-go/v3/errors"
+)
+
+const minAutoCreateLength = 64
+
+// isNotExistsTable reports whether err is the server rejecting a write to a missing table.
+func isNotExistsTable(err error) bool {
+	if errors.Is(err, ErrNotExistsTable) {
+		return true
+	}
+	var taosErr *taoserrors.TaosError
+	if errors.As(err, &taosErr) {
+		return errorForCode(int(taosErr.Code)) == ErrNotExistsTable
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "table does not exist")
+}
+
+// createSTables creates the super tables of the points of the batch, with a column
+// per field and an NCHAR tag per tag. String fields are VARCHAR columns, as bound
+// by the stmt backend and written by line protocol. Child tables are created by the write itself.
+func (client *tsdbClient) createSTables(bp BatchPoints) error {
+	type schema struct {
+		columns map[string]Column
+		tags    map[string]Column
+	}
+	schemas := make(map[string]*schema)
+
+	for _, p := range bp.Points() {
+		if p == nil {
+			continue
+		}
+		fields, err := p.Fields()
+		if err != nil {
+			return err
+		}
+		s, ok := schemas[p.Name()]
+		if !ok {
+			s = &schema{columns: make(map[string]Column), tags: make(map[string]Column)}
+			schemas[p.Name()] = s
+		}
+		for k, v := range p.Tags() {
+			s.tags[k] = widerColumn(s.tags[k], Column{Name: k, Type: TypeNchar, Length: len(v)})
+		}
+		for k, v := range fields {
+			c, err := fieldColumn(k, v)
+			if err != nil {
+				return err
+			}
+			s.columns[k] = widerColumn(s.columns[k], c)
+		}
+	}
+
+	for name, s := range schemas {
+		if err := client.CreateSTable(name, sortedColumns(s.columns), sortedColumns(s.tags)); err != nil {
+			return fmt.Errorf("auto create super table %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func fieldColumn(name string, v interface{}) (Column, error) {
+	switch x := v.(type) {
+	case float64, float32:
+		return Column{Name: name, Type: TypeDouble}, nil
+	case int64, int32, int:
+		return Column{Name: name, Type: TypeBigInt}, nil
+	case uint64, uint32, uint:
+		return Column{Name: name, Type: TypeUBigInt}, nil
+	case bool:
+		return Column{Name: name, Type: TypeBool}, nil
+	case string:
+		return Column{Name: name, Type: TypeVarchar, Length: len(x)}, nil
+	}
+	return Column{}, fmt.Errorf("unsupported field type %T of %s", v, name)
+}
+
+// widerColumn returns c with the length of the longer of c and prev, at least minAutoCreateLength.
+func widerColumn(prev, c Column) Column {
+	if !c.Type.hasLength() {
+		return c
+	}
+	if prev.Length > c.Length {
+		c.Length = prev.Length
+	}
+	if c.Length < minAutoCreateLength {
+		c.Length = minAutoCreateLength
+	}
+	return c
+}
+
+func sortedColumns(m map[string]Column) []Column {
+	columns := make([]Column, 0, len(m))
+	for _, c := range m {
+		columns = append(columns, c)
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+	return columns
+}
@@ -0,0 +1,286 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// durationLiteralPattern matches the duration literals of window clauses, e.g. 10m.
+var durationLiteralPattern = regexp.MustCompile(`^[0-9]+[buasmhdwny]$`)
+
+var fillModes = map[string]bool{
+	"none": true, "null": true, "null_f": true, "value": true, "value_f": true,
+	"prev": true, "next": true, "linear": true,
+}
+
+// SelectBuilder builds a TDengine select statement. Errors are kept until Build,
+// so calls can be chained:
+//
+//	sql, err := Select("_wstart", "avg(current)").From("power.meters").
+//		Where("location = ?", "beijing").Interval("1m").Fill("prev").Build()
+type SelectBuilder struct {
+	columns     []string
+	from        string
+	where       []string
+	partitionBy []string
+	window      string
+	sliding     string
+	fill        string
+	groupBy     []string
+	orderBy     []string
+	slimit      string
+	limit       string
+	precision   string
+	err         error
+}
+
+// Select starts a statement selecting the expressions, "*" if none.
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns}
+}
+
+func (b *SelectBuilder) setErr(err error) *SelectBuilder {
+	if b.err == nil {
+		b.err = err
+	}
+	return b
+}
+
+// Precision sets the precision of the database, the window durations set after
+// it which are finer are rejected, see CheckDuration.
+func (b *SelectBuilder) Precision(precision string) *SelectBuilder {
+	b.precision = precision
+	return b
+}
+
+// From sets the table, a name qualified by its database as "db.table" is quoted part by part.
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	parts := strings.Split(table, ".")
+	for i, p := range parts {
+		q, err := QuoteIdent(p)
+		if err != nil || len(p) == 0 {
+			return b.setErr(fmt.Errorf("invalid args: table %q", table))
+		}
+		parts[i] = q
+	}
+	b.from = strings.Join(parts, ".")
+	return b
+}
+
+// Where adds a condition, ANDed with the others. The ? placeholders outside quotes
+// are replaced by the args rendered as NewQueryWithParameters does.
+func (b *SelectBuilder) Where(cond string, args ...interface{}) *SelectBuilder {
+	rendered, err := renderPositional(cond, args)
+	if err != nil {
+		return b.setErr(err)
+	}
+	b.where = append(b.where, rendered)
+	return b
+}
+
+func renderPositional(cond string, args []interface{}) (string, error) {
+	var s strings.Builder
+	n := 0
+	for i := 0; i < len(cond); i++ {
+		c := cond[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := skipQuoted(cond, i)
+			s.WriteString(cond[i:end])
+			i = end - 1
+		case c == '?':
+			if n >= len(args) {
+				return "", fmt.Errorf("invalid args: missing argument %d of %q", n+1, cond)
+			}
+			lit, err := formatParameter(args[n])
+			if err != nil {
+				return "", fmt.Errorf("argument %d of %q: %v", n+1, cond, err)
+			}
+			s.WriteString(lit)
+			n++
+		default:
+			s.WriteByte(c)
+		}
+	}
+	if n != len(args) {
+		return "", fmt.Errorf("invalid args: %d arguments for %d placeholders of %q", len(args), n, cond)
+	}
+	return s.String(), nil
+}
+
+// PartitionBy partitions the data by the expressions, windows are computed per partition.
+func (b *SelectBuilder) PartitionBy(exprs ...string) *SelectBuilder {
+	b.partitionBy = append(b.partitionBy, exprs...)
+	return b
+}
+
+func (b *SelectBuilder) setWindow(window string) *SelectBuilder {
+	if len(b.window) > 0 {
+		return b.setErr(errors.New("invalid args: more than one window clause"))
+	}
+	b.window = window
+	return b
+}
+
+// Interval sets a time window of the duration literal, with an optional offset.
+func (b *SelectBuilder) Interval(interval string, offset ...string) *SelectBuilder {
+	args := append([]string{interval}, offset...)
+	if len(args) > 2 {
+		return b.setErr(errors.New("invalid args: interval takes one offset"))
+	}
+	for _, a := range args {
+		if err := CheckDuration(a, b.precision); err != nil {
+			return b.setErr(err)
+		}
+	}
+	return b.setWindow("interval(" + strings.Join(args, ", ") + ")")
+}
+
+// Sliding sets the sliding of the Interval window.
+func (b *SelectBuilder) Sliding(sliding string) *SelectBuilder {
+	if err := CheckDuration(sliding, b.precision); err != nil {
+		return b.setErr(err)
+	}
+	b.sliding = "sliding(" + sliding + ")"
+	return b
+}
+
+// SessionWindow sets a session window on the timestamp column closing after gap.
+func (b *SelectBuilder) SessionWindow(column, gap string) *SelectBuilder {
+	if err := CheckDuration(gap, b.precision); err != nil {
+		return b.setErr(err)
+	}
+	return b.setWindow("session(" + column + ", " + gap + ")")
+}
+
+// StateWindow sets a window over the rows sharing the value of expr.
+func (b *SelectBuilder) StateWindow(expr string) *SelectBuilder {
+	return b.setWindow("state_window(" + expr + ")")
+}
+
+// EventWindow sets a window opened by start and closed by end conditions.
+func (b *SelectBuilder) EventWindow(start, end string) *SelectBuilder {
+	return b.setWindow("event_window start with " + start + " end with " + end)
+}
+
+// Fill sets how the empty Interval windows are filled, values are required by
+// the "value" and "value_f" modes.
+func (b *SelectBuilder) Fill(mode string, values ...interface{}) *SelectBuilder {
+	mode = strings.ToLower(mode)
+	if !fillModes[mode] {
+		return b.setErr(fmt.Errorf("invalid args: fill mode %q", mode))
+	}
+	withValues := mode == "value" || mode == "value_f"
+	if withValues != (len(values) > 0) {
+		return b.setErr(fmt.Errorf("invalid args: fill mode %s with %d values", mode, len(values)))
+	}
+
+	args := []string{mode}
+	for _, v := range values {
+		lit, err := formatParameter(v)
+		if err != nil {
+			return b.setErr(fmt.Errorf("fill value: %v", err))
+		}
+		args = append(args, lit)
+	}
+	b.fill = "fill(" + strings.Join(args, ", ") + ")"
+	return b
+}
+
+// GroupBy groups the rows by the expressions.
+func (b *SelectBuilder) GroupBy(exprs ...string) *SelectBuilder {
+	b.groupBy = append(b.groupBy, exprs...)
+	return b
+}
+
+// OrderBy orders the result, e.g. OrderBy("_wstart desc").
+func (b *SelectBuilder) OrderBy(exprs ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, exprs...)
+	return b
+}
+
+// Limit limits the rows returned per partition, skipping offset rows.
+func (b *SelectBuilder) Limit(limit int, offset ...int) *SelectBuilder {
+	b.limit = "limit " + limitClause(limit, offset)
+	return b
+}
+
+// SLimit limits the partitions returned, skipping offset partitions.
+func (b *SelectBuilder) SLimit(limit int, offset ...int) *SelectBuilder {
+	b.slimit = "slimit " + limitClause(limit, offset)
+	return b
+}
+
+func limitClause(limit int, offset []int) string {
+	s := strconv.Itoa(limit)
+	if len(offset) > 0 && offset[0] > 0 {
+		s += " offset " + strconv.Itoa(offset[0])
+	}
+	return s
+}
+
+// Build returns the statement, or the first error of the chain.
+func (b *SelectBuilder) Build() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	if len(b.from) == 0 {
+		return "", errors.New("invalid args: select without from")
+	}
+	if len(b.fill) > 0 && !strings.HasPrefix(b.window, "interval(") {
+		return "", errors.New("invalid args: fill requires an interval window")
+	}
+	if len(b.sliding) > 0 && !strings.HasPrefix(b.window, "interval(") {
+		return "", errors.New("invalid args: sliding requires an interval window")
+	}
+
+	columns := "*"
+	if len(b.columns) > 0 {
+		columns = strings.Join(b.columns, ", ")
+	}
+	clauses := []string{"select " + columns, "from " + b.from}
+	if len(b.where) > 0 {
+		if len(b.where) == 1 {
+			clauses = append(clauses, "where "+b.where[0])
+		} else {
+			clauses = append(clauses, "where ("+strings.Join(b.where, ") and (")+")")
+		}
+	}
+	if len(b.partitionBy) > 0 {
+		clauses = append(clauses, "partition by "+strings.Join(b.partitionBy, ", "))
+	}
+	for _, c := range []string{b.window, b.sliding, b.fill} {
+		if len(c) > 0 {
+			clauses = append(clauses, c)
+		}
+	}
+	if len(b.groupBy) > 0 {
+		clauses = append(clauses, "group by "+strings.Join(b.groupBy, ", "))
+	}
+	if len(b.orderBy) > 0 {
+		clauses = append(clauses, "order by "+strings.Join(b.orderBy, ", "))
+	}
+	for _, c := range []string{b.slimit, b.limit} {
+		if len(c) > 0 {
+			clauses = append(clauses, c)
+		}
+	}
+	return strings.Join(clauses, " "), nil
+}
+
+// QueryData builds the statement and runs it with QueryDataContext of client,
+// the default client if nil.
+func (b *SelectBuilder) QueryData(ctx context.Context, client TSDBClient, convertNumber bool) ([]map[string]interface{}, error) {
+	sql, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = clientWrapper
+	}
+	return client.QueryDataContext(ctx, sql, convertNumber)
+}
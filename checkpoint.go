@@ -0,0 +1,110 @@
+package tsdbclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CheckpointStore persists the high-watermarks of incremental reads, the last
+// timestamp read per key.
+type CheckpointStore interface {
+	// Load returns the watermark of key, false when none was saved.
+	Load(ctx context.Context, key string) (time.Time, bool, error)
+
+	// Save replaces the watermark of key.
+	Save(ctx context.Context, key string, ts time.Time) error
+}
+
+// MemoryCheckpointStore keeps the watermarks in memory, they are lost on restart.
+type MemoryCheckpointStore struct {
+	lock       sync.Mutex
+	watermarks map[string]time.Time
+}
+
+// NewMemoryCheckpointStore returns an empty in memory store.
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{watermarks: make(map[string]time.Time)}
+}
+
+func (s *MemoryCheckpointStore) Load(_ context.Context, key string) (time.Time, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ts, ok := s.watermarks[key]
+	return ts, ok, nil
+}
+
+func (s *MemoryCheckpointStore) Save(_ context.Context, key string, ts time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.watermarks[key] = ts
+	return nil
+}
+
+// FileCheckpointStore keeps the watermarks in a json file, rewritten as a whole
+// on each save through a rename so a crash leaves either version.
+type FileCheckpointStore struct {
+	path string
+	lock sync.Mutex
+}
+
+// NewFileCheckpointStore returns a store in the file at path, created on the first save.
+func NewFileCheckpointStore(path string) (*FileCheckpointStore, error) {
+	if len(path) == 0 {
+		return nil, errors.New("invalid args: `path` is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return &FileCheckpointStore{path: path}, nil
+}
+
+func (s *FileCheckpointStore) Load(_ context.Context, key string) (time.Time, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	watermarks, err := s.read()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	ts, ok := watermarks[key]
+	return ts, ok, nil
+}
+
+func (s *FileCheckpointStore) Save(_ context.Context, key string, ts time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	watermarks, err := s.read()
+	if err != nil {
+		return err
+	}
+	watermarks[key] = ts
+
+	b, err := json.MarshalIndent(watermarks, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *FileCheckpointStore) read() (map[string]time.Time, error) {
+	watermarks := make(map[string]time.Time)
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return watermarks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &watermarks); err != nil {
+		return nil, err
+	}
+	return watermarks, nil
+}
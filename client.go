@@ -2,8 +2,10 @@ package tsdbclient
 
 import (
 	"bytes"
-	"compress/gzip"
+	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,6 +15,9 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jeagle929/tsdbclient/models"
@@ -32,11 +37,26 @@ const (
 
 const InfluxTimeFormat = "2006-01-02T15:04:05Z"
 
+const (
+	defaultMaxIdleConns    = 100
+	defaultIdleConnTimeout = 90 * time.Second
+)
+
 // HTTPConfig is the config data needed to create an HTTP Client.
 type HTTPConfig struct {
 	// Addr should be of the form "http://host:port"
 	Addr string
 
+	// ReadAddr is the address queries, including DDL, are sent to, defaults to Addr.
+	ReadAddr string
+
+	// WriteAddr is the address writes are sent to, defaults to Addr.
+	WriteAddr string
+
+	// ReplicaAddrs are read replicas, queries with a MaxStaleness are sent to them in
+	// turn, the others to ReadAddr.
+	ReplicaAddrs []string
+
 	// Username is the influxdb username, optional.
 	Username string
 
@@ -49,6 +69,21 @@ type HTTPConfig struct {
 	// Timeout for tdengine writes, defaults to no timeout.
 	Timeout time.Duration
 
+	// PingQuery is the health check query of PingContext, defaults to DefaultPingQuery.
+	PingQuery string
+
+	// Logger receives the logs of the client, defaults to the logger set by SetLogger.
+	Logger Logger
+
+	// DialControl vets the resolved address of each connection, see AllowNetworks.
+	DialControl DialControl
+
+	// LogDials logs the address each connection resolved to, for audit.
+	LogDials bool
+
+	// Middlewares wrap the transport of all requests, the first one is the outermost.
+	Middlewares []Middleware
+
 	// InsecureSkipVerify gets passed to the http client, if true, it will
 	// skip https certificate verification. Defaults to false.
 	InsecureSkipVerify bool
@@ -57,34 +92,162 @@ type HTTPConfig struct {
 	// Client. If set, this option overrides InsecureSkipVerify.
 	TLSConfig *tls.Config
 
+	// StrictTLS refuses the configs which are not https only with TLS 1.2 or later,
+	// a client certificate, and the server certificate verified against a pinned CA
+	// (TLSConfig.RootCAs) or SPKIPins. InsecureSkipVerify is rejected.
+	StrictTLS bool
+
+	// SPKIPins are the accepted public keys of the server certificates, see SPKIPin.
+	SPKIPins []string
+
 	// Proxy configures the Proxy function on the HTTP client.
 	Proxy func(req *http.Request) (*url.URL, error)
 
 	// WriteEncoding specifies the encoding of write request
 	WriteEncoding ContentEncoding
+
+	// GzipLevel is the compression level of GzipEncoding, defaults to gzip.DefaultCompression.
+	// Use GzipNoCompression for gzip.NoCompression.
+	GzipLevel int
+
+	// WriteProtocol is the format points are written in, defaults to InfluxLineProtocol.
+	WriteProtocol WriteProtocol
+
+	// WriteRetry configures the retries of failed writes, defaults to no retry.
+	WriteRetry RetryPolicy
+
+	// WriteChecksum sends the SHA-256 of write payloads in a Content-Digest header
+	// (RFC 9530), so a proxy in between can verify them.
+	WriteChecksum bool
+
+	// VerifyContentLength fails responses whose body length differs from their
+	// Content-Length header with ErrContentLengthMismatch.
+	VerifyContentLength bool
+
+	// TokenAuth authenticates with a token obtained by logging in once instead
+	// of sending the credentials with each request. It applies to the HTTP
+	// requests only: the tmq websocket of the subscriptions takes no token, their
+	// consumers log in with the credentials and spread their reconnects instead.
+	TokenAuth bool
+
+	// TokenRefresh is how long a token is used before logging in again, defaults to 30m.
+	TokenRefresh time.Duration
+
+	// LimitPolicy appends a default LIMIT to the selects lacking one, see InteractiveLimit.
+	LimitPolicy LimitPolicy
+
+	// MaxPointsPerRequest splits the writes of larger batches into requests of at
+	// most this many points, 0 for no limit.
+	MaxPointsPerRequest int
+
+	// MaxBodySize splits the writes whose body would be larger than this many
+	// bytes before compression, 0 for no limit. A single point larger than it is
+	// sent alone.
+	MaxBodySize int
+
+	// WriteConcurrency is how many requests of a split write are sent at once,
+	// defaults to 1.
+	WriteConcurrency int
+
+	// MaxIdleConns is the maximum number of idle connections kept, defaults to 100.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept to a
+	// host, defaults to MaxIdleConns. The requests to taosAdapter go to one host
+	// or few, with a lower limit concurrent writes close the connections they
+	// can't keep and leave sockets in TIME_WAIT.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost limits the connections to a host, the requests above it
+	// wait for one, 0 for no limit.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is how long an idle connection is kept, defaults to 90s.
+	IdleConnTimeout time.Duration
+
+	// KeepAlive is the period of the TCP keep-alive probes, defaults to 30s, a
+	// negative value disables them.
+	KeepAlive time.Duration
+
+	// DisableKeepAlives opens a connection per request.
+	DisableKeepAlives bool
+
+	// HTTPClient sends the requests instead of a client created from this config,
+	// its Timeout is kept unless Timeout is set. The transport settings, TLSConfig,
+	// InsecureSkipVerify, Proxy, DialControl and the connection pool, are ignored;
+	// Middlewares still wrap its transport.
+	HTTPClient *http.Client
+
+	// RoundTripper is the transport of the requests instead of one created from
+	// this config, the transport settings are ignored as with HTTPClient.
+	RoundTripper http.RoundTripper
+
+	// credentials are shared with the TSDBClient so ChangePassword reaches the
+	// transport, from Username and Password when nil.
+	credentials *credentials
 }
 
 // BatchPointsConfig is the config data needed to create an instance of the BatchPoints struct.
 type BatchPointsConfig struct {
 	// Precision is the write precision of the points, defaults to "ms".
+	// Timestamps of the points are converted to it when the batch is encoded.
 	Precision string
 
 	// Database is the database to write points to.
 	Database string
+
+	// StrictPrecision fails the write of points whose timestamps would be
+	// truncated by Precision instead of silently truncating them.
+	StrictPrecision bool
+
+	// TTL is the time to live in days of the child tables created by the write,
+	// 0 keeps them forever. Tables existing before are not changed.
+	TTL int
+
+	// TableNameKey names the child tables created by the write after the value of
+	// this tag instead of a hash of all tags, the tag is not stored. It is only
+	// supported by the REST line protocol writes.
+	TableNameKey string
 }
 
+// ErrPrecisionTruncated is returned by writes of batches with StrictPrecision
+// containing timestamps finer than the batch precision.
+var ErrPrecisionTruncated = errors.New("timestamp truncated by batch precision")
+
+// ErrContentLengthMismatch is returned with VerifyContentLength for responses
+// whose body length differs from their Content-Length header.
+var ErrContentLengthMismatch = errors.New("response body length does not match content length")
+
 // Client is a client interface for writing & querying the database.
 type Client interface {
 	// Ping checks that status of cluster
 	Ping() (time.Duration, string, error)
 
+	// PingContext runs the health check query under ctx and reports the server status.
+	PingContext(ctx context.Context) (*PingResult, error)
+
+	// Health pings the server several times and reports its health.
+	Health(ctx context.Context, database string, probes int) *HealthReport
+
 	// Write takes a BatchPoints object and writes all Points to InfluxDB.
 	Write(bp BatchPoints) error
 
+	// WriteContext is Write with a context controlling cancellation and deadline.
+	WriteContext(ctx context.Context, bp BatchPoints) error
+
+	// WriteWithResult is WriteContext reporting the points refused by the server.
+	WriteWithResult(ctx context.Context, bp BatchPoints) (*WriteResult, error)
+
 	// Query makes an TDEngine Query on the database. This will fail if using
 	// the UDP client.
 	Query(q Query) (*Response, error)
 
+	// QueryContext is Query with a context controlling cancellation and deadline.
+	QueryContext(ctx context.Context, q Query) (*Response, error)
+
+	// QueryStream is Query decoding the rows incrementally through the returned iterator.
+	QueryStream(ctx context.Context, q Query) (*QueryIterator, error)
+
 	// Close releases any resources a Client may be using.
 	Close() error
 }
@@ -96,77 +259,188 @@ func NewHTTPClient(conf HTTPConfig) (Client, error) {
 		conf.UserAgent = "TDEngineDBClient"
 	}
 
-	u, err := url.Parse(conf.Addr)
+	u, err := parseAddr(conf.Addr)
 	if err != nil {
 		return nil, err
-	} else if u.Scheme != "http" && u.Scheme != "https" {
-		m := fmt.Sprintf("Unsupported protocol scheme: %s, your address"+
-			" must start with http:// or https://", u.Scheme)
-		return nil, errors.New(m)
+	}
+	readURL, writeURL := u, u
+	if len(conf.ReadAddr) > 0 {
+		if readURL, err = parseAddr(conf.ReadAddr); err != nil {
+			return nil, err
+		}
+	}
+	if len(conf.WriteAddr) > 0 {
+		if writeURL, err = parseAddr(conf.WriteAddr); err != nil {
+			return nil, err
+		}
+	}
+	replicas := make([]url.URL, 0, len(conf.ReplicaAddrs))
+	for _, addr := range conf.ReplicaAddrs {
+		r, err := parseAddr(addr)
+		if err != nil {
+			return nil, err
+		}
+		replicas = append(replicas, *r)
 	}
 
-	switch conf.WriteEncoding {
-	case DefaultEncoding, GzipEncoding:
+	if err := checkEncoding(conf.WriteEncoding, conf.GzipLevel); err != nil {
+		return nil, err
+	}
+
+	switch conf.WriteProtocol {
+	case InfluxLineProtocol, OpenTSDBTelnet, OpenTSDBJSON:
 	default:
-		return nil, fmt.Errorf("unsupported encoding %s", conf.WriteEncoding)
+		return nil, fmt.Errorf("unsupported write protocol %s", conf.WriteProtocol)
 	}
 
+	if conf.StrictTLS && (conf.HTTPClient != nil || conf.RoundTripper != nil) {
+		return nil, errors.New("invalid args: `StrictTLS` can't verify a custom http client or transport")
+	}
+	if conf.MaxIdleConns <= 0 {
+		conf.MaxIdleConns = defaultMaxIdleConns
+	}
+	if conf.MaxIdleConnsPerHost <= 0 {
+		conf.MaxIdleConnsPerHost = conf.MaxIdleConns
+	}
+	if conf.IdleConnTimeout <= 0 {
+		conf.IdleConnTimeout = defaultIdleConnTimeout
+	}
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{
 			InsecureSkipVerify: conf.InsecureSkipVerify,
 		},
-		Proxy: conf.Proxy,
+		Proxy:               conf.Proxy,
+		MaxIdleConns:        conf.MaxIdleConns,
+		MaxIdleConnsPerHost: conf.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     conf.MaxConnsPerHost,
+		IdleConnTimeout:     conf.IdleConnTimeout,
+		DisableKeepAlives:   conf.DisableKeepAlives,
 	}
 	if conf.TLSConfig != nil {
 		tr.TLSClientConfig = conf.TLSConfig
 	}
-	return &client{
-		url:       *u,
-		username:  conf.Username,
-		password:  conf.Password,
-		useragent: conf.UserAgent,
-		httpClient: &http.Client{
-			Timeout:   conf.Timeout,
-			Transport: tr,
-		},
-		transport: tr,
-		encoding:  conf.WriteEncoding,
-	}, nil
+	if conf.StrictTLS {
+		urls := []*url.URL{u, readURL, writeURL}
+		for i := range replicas {
+			urls = append(urls, &replicas[i])
+		}
+		if tr.TLSClientConfig, err = strictTLSConfig(conf, urls...); err != nil {
+			return nil, err
+		}
+	}
+	tr.DialContext = dialContext(conf.DialControl, conf.KeepAlive, conf.LogDials, conf.Logger)
+	c := &client{
+		url:        *u,
+		readURL:    *readURL,
+		writeURL:   *writeURL,
+		replicas:   replicas,
+		creds:      conf.credentials,
+		useragent:  conf.UserAgent,
+		httpClient: newHTTPClient(conf, tr),
+		encoding:   conf.WriteEncoding,
+		gzipLevel:  conf.GzipLevel,
+		retry:      conf.WriteRetry,
+		checksum:   conf.WriteChecksum,
+		verifyLen:  conf.VerifyContentLength,
+		protocol:   conf.WriteProtocol,
+		pingQuery:  conf.PingQuery,
+
+		limitPolicy: conf.LimitPolicy,
+
+		maxPoints:        conf.MaxPointsPerRequest,
+		maxBodySize:      conf.MaxBodySize,
+		writeConcurrency: conf.WriteConcurrency,
+	}
+	if conf.HTTPClient == nil && conf.RoundTripper == nil {
+		c.transport = tr
+	}
+	untimed := *c.httpClient
+	untimed.Timeout = 0
+	c.untimedClient = &untimed
+	if c.creds == nil {
+		c.creds = newCredentials(conf.Username, conf.Password)
+	}
+	if conf.TokenAuth && conf.Username != "" {
+		c.tokens = newTokenSource(c.httpClient, c.readURL, c.creds, conf.TokenRefresh, conf.Logger)
+	}
+	return c, nil
 }
 
-// Ping will check to see if the server is up.
-// Ping returns how long the request took, the version of the server it connected to, and an error if one occurred.
-func (c *client) Ping() (time.Duration, string, error) {
-	now := time.Now()
-	var version string
-	if resp, err := c.Query(NewQuery("select server_version() as version", "", "")); err != nil {
-		return 0, "", err
-	} else if resp != nil {
-		version = resp.Data[resp.Rows-1][0].(string)
-	} else {
-		return 0, "", errors.New("get server version response empty")
+// newHTTPClient returns the http client of conf, which sends the requests through
+// tr unless it has its own HTTPClient or RoundTripper.
+func newHTTPClient(conf HTTPConfig, tr *http.Transport) *http.Client {
+	var hc http.Client
+	var base http.RoundTripper = tr
+	switch {
+	case conf.HTTPClient != nil:
+		// a copy, the middlewares must not wrap the transport of the caller's client
+		hc = *conf.HTTPClient
+		base = hc.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+	case conf.RoundTripper != nil:
+		base = conf.RoundTripper
+	}
+	if conf.Timeout > 0 || conf.HTTPClient == nil {
+		hc.Timeout = conf.Timeout
 	}
-	return time.Since(now), version, nil
+	hc.Transport = chainMiddlewares(base, conf.Middlewares)
+	return &hc
+}
+
+func parseAddr(addr string) (*url.URL, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		m := fmt.Sprintf("Unsupported protocol scheme: %s, your address"+
+			" must start with http:// or https://", u.Scheme)
+		return nil, errors.New(m)
+	}
+	return u, nil
 }
 
 // Close releases the client's resources.
 func (c *client) Close() error {
-	c.transport.CloseIdleConnections()
+	// a custom client or transport may be shared, it is left to its owner
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
+	}
 	return nil
 }
 
 // client is safe for concurrent use as the fields are all read-only
-// once the client is instantiated.
+// once the client is instantiated, but creds which is swapped atomically.
 type client struct {
 	// N.B - if url.UserInfo is accessed in future modifications to the
 	// methods on client, you will need to synchronize access to url.
 	url        url.URL
-	username   string
-	password   string
+	readURL    url.URL
+	writeURL   url.URL
+	replicas   []url.URL
+	next       atomic.Uint32
+	creds      *credentials
 	useragent  string
 	httpClient *http.Client
 	transport  *http.Transport
 	encoding   ContentEncoding
+	gzipLevel  int
+	retry      RetryPolicy
+	checksum   bool
+	verifyLen  bool
+	protocol   WriteProtocol
+	tokens     *tokenSource
+	pingQuery  string
+
+	// untimedClient is httpClient without Timeout, for the queries with their own.
+	untimedClient *http.Client
+
+	limitPolicy LimitPolicy
+
+	maxPoints        int
+	maxBodySize      int
+	writeConcurrency int
 }
 
 // BatchPoints is an interface into a batched grouping of points to write into
@@ -189,6 +463,21 @@ type BatchPoints interface {
 	Database() string
 	// SetDatabase sets the database of this Batch.
 	SetDatabase(s string)
+
+	// StrictPrecision returns whether truncated timestamps fail the write.
+	StrictPrecision() bool
+	// SetStrictPrecision sets whether truncated timestamps fail the write.
+	SetStrictPrecision(strict bool)
+
+	// TTL returns the time to live in days of the child tables created.
+	TTL() int
+	// SetTTL sets the time to live in days of the child tables created.
+	SetTTL(days int)
+
+	// TableNameKey returns the tag naming the child tables created.
+	TableNameKey() string
+	// SetTableNameKey sets the tag naming the child tables created.
+	SetTableNameKey(tag string)
 }
 
 // NewBatchPoints returns a BatchPoints interface based on the given config.
@@ -200,18 +489,35 @@ func NewBatchPoints(conf BatchPointsConfig) (BatchPoints, error) {
 		return nil, err
 	}
 	bp := &batchpoints{
-		database:  conf.Database,
-		precision: conf.Precision,
+		database:        conf.Database,
+		precision:       conf.Precision,
+		strictPrecision: conf.StrictPrecision,
+		ttl:             conf.TTL,
+		tableNameKey:    conf.TableNameKey,
 	}
 	return bp, nil
 }
 
+// configOf returns the config of bp, to create a batch of part of its points.
+func configOf(bp BatchPoints) BatchPointsConfig {
+	return BatchPointsConfig{
+		Precision:       bp.Precision(),
+		Database:        bp.Database(),
+		StrictPrecision: bp.StrictPrecision(),
+		TTL:             bp.TTL(),
+		TableNameKey:    bp.TableNameKey(),
+	}
+}
+
 type batchpoints struct {
 	points           []*DataPoint
 	database         string
 	precision        string
 	retentionPolicy  string
 	writeConsistency string
+	strictPrecision  bool
+	ttl              int
+	tableNameKey     string
 }
 
 func (bp *batchpoints) AddPoint(p *DataPoint) {
@@ -254,6 +560,30 @@ func (bp *batchpoints) SetDatabase(db string) {
 	bp.database = db
 }
 
+func (bp *batchpoints) StrictPrecision() bool {
+	return bp.strictPrecision
+}
+
+func (bp *batchpoints) SetStrictPrecision(strict bool) {
+	bp.strictPrecision = strict
+}
+
+func (bp *batchpoints) TTL() int {
+	return bp.ttl
+}
+
+func (bp *batchpoints) SetTTL(days int) {
+	bp.ttl = days
+}
+
+func (bp *batchpoints) TableNameKey() string {
+	return bp.tableNameKey
+}
+
+func (bp *batchpoints) SetTableNameKey(tag string) {
+	bp.tableNameKey = tag
+}
+
 func (bp *batchpoints) SetWriteConsistency(wc string) {
 	bp.writeConsistency = wc
 }
@@ -332,74 +662,235 @@ func NewPointFrom(pt models.Point) *DataPoint {
 	return &DataPoint{pt: pt}
 }
 
+// ParsePoints parses line protocol into points, with the timestamps in the given
+// precision, nanoseconds if empty. Points without timestamp are left without one,
+// so the client TimestampPolicy applies when they are written. Lines failing to parse
+// are reported by the error, the points of the other lines are returned with it.
+func ParsePoints(data []byte, precision string) ([]*DataPoint, error) {
+	pts, err := models.ParsePointsWithPrecision(data, time.Time{}, precision)
+	points := make([]*DataPoint, len(pts))
+	for i, pt := range pts {
+		points[i] = &DataPoint{pt: pt}
+	}
+	return points, err
+}
+
 func (c *client) Write(bp BatchPoints) error {
+	return c.WriteContext(context.Background(), bp)
+}
+
+// WriteContext is Write with a context controlling cancellation and deadline.
+// Batches beyond MaxPointsPerRequest or MaxBodySize are sent in several requests,
+// the error joins those of the requests which failed.
+func (c *client) WriteContext(ctx context.Context, bp BatchPoints) error {
+	parts := c.splitBatch(bp)
+	if len(parts) == 1 {
+		return c.writeRequest(ctx, parts[0])
+	}
+
+	concurrency := max(c.writeConcurrency, 1)
+	sem := make(chan struct{}, concurrency)
+	errs := make([]error, len(parts))
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, part BatchPoints) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = c.writeRequest(ctx, part)
+		}(i, part)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// splitBatch splits bp into batches within the points and body size limits,
+// the body size of a point is estimated from its line protocol.
+func (c *client) splitBatch(bp BatchPoints) []BatchPoints {
+	points := bp.Points()
+	if (c.maxPoints <= 0 || len(points) <= c.maxPoints) && c.maxBodySize <= 0 {
+		return []BatchPoints{bp}
+	}
+
+	var parts []BatchPoints
+	start, size := 0, 0
+	flush := func(end int) {
+		part, _ := NewBatchPoints(configOf(bp))
+		part.AddPoints(points[start:end])
+		parts = append(parts, part)
+		start, size = end, 0
+	}
+	for i, p := range points {
+		n := 0
+		if c.maxBodySize > 0 && p != nil {
+			n = len(p.pt.PrecisionString(bp.Precision())) + 1
+		}
+		if i > start && ((c.maxPoints > 0 && i-start >= c.maxPoints) || (c.maxBodySize > 0 && size+n > c.maxBodySize)) {
+			flush(i)
+		}
+		size += n
+	}
+	if start < len(points) || len(parts) == 0 {
+		flush(len(points))
+	}
+	if len(parts) == 1 {
+		return []BatchPoints{bp}
+	}
+	return parts
+}
+
+// writeRequest writes the batch in one request, retried by the RetryPolicy.
+func (c *client) writeRequest(ctx context.Context, bp BatchPoints) error {
 	var b bytes.Buffer
 
-	var w io.Writer
-	if c.encoding == GzipEncoding {
-		w = gzip.NewWriter(&b)
-	} else {
-		w = &b
+	var w io.Writer = &b
+	enc, err := newEncoder(c.encoding, c.gzipLevel, &b)
+	if err != nil {
+		return err
+	}
+	if enc != nil {
+		w = enc
+	}
+
+	protocol := c.writeProtocol(ctx)
+	switch protocol {
+	case OpenTSDBTelnet:
+		err = encodeOpenTSDBTelnet(w, bp)
+	case OpenTSDBJSON:
+		err = encodeOpenTSDBJSON(w, bp)
+	default:
+		err = encodeLineProtocol(w, bp)
+	}
+	if err != nil {
+		if enc != nil {
+			enc.Close()
+		}
+		return err
+	}
+
+	// compressing writer should be closed to flush data into underlying buffer
+	if enc != nil {
+		if err := enc.Close(); err != nil {
+			return err
+		}
+	}
+
+	u := c.writeURL
+	switch protocol {
+	case OpenTSDBTelnet:
+		u.Path = path.Join(u.Path, OpenTSDBTelnetURL, bp.Database())
+	case OpenTSDBJSON:
+		u.Path = path.Join(u.Path, OpenTSDBJSONURL, bp.Database())
+	default:
+		u.Path = path.Join(u.Path, WriteDataURL)
+	}
+
+	// the attempts share the req_id
+	reqID := requestID(ctx)
+	ctx = WithReqID(ctx, reqID)
+	for attempt := 1; ; attempt++ {
+		retryable, err := c.writeOnce(ctx, u.String(), b.Bytes(), bp)
+		if err == nil {
+			return nil
+		}
+		if !retryable || !c.retry.enabled() || attempt >= c.retry.MaxAttempts || ctx.Err() != nil {
+			return withReqID(err, reqID)
+		}
+		if e := c.retry.wait(ctx, attempt); e != nil {
+			return withReqID(err, reqID)
+		}
 	}
+}
 
+func encodeLineProtocol(w io.Writer, bp BatchPoints) error {
+	precision := bp.Precision()
+	multiplier := models.GetPrecisionMultiplier(precision)
 	for _, p := range bp.Points() {
 		if p == nil {
 			continue
 		}
-		if _, err := io.WriteString(w, p.pt.PrecisionString(bp.Precision())); err != nil {
-			return err
+		if bp.StrictPrecision() && !p.Time().IsZero() && p.UnixNano()%multiplier != 0 {
+			return fmt.Errorf("%w: point %s at %s, precision %s", ErrPrecisionTruncated, p.Name(), p.Time().Format(time.RFC3339Nano), precision)
 		}
-
-		if _, err := w.Write([]byte{'\n'}); err != nil {
+		if _, err := io.WriteString(w, p.pt.PrecisionString(precision)); err != nil {
 			return err
 		}
-	}
 
-	// gzip writer should be closed to flush data into underlying buffer
-	if c, ok := w.(io.Closer); ok {
-		if err := c.Close(); err != nil {
+		if _, err := w.Write([]byte{'\n'}); err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	u := c.url
-	u.Path = path.Join(u.Path, WriteDataURL)
-
-	req, err := http.NewRequest("POST", u.String(), &b)
+// writeOnce sends one write request, it reports whether a failure may be retried.
+func (c *client) writeOnce(ctx context.Context, u string, body []byte, bp BatchPoints) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(body))
 	if err != nil {
-		return err
+		return false, err
 	}
 	if c.encoding != DefaultEncoding {
 		req.Header.Set("Content-Encoding", string(c.encoding))
 	}
 	req.Header.Set("Content-Type", "")
 	req.Header.Set("User-Agent", c.useragent)
-	if c.username != "" {
-		req.SetBasicAuth(c.username, c.password)
+	if c.checksum {
+		sum := sha256.Sum256(body)
+		req.Header.Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
 	}
-
 	params := req.URL.Query()
-	params.Set("db", bp.Database())
-	params.Set("precision", bp.Precision())
+	if id := ReqIDFrom(ctx); id != 0 {
+		params.Set("req_id", strconv.FormatInt(id, 10))
+	}
+	if c.writeProtocol(ctx) == InfluxLineProtocol {
+		params.Set("db", bp.Database())
+		params.Set("precision", wirePrecision(bp.Precision()))
+		if bp.TTL() > 0 {
+			params.Set("ttl", strconv.Itoa(bp.TTL()))
+		}
+		if len(bp.TableNameKey()) > 0 {
+			params.Set("table_name_key", bp.TableNameKey())
+		}
+	}
 	req.URL.RawQuery = params.Encode()
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return err
+		return true, err
 	}
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return true, err
+	}
+	if err = c.checkContentLength(resp, int64(len(respBody))); err != nil {
+		return true, err
 	}
 
 	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		var err = errors.New(string(body))
-		return err
+		var err error = &httpStatusError{code: resp.StatusCode, msg: string(respBody)}
+		return c.retry.retryableStatus(resp.StatusCode), err
 	}
 
-	return nil
+	return false, nil
+}
+
+// wirePrecision returns the precision name understood by the influxdb write endpoint.
+func wirePrecision(precision string) string {
+	switch precision {
+	case "us", "µs", "μs":
+		return "u"
+	case "n":
+		return "ns"
+	}
+	return precision
 }
 
 // Query defines a query to send to the server.
@@ -407,6 +898,46 @@ type Query struct {
 	Command   string
 	Database  string
 	Precision string
+
+	// NoCache bypasses the query cache, see the NoCache option.
+	NoCache bool
+
+	// MaxStaleness is how old a result may be, from the query cache or from a read
+	// replica, zero reads from the primary and uses the cache TTL.
+	MaxStaleness time.Duration
+
+	// Timeout bounds this query instead of the Timeout of the client, so a slow
+	// analytic query may be given minutes on a client tuned for fast lookups.
+	Timeout time.Duration
+
+	// MaxRows caps the rows of the result, see the MaxRows option.
+	MaxRows int
+
+	// TimestampFormat and Location decode the TIMESTAMP columns in QueryData over
+	// those of the client, see DecodeTimestamps.
+	TimestampFormat TimestampFormat
+	Location        *time.Location
+}
+
+type queryTimeoutKey struct{}
+
+// prepareQuery applies the options of ctx to q, limits its rows and bounds ctx by
+// its Timeout. The requests sent under the returned context are not bounded by
+// the Timeout of the client.
+func prepareQuery(ctx context.Context, q Query, policy LimitPolicy) (context.Context, Query, context.CancelFunc) {
+	q = withContextOptions(ctx, q)
+	if q.MaxRows > 0 {
+		// one more row tells the result was truncated
+		q.Command = appendLimit(q.Command, q.MaxRows+1)
+	}
+	if n := policyLimit(ctx, q, policy); n > 0 {
+		q.Command = appendLimit(q.Command, n)
+	}
+	if q.Timeout <= 0 {
+		return ctx, q, func() {}
+	}
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, queryTimeoutKey{}, q.Timeout), q.Timeout)
+	return ctx, q, cancel
 }
 
 // NewQuery returns a query object.
@@ -428,29 +959,52 @@ type Response struct {
 	ColumnMeta [][]interface{} `json:"column_meta,omitempty"`
 	Data       [][]interface{} `json:"data,omitempty"`
 	Rows       int             `json:"rows,omitempty"`
+
+	// Columns is ColumnMeta parsed when the response is decoded.
+	Columns []ColumnMeta `json:"-"`
+
+	// Warning reports a result returned incomplete, such as a RowLimitWarning.
+	Warning error `json:"-"`
+
+	// ReqID is the req_id the query was sent with, it is reported by Error.
+	ReqID int64 `json:"-"`
 }
 
 // Error returns the first error from any statement.
 func (r *Response) Error() error {
 	if r.Code != 0 || len(r.Desc) > 0 {
-		//if strings.Contains(r.Desc, "error: Table does not exist") || strings.Contains(r.Desc, "Database not specified") {
-		//	return ErrNotExistsTable
-		//}
-		if r.Code == 9826 || r.Code == 9750 {
-			return ErrNotExistsTable
+		if err := errorForCode(r.Code); err != nil {
+			return withReqID(err, r.ReqID)
 		}
-		return errors.New(r.Desc)
+		return withReqID(errors.New(redact(r.Desc)), r.ReqID)
 	}
 	return nil
 }
 
 // Query sends a command to the server and returns the Response.
 func (c *client) Query(q Query) (*Response, error) {
-	req, err := c.createDefaultRequest(q)
+	return c.QueryContext(context.Background(), q)
+}
+
+// QueryContext is Query with a context controlling cancellation and deadline.
+// The errors of the request and of the response report its req_id.
+func (c *client) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	reqID := requestID(ctx)
+	resp, err := c.query(WithReqID(ctx, reqID), q)
+	if resp != nil {
+		resp.ReqID = reqID
+	}
+	return resp, withReqID(err, reqID)
+}
+
+func (c *client) query(ctx context.Context, q Query) (*Response, error) {
+	ctx, q, cancel := prepareQuery(ctx, q, c.limitPolicy)
+	defer cancel()
+	req, err := c.createDefaultRequest(ctx, q)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -460,10 +1014,19 @@ func (c *client) Query(q Query) (*Response, error) {
 		return nil, err
 	}
 
+	body := &countingReader{r: resp.Body}
 	var response Response
-	dec := json.NewDecoder(resp.Body)
+	dec := json.NewDecoder(body)
 	dec.UseNumber()
 	decErr := dec.Decode(&response)
+	if c.verifyLen && decErr == nil {
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return nil, err
+		}
+		if err := c.checkContentLength(resp, body.n); err != nil {
+			return nil, err
+		}
+	}
 
 	// ignore this error if we got an invalid status code
 	if decErr != nil && decErr.Error() == "EOF" && resp.StatusCode != http.StatusOK {
@@ -479,17 +1042,50 @@ func (c *client) Query(q Query) (*Response, error) {
 	if resp.StatusCode != http.StatusOK && response.Error() == nil {
 		return &response, fmt.Errorf("received status code %d from server", resp.StatusCode)
 	}
+	if columns, err := parseColumnMeta(response.ColumnMeta); err == nil {
+		response.Columns = columns
+	}
+	truncateRows(&response, q.MaxRows)
 	return &response, nil
 }
 
+// checkContentLength verifies n bytes were read of a response with a known Content-Length.
+func (c *client) checkContentLength(resp *http.Response, n int64) error {
+	if !c.verifyLen || resp.ContentLength < 0 || resp.ContentLength == n {
+		return nil
+	}
+	return fmt.Errorf("%w: content length %d, read %d bytes", ErrContentLengthMismatch, resp.ContentLength, n)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// httpStatusError is an error response of the server, with the message unchanged.
+type httpStatusError struct {
+	code int
+	msg  string
+}
+
+func (e *httpStatusError) Error() string {
+	return redact(e.msg)
+}
+
 func checkResponse(resp *http.Response) error {
 	if resp.StatusCode >= http.StatusInternalServerError {
 		body, err := io.ReadAll(resp.Body)
 		if err != nil || len(body) == 0 {
-			return fmt.Errorf("received status code %d from downstream server", resp.StatusCode)
+			return &httpStatusError{code: resp.StatusCode, msg: fmt.Sprintf("received status code %d from downstream server", resp.StatusCode)}
 		}
 
-		return fmt.Errorf("received status code %d from downstream server, with response body: %q", resp.StatusCode, body)
+		return &httpStatusError{code: resp.StatusCode, msg: fmt.Sprintf("received status code %d from downstream server, with response body: %q", resp.StatusCode, body)}
 	}
 
 	// If we get an unexpected content type, then it is also not from influx direct and therefore
@@ -502,19 +1098,29 @@ func checkResponse(resp *http.Response) error {
 			return fmt.Errorf("expected json response, got empty body, with status: %v", resp.StatusCode)
 		}
 
-		return fmt.Errorf("expected json response, got %q, with status: %v and response body: %q", cType, resp.StatusCode, body)
+		return fmt.Errorf("expected json response, got %q, with status: %v and response body: %q", cType, resp.StatusCode, redact(string(body)))
 	}
 	return nil
 }
 
-func (c *client) createDefaultRequest(q Query) (*http.Request, error) {
-	u := c.url
+func (c *client) createDefaultRequest(ctx context.Context, q Query) (*http.Request, error) {
+	q = withContextOptions(ctx, q)
+	u := c.readURL
+	if q.MaxStaleness > 0 && len(c.replicas) > 0 {
+		u = c.replicas[int(c.next.Add(1))%len(c.replicas)]
+	}
 	u.Path = path.Join(u.Path, ExecuteSqlURL)
 	if len(q.Database) > 0 {
 		u.Path = path.Join(u.Path, q.Database)
 	}
+	command := labelQuery(ctx, q.Command)
+	if reqID := ReqIDFrom(ctx); reqID != 0 {
+		params := u.Query()
+		params.Set("req_id", strconv.FormatInt(reqID, 10))
+		u.RawQuery = params.Encode()
+	}
 
-	req, err := http.NewRequest("POST", u.String(), bytes.NewBufferString(q.Command))
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), bytes.NewBufferString(command))
 	if err != nil {
 		return nil, err
 	}
@@ -522,10 +1128,6 @@ func (c *client) createDefaultRequest(q Query) (*http.Request, error) {
 	req.Header.Set("Content-Type", "")
 	req.Header.Set("User-Agent", c.useragent)
 
-	if c.username != "" {
-		req.SetBasicAuth(c.username, c.password)
-	}
-
 	return req, nil
 
 }
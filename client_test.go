@@ -0,0 +1,59 @@
+package tsdbclient
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitBatch(t *testing.T) {
+	var lines []string
+	for i := 0; i < 10; i++ {
+		lines = append(lines, fmt.Sprintf("meters,location=l%d current=10 17000000000%02d", i, i))
+	}
+	points, err := ParsePoints([]byte(strings.Join(lines, "\n")), "ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lineSize := len(points[0].pt.PrecisionString("ms")) + 1
+
+	tests := []struct {
+		name        string
+		maxPoints   int
+		maxBodySize int
+		want        []int
+	}{
+		{"no limit", 0, 0, []int{10}},
+		{"under the points limit", 10, 0, []int{10}},
+		{"points limit", 4, 0, []int{4, 4, 2}},
+		{"one point a batch", 1, 0, []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
+		{"body size limit", 0, 3 * lineSize, []int{3, 3, 3, 1}},
+		{"body size under a point", 0, lineSize / 2, []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
+		{"under the body size limit", 0, 10 * lineSize, []int{10}},
+		{"both limits", 4, 3 * lineSize, []int{3, 3, 3, 1}},
+	}
+	for _, tt := range tests {
+		bp, _ := NewBatchPoints(BatchPointsConfig{Database: "power", Precision: "ms", TTL: 30})
+		bp.AddPoints(points)
+		c := &client{maxPoints: tt.maxPoints, maxBodySize: tt.maxBodySize}
+
+		parts := c.splitBatch(bp)
+		var got []int
+		next := 0
+		for _, part := range parts {
+			got = append(got, len(part.Points()))
+			if part.Database() != "power" || part.Precision() != "ms" || part.TTL() != 30 {
+				t.Errorf("%s: part config %+v, want the config of the batch", tt.name, configOf(part))
+			}
+			for _, p := range part.Points() {
+				if next < len(points) && p != points[next] {
+					t.Errorf("%s: point %d out of order", tt.name, next)
+				}
+				next++
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: parts of %v points, want %v", tt.name, got, tt.want)
+		}
+	}
+}
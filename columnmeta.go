@@ -0,0 +1,70 @@
+package tsdbclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/taosdata/driver-go/v3/common"
+)
+
+// ColumnMeta describes a column of a result.
+type ColumnMeta struct {
+	Name string
+	Type ColumnType
+	// Size is the size of the type in bytes, the length of VARCHAR and NCHAR.
+	Size int
+}
+
+// parseColumnMeta parses the column meta of a response, [column name, column type,
+// type size]. The type is a name, or a type code from servers before TDengine 3.
+func parseColumnMeta(meta [][]interface{}) ([]ColumnMeta, error) {
+	columns := make([]ColumnMeta, len(meta))
+	for i, c := range meta {
+		if len(c) != 3 {
+			return nil, errors.New("column meta data length no equal 3")
+		}
+		name, ok := c[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid column name: %v", c[0])
+		}
+		columns[i].Name = name
+
+		switch t := c[1].(type) {
+		case string:
+			columns[i].Type = ColumnType(t)
+		case json.Number:
+			code, err := strconv.Atoi(string(t))
+			if err != nil {
+				return nil, fmt.Errorf("invalid type of column %s: %v", name, t)
+			}
+			columns[i].Type = ColumnType(common.TypeNameMap[code])
+		case float64:
+			columns[i].Type = ColumnType(common.TypeNameMap[int(t)])
+		default:
+			return nil, fmt.Errorf("invalid type of column %s: %v", name, c[1])
+		}
+
+		switch s := c[2].(type) {
+		case json.Number:
+			size, err := strconv.Atoi(string(s))
+			if err != nil {
+				return nil, fmt.Errorf("invalid size of column %s: %v", name, s)
+			}
+			columns[i].Size = size
+		case float64:
+			columns[i].Size = int(s)
+		}
+	}
+	return columns, nil
+}
+
+// columnMetas returns the Columns of the response, parsed from its ColumnMeta if
+// they were not set.
+func (r *Response) columnMetas() ([]ColumnMeta, error) {
+	if r.Columns != nil || len(r.ColumnMeta) == 0 {
+		return r.Columns, nil
+	}
+	return parseColumnMeta(r.ColumnMeta)
+}
@@ -0,0 +1,128 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ColumnStat is the statistics of a numeric column over a time range.
+type ColumnStat struct {
+	Column string
+
+	// Rows is the number of rows of the range, Nulls those where the column is NULL.
+	Rows  int64
+	Nulls int64
+
+	Min    float64
+	Max    float64
+	Avg    float64
+	Stddev float64
+
+	// Valid is false when the column has no value in the range, the statistics are 0.
+	Valid bool
+}
+
+// columnStatsSQL returns the query of the statistics of the columns, one
+// count(*) then count, min, max, avg and stddev per column.
+func columnStatsSQL(table string, columns []string, r TimeRange, precision string) (string, error) {
+	if len(columns) == 0 {
+		return "", errors.New("invalid args: `columns` is empty")
+	}
+	from, err := quoteQualified(table)
+	if err != nil {
+		return "", err
+	}
+
+	selects := make([]string, 0, 1+5*len(columns))
+	selects = append(selects, "count(*)")
+	for _, c := range columns {
+		name, err := QuoteIdent(c)
+		if err != nil {
+			return "", err
+		}
+		for _, f := range []string{"count", "min", "max", "avg", "stddev"} {
+			selects = append(selects, f+"("+name+")")
+		}
+	}
+
+	sql := "select " + strings.Join(selects, ", ") + " from " + from
+	if !r.Start.IsZero() || !r.End.IsZero() {
+		if !r.Start.Before(r.End) {
+			return "", errors.New("invalid args: time range is empty")
+		}
+		start, err := timestampLiteral(r.Start, precision)
+		if err != nil {
+			return "", err
+		}
+		end, err := timestampLiteral(r.End, precision)
+		if err != nil {
+			return "", err
+		}
+		// the pseudo column of the primary timestamp can't be quoted
+		sql += fmt.Sprintf(" where _rowts >= %s and _rowts < %s", start, end)
+	}
+	return sql, nil
+}
+
+// ColumnStats returns the statistics of the numeric columns of the table, or
+// super table, over the range in one query. A zero range covers all rows.
+func (client *tsdbClient) ColumnStats(ctx context.Context, table string, columns []string, r TimeRange) ([]ColumnStat, error) {
+	if client.httpClient == nil || client.initialErr != nil {
+		return nil, fmt.Errorf("not created http client for tdengine: %v", client.initialErr)
+	}
+	sql, err := columnStatsSQL(table, columns, r, client.dbConfig.Precision)
+	if err != nil {
+		return nil, err
+	}
+	q := withContextOptions(ctx, NewQuery(sql, client.dbConfig.DBName, client.dbConfig.Precision))
+	resp, err := client.httpClient.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if err = resp.Error(); err != nil {
+		return nil, err
+	}
+
+	stats := make([]ColumnStat, len(columns))
+	for i, c := range columns {
+		stats[i].Column = c
+	}
+	if len(resp.Data) == 0 {
+		return stats, nil
+	}
+	row := resp.Data[0]
+	if len(row) != 1+5*len(columns) {
+		return nil, fmt.Errorf("column stats: row has %d values, expected %d", len(row), 1+5*len(columns))
+	}
+
+	rows, _, err := numericValue(row[0])
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		s := &stats[i]
+		values := row[1+5*i : 6+5*i]
+		count, _, err := numericValue(values[0])
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", s.Column, err)
+		}
+		s.Rows, s.Nulls = int64(rows), int64(rows-count)
+		if count == 0 {
+			continue
+		}
+		for j, dest := range []*float64{&s.Min, &s.Max, &s.Avg, &s.Stddev} {
+			if *dest, _, err = numericValue(values[1+j]); err != nil {
+				return nil, fmt.Errorf("column %s: %w", s.Column, err)
+			}
+		}
+		s.Valid = true
+	}
+	return stats, nil
+}
+
+// ColumnStats returns the statistics of the columns with the default client.
+func ColumnStats(ctx context.Context, table string, columns []string, r TimeRange) ([]ColumnStat, error) {
+	return clientWrapper.ColumnStats(ctx, table, columns, r)
+}
@@ -0,0 +1,117 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	tmqcommon "github.com/taosdata/driver-go/v3/common/tmq"
+)
+
+// Consumer is a consumer of topics polled by the caller, with control of its
+// offsets: a service may replay a vgroup from an offset saved before a crash
+// instead of starting at OffsetLatest. It is not safe for concurrent use.
+//
+//	c, err := client.NewConsumer([]string{"meters"}, NewSubscribeConfig(ConsumerGroup("export")))
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	_ = c.Seek(partition, savedOffset)
+//	for {
+//		msg, err := c.Poll(ctx)
+//		...
+//	}
+type Consumer struct {
+	consumer      taosConsumer
+	conf          SubscribeConfig
+	pollTimeoutMs int
+}
+
+// NewConsumer subscribes the topics with the config of the consumer, OnAssign,
+// OnRevoke, Reconnect and Status are not used.
+func (client *tsdbClient) NewConsumer(topics []string, conf SubscribeConfig) (*Consumer, error) {
+	if len(topics) == 0 {
+		return nil, errors.New("invalid args: topics is empty")
+	}
+	creds := client.creds.load()
+	tsdbCons, err := newConsumer(client.dbConfig.DBAddr, creds.user, creds.password, strings.Join(topics, "_"), conf)
+	if err != nil {
+		return nil, err
+	}
+	if err = tsdbCons.SubscribeTopics(topics, nil); err != nil {
+		_ = tsdbCons.Close()
+		return nil, err
+	}
+	return &Consumer{consumer: tsdbCons, conf: conf, pollTimeoutMs: conf.pollTimeoutMs()}, nil
+}
+
+// NewConsumer subscribes the topics with the default client.
+func NewConsumer(topics []string, conf SubscribeConfig) (*Consumer, error) {
+	return clientWrapper.NewConsumer(topics, conf)
+}
+
+// Poll returns the next message accepted by the Filter of the config, waiting
+// until one arrives or ctx is done. With ManualCommit it is a CommittableMessage.
+func (c *Consumer) Poll(ctx context.Context) (TSDBSubscribedMessage, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		switch e := c.consumer.Poll(c.pollTimeoutMs).(type) {
+		case nil:
+		case TSDBSubscribedMessage:
+			if c.conf.Filter != nil && !c.conf.Filter(e) {
+				continue
+			}
+			if c.conf.ManualCommit {
+				return newCommittableMessage(c.consumer, e, commitOffset(c.consumer))
+			}
+			return e, nil
+		case error:
+			return nil, e
+		default:
+			return nil, fmt.Errorf("unexpected event %T", e)
+		}
+	}
+}
+
+// Assignment returns the vgroups of the topics assigned to the consumer, with
+// their current offsets.
+func (c *Consumer) Assignment() ([]tmqcommon.TopicPartition, error) {
+	return c.consumer.Assignment()
+}
+
+// Seek moves the consumer in the vgroup of partition to offset, the next polls
+// read the vgroup from it. The partition is one of Assignment.
+func (c *Consumer) Seek(partition tmqcommon.TopicPartition, offset tmqcommon.Offset) error {
+	if partition.Topic == nil {
+		return errors.New("invalid args: partition has no topic")
+	}
+	partition.Offset = offset
+	return c.consumer.Seek(partition, 0)
+}
+
+// Committed returns the offsets committed by the consumer group for the
+// partitions, those of Assignment if none are given.
+func (c *Consumer) Committed(partitions ...tmqcommon.TopicPartition) ([]tmqcommon.TopicPartition, error) {
+	if len(partitions) == 0 {
+		var err error
+		if partitions, err = c.consumer.Assignment(); err != nil {
+			return nil, err
+		}
+	}
+	return c.consumer.Committed(partitions, 0)
+}
+
+// Commit commits the offsets of the messages polled.
+func (c *Consumer) Commit() error {
+	_, err := c.consumer.Commit()
+	return err
+}
+
+// Close unsubscribes the topics and closes the consumer.
+func (c *Consumer) Close() error {
+	return errors.Join(c.consumer.Unsubscribe(), c.consumer.Close())
+}
@@ -0,0 +1,80 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// credentials are the user and password of a client, shared by its transports
+// and derived clients. They are replaced as a whole, so a request never pairs the
+// user with a stale password, and the connections opened with the previous pair
+// can tell they must reconnect.
+type credentials struct {
+	current  atomic.Pointer[userPassword]
+	changing sync.Mutex
+}
+
+type userPassword struct {
+	user     string
+	password string
+}
+
+func newCredentials(user, password string) *credentials {
+	c := &credentials{}
+	c.store(user, password)
+	return c
+}
+
+func (c *credentials) load() *userPassword {
+	return c.current.Load()
+}
+
+func (c *credentials) store(user, password string) {
+	c.current.Store(&userPassword{user: user, password: password})
+}
+
+// ChangePassword sets the password of user on the server. When user is the user
+// of the client, the credentials of the client and of its derived clients are
+// swapped once the server accepted it: the requests after it use the new password
+// and the connections opened with the old one reconnect on their next use.
+func (client *tsdbClient) ChangePassword(ctx context.Context, user, newPass string) error {
+	if len(user) == 0 {
+		return errors.New("invalid args: `user` is empty")
+	}
+	for i := 0; i < len(user); i++ {
+		if !isParamChar(user[i]) {
+			return fmt.Errorf("invalid args: `user` %q", user)
+		}
+	}
+	if len(newPass) == 0 {
+		return errors.New("invalid args: `newPass` is empty")
+	}
+	if client.httpClient == nil || client.initialErr != nil {
+		return fmt.Errorf("not created http client for tdengine: %v", client.initialErr)
+	}
+
+	creds := client.creds
+	creds.changing.Lock()
+	defer creds.changing.Unlock()
+
+	resp, err := client.httpClient.QueryContext(ctx, Query{Command: "alter user " + user + " pass " + QuoteString(newPass), NoCache: true})
+	if err != nil {
+		return redactError(err, newPass)
+	}
+	if err := resp.Error(); err != nil {
+		return redactError(err, newPass)
+	}
+
+	if creds.load().user == user {
+		creds.store(user, newPass)
+	}
+	return nil
+}
+
+// ChangePassword sets the password of user with the default client.
+func ChangePassword(ctx context.Context, user, newPass string) error {
+	return clientWrapper.ChangePassword(ctx, user, newPass)
+}
@@ -0,0 +1,105 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const defaultDashboardConcurrency = 8
+
+// ResultSet is the result of one query of FetchDashboard.
+type ResultSet struct {
+	Columns []string
+	Rows    [][]interface{}
+
+	// Err is the error of the query, the other fields are empty if set.
+	Err error
+}
+
+// FetchDashboard runs the named queries concurrently, at most DashboardConcurrency at
+// once, and returns their results by name. Queries without database run in the client
+// database. The error joins the errors of the failed queries, whose ResultSet carries
+// its own error, the results of the other queries are returned nevertheless.
+func (client *tsdbClient) FetchDashboard(ctx context.Context, queries map[string]Query) (map[string]ResultSet, error) {
+	if client.httpClient == nil || client.initialErr != nil {
+		return nil, fmt.Errorf("not created http client for tdengine: %v", client.initialErr)
+	}
+
+	limit := client.dashboardConcurrency
+	if limit <= 0 {
+		limit = defaultDashboardConcurrency
+	}
+
+	var (
+		lock    sync.Mutex
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, limit)
+		results = make(map[string]ResultSet, len(queries))
+	)
+	for name, q := range queries {
+		if len(q.Database) == 0 {
+			q.Database = client.dbConfig.DBName
+		}
+		if len(q.Precision) == 0 {
+			q.Precision = client.dbConfig.Precision
+		}
+
+		wg.Add(1)
+		go func(name string, q Query) {
+			defer wg.Done()
+			var rs ResultSet
+			select {
+			case sem <- struct{}{}:
+				rs = client.fetch(ctx, q)
+				<-sem
+			case <-ctx.Done():
+				rs.Err = ctx.Err()
+			}
+			lock.Lock()
+			results[name] = rs
+			lock.Unlock()
+		}(name, q)
+	}
+	wg.Wait()
+
+	names := make([]string, 0, len(results))
+	for name, rs := range results {
+		if rs.Err != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	errs := make([]error, len(names))
+	for i, name := range names {
+		errs[i] = fmt.Errorf("%s: %w", name, results[name].Err)
+	}
+	return results, errors.Join(errs...)
+}
+
+func (client *tsdbClient) fetch(ctx context.Context, q Query) ResultSet {
+	resp, err := client.httpClient.QueryContext(ctx, q)
+	if err == nil {
+		err = resp.Error()
+	}
+	if err != nil {
+		return ResultSet{Err: err}
+	}
+
+	columns, err := resp.columnMetas()
+	if err != nil {
+		return ResultSet{Err: err}
+	}
+	rs := ResultSet{Rows: resp.Data}
+	for _, c := range columns {
+		rs.Columns = append(rs.Columns, c.Name)
+	}
+	return rs
+}
+
+// FetchDashboard runs the named queries concurrently with the default client.
+func FetchDashboard(ctx context.Context, queries map[string]Query) (map[string]ResultSet, error) {
+	return clientWrapper.FetchDashboard(ctx, queries)
+}
@@ -0,0 +1,124 @@
+package tsdbclient
+
+import (
+	"container/list"
+	"runtime"
+	"sync"
+)
+
+const defaultDatabaseCacheSize = 16
+
+// databaseCache keeps the clients derived for other databases in a bounded LRU.
+type databaseCache struct {
+	size int
+
+	lock  sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+}
+
+type databaseCacheEntry struct {
+	key    string
+	client *tsdbClient
+}
+
+func newDatabaseCache(size int) *databaseCache {
+	if size <= 0 {
+		size = defaultDatabaseCacheSize
+	}
+	return &databaseCache{
+		size:  size,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// ForDatabase returns a client of the same server for the database db. The clients
+// are cached and share the connections of this client, closing them only releases
+// their own resources. A client evicted from the cache keeps working for those
+// holding it.
+func (client *tsdbClient) ForDatabase(db string) TSDBClient {
+	if db == client.dbConfig.DBName {
+		return client
+	}
+	if client.parent != nil {
+		return client.parent.ForDatabase(db)
+	}
+	c := client.databases
+	key := client.dbConfig.DBAddr + "/" + db
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*databaseCacheEntry).client
+	}
+
+	derived := client.derive(db)
+	c.items[key] = c.order.PushFront(&databaseCacheEntry{key: key, client: derived})
+	if c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.items, e.Value.(*databaseCacheEntry).key)
+		// the evicted client may still be used by those it was returned to, its
+		// stmt connection is closed once it is no longer referenced
+		runtime.SetFinalizer(e.Value.(*databaseCacheEntry).client, (*tsdbClient).closeStmtWriter)
+	}
+	return derived
+}
+
+// derive returns a client of the database db sharing the http client and metrics.
+func (client *tsdbClient) derive(db string) *tsdbClient {
+	d := &tsdbClient{
+		httpClient:         client.httpClient,
+		initialErr:         client.initialErr,
+		creds:              client.creds,
+		defaultNumberValue: client.defaultNumberValue,
+		writeBackend:       client.writeBackend,
+		missingTimestamp:   client.missingTimestamp,
+		timestampBounds:    client.timestampBounds,
+		timestampFormat:    client.timestampFormat,
+		timestampLocation:  client.timestampLocation,
+		arrayExplodes:      client.arrayExplodes,
+		flattenSeparator:   client.flattenSeparator,
+		writeTTL:           client.writeTTL,
+		tableNameKey:       client.tableNameKey,
+		schemas:            client.schemas,
+		rejected:           client.rejected,
+		clock:              client.clock,
+		spool:              client.spool,
+		queryCache:         client.queryCache,
+		logger:             client.logger,
+		auditor:            client.auditor,
+		autoCreateTables:   client.autoCreateTables,
+		metrics:            client.metrics,
+		parent:             client,
+
+		dashboardConcurrency: client.dashboardConcurrency,
+	}
+	d.dbConfig = client.dbConfig
+	d.dbConfig.DBName = db
+	if client.keepFilter != nil {
+		d.keepFilter = &keepFilter{onDrop: client.keepFilter.onDrop}
+	}
+	if client.dedup != nil {
+		d.dedup = newPointDeduplicator(client.dedup.window, client.dedup.size)
+	}
+	return d
+}
+
+// close releases the resources of the cached clients.
+func (c *databaseCache) close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for e := c.order.Front(); e != nil; e = e.Next() {
+		e.Value.(*databaseCacheEntry).client.closeStmtWriter()
+	}
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// ForDatabase returns a client of the default client server for the database db.
+func ForDatabase(db string) TSDBClient {
+	return clientWrapper.ForDatabase(db)
+}
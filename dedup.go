@@ -0,0 +1,108 @@
+package tsdbclient
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultDedupSize = 100000
+
+// pointDeduplicator remembers the (series, timestamp) of written points in a
+// bounded LRU, so points seen again within the window can be dropped.
+type pointDeduplicator struct {
+	window time.Duration
+	size   int
+
+	lock  sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+}
+
+type dedupEntry struct {
+	key    string
+	seenAt time.Time
+}
+
+func newPointDeduplicator(window time.Duration, size int) *pointDeduplicator {
+	if size <= 0 {
+		size = defaultDedupSize
+	}
+	return &pointDeduplicator{
+		window: window,
+		size:   size,
+		items:  make(map[string]*list.Element),
+		order:  list.New(),
+	}
+}
+
+func dedupKey(p *DataPoint) string {
+	return string(p.pt.Key()) + "@" + strconv.FormatInt(p.pt.UnixNano(), 10)
+}
+
+// filter returns a batch without the points already seen within the window
+// and without duplicates inside the batch itself.
+func (d *pointDeduplicator) filter(bp BatchPoints) BatchPoints {
+	out, _ := NewBatchPoints(configOf(bp))
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	now := time.Now()
+	d.expire(now)
+
+	batch := make(map[string]struct{})
+	for _, p := range bp.Points() {
+		if p == nil {
+			continue
+		}
+		key := dedupKey(p)
+		if _, ok := batch[key]; ok {
+			continue
+		}
+		if e, ok := d.items[key]; ok && now.Sub(e.Value.(*dedupEntry).seenAt) < d.window {
+			continue
+		}
+		batch[key] = struct{}{}
+		out.AddPoint(p)
+	}
+	return out
+}
+
+// mark records the points of a successfully written batch.
+func (d *pointDeduplicator) mark(bp BatchPoints) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	now := time.Now()
+	for _, p := range bp.Points() {
+		if p == nil {
+			continue
+		}
+		key := dedupKey(p)
+		if e, ok := d.items[key]; ok {
+			e.Value.(*dedupEntry).seenAt = now
+			d.order.MoveToFront(e)
+			continue
+		}
+		d.items[key] = d.order.PushFront(&dedupEntry{key: key, seenAt: now})
+		if d.order.Len() > d.size {
+			d.remove(d.order.Back())
+		}
+	}
+}
+
+func (d *pointDeduplicator) expire(now time.Time) {
+	for e := d.order.Back(); e != nil; e = d.order.Back() {
+		if now.Sub(e.Value.(*dedupEntry).seenAt) < d.window {
+			return
+		}
+		d.remove(e)
+	}
+}
+
+func (d *pointDeduplicator) remove(e *list.Element) {
+	d.order.Remove(e)
+	delete(d.items, e.Value.(*dedupEntry).key)
+}
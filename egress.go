@@ -0,0 +1,75 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ErrEgressDenied is returned by the DialControl of AllowNetworks for addresses
+// outside of the allowed networks.
+var ErrEgressDenied = errors.New("connection to address not allowed")
+
+// DialControl is called with the resolved address before each connection of the
+// HTTP client is made, an error aborts the connection. See net.Dialer.Control.
+type DialControl func(network, address string, c syscall.RawConn) error
+
+// AllowNetworks returns a DialControl allowing only connections to the networks,
+// given in CIDR notation, e.g. "10.0.0.0/8".
+func AllowNetworks(cidrs ...string) (DialControl, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid args: %v", err)
+		}
+		networks = append(networks, n)
+	}
+
+	return func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return fmt.Errorf("%w: %s", ErrEgressDenied, address)
+		}
+		for _, n := range networks {
+			if n.Contains(ip) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrEgressDenied, address)
+	}, nil
+}
+
+// dialContext returns the dial function of the transport, applying the control
+// hook and logging the address each host resolved to when logDials is set.
+func dialContext(control DialControl, keepAlive time.Duration, logDials bool, logger Logger) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if keepAlive == 0 {
+		keepAlive = 30 * time.Second
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: keepAlive,
+		Control:   control,
+	}
+	if logDials && logger == nil {
+		logger = defaultLogger()
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if logDials {
+			if err != nil {
+				logger.Warn("dial failed", "network", network, "addr", addr, "error", err)
+			} else {
+				logger.Info("dial", "network", network, "addr", addr, "remote", conn.RemoteAddr().String())
+			}
+		}
+		return conn, err
+	}
+}
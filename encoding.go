@@ -0,0 +1,165 @@
+package tsdbclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// ZstdEncoding and SnappyEncoding compress writes for servers or proxies
+	// accepting them. Snappy bodies are in the block format, as sent by
+	// Prometheus remote write, not the framing format.
+	ZstdEncoding   ContentEncoding = "zstd"
+	SnappyEncoding ContentEncoding = "snappy"
+)
+
+// GzipNoCompression is the GzipLevel of gzip.NoCompression, as a GzipLevel of 0
+// is the default level.
+const GzipNoCompression = gzip.HuffmanOnly - 1
+
+// EncoderFunc returns a writer compressing into w, closing it flushes the data.
+type EncoderFunc func(w io.Writer) (io.WriteCloser, error)
+
+var (
+	encodersLock sync.RWMutex
+	encoders     = map[ContentEncoding]EncoderFunc{}
+)
+
+// RegisterEncoder sets the encoder of the content encoding for write requests.
+// Encoders registered for gzip, zstd or snappy replace the built-in ones.
+func RegisterEncoder(encoding ContentEncoding, fn EncoderFunc) {
+	encodersLock.Lock()
+	defer encodersLock.Unlock()
+	encoders[encoding] = fn
+}
+
+func registeredEncoder(encoding ContentEncoding) (EncoderFunc, bool) {
+	encodersLock.RLock()
+	defer encodersLock.RUnlock()
+	fn, ok := encoders[encoding]
+	return fn, ok
+}
+
+// checkEncoding validates the encoding and the gzip level of the config.
+func checkEncoding(encoding ContentEncoding, gzipLevel int) error {
+	if gzipLevel != 0 && gzipLevel != GzipNoCompression && (gzipLevel < gzip.HuffmanOnly || gzipLevel > gzip.BestCompression) {
+		return fmt.Errorf("invalid gzip level %d", gzipLevel)
+	}
+	switch encoding {
+	case DefaultEncoding, GzipEncoding, ZstdEncoding, SnappyEncoding:
+		return nil
+	}
+	if _, ok := registeredEncoder(encoding); !ok {
+		return fmt.Errorf("unsupported encoding %s", encoding)
+	}
+	return nil
+}
+
+// gzipPools keeps the gzip writers per compression level, their allocation
+// dominates the cost of compressing small batches.
+var gzipPools sync.Map
+
+func gzipPool(level int) *sync.Pool {
+	if p, ok := gzipPools.Load(level); ok {
+		return p.(*sync.Pool)
+	}
+	p, _ := gzipPools.LoadOrStore(level, &sync.Pool{})
+	return p.(*sync.Pool)
+}
+
+// pooledGzipWriter returns its gzip writer to the pool once closed.
+type pooledGzipWriter struct {
+	*gzip.Writer
+	pool *sync.Pool
+}
+
+func (w *pooledGzipWriter) Close() error {
+	err := w.Writer.Close()
+	w.pool.Put(w.Writer)
+	w.Writer = nil
+	return err
+}
+
+// pooledZstdWriter returns its zstd encoder to the pool once closed.
+type pooledZstdWriter struct {
+	*zstd.Encoder
+}
+
+var zstdPool sync.Pool
+
+func (w *pooledZstdWriter) Close() error {
+	err := w.Encoder.Close()
+	zstdPool.Put(w.Encoder)
+	w.Encoder = nil
+	return err
+}
+
+func newZstdWriter(w io.Writer) (io.WriteCloser, error) {
+	if enc, ok := zstdPool.Get().(*zstd.Encoder); ok {
+		enc.Reset(w)
+		return &pooledZstdWriter{Encoder: enc}, nil
+	}
+	// the bodies are small, compressed by the goroutine of the request
+	enc, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &pooledZstdWriter{Encoder: enc}, nil
+}
+
+// snappyWriter compresses the body as one snappy block once closed.
+type snappyWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (w *snappyWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *snappyWriter) Close() error {
+	_, err := w.w.Write(snappy.Encode(nil, w.buf.Bytes()))
+	return err
+}
+
+// newEncoder returns the writer compressing the write body into w, nil for no encoding.
+func newEncoder(encoding ContentEncoding, gzipLevel int, w io.Writer) (io.WriteCloser, error) {
+	if encoding == DefaultEncoding {
+		return nil, nil
+	}
+	if fn, ok := registeredEncoder(encoding); ok {
+		return fn(w)
+	}
+	switch encoding {
+	case GzipEncoding:
+	case ZstdEncoding:
+		return newZstdWriter(w)
+	case SnappyEncoding:
+		return &snappyWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %s", encoding)
+	}
+
+	switch gzipLevel {
+	case 0:
+		gzipLevel = gzip.DefaultCompression
+	case GzipNoCompression:
+		gzipLevel = gzip.NoCompression
+	}
+	pool := gzipPool(gzipLevel)
+	if gw, ok := pool.Get().(*gzip.Writer); ok {
+		gw.Reset(w)
+		return &pooledGzipWriter{Writer: gw, pool: pool}, nil
+	}
+	gw, err := gzip.NewWriterLevel(w, gzipLevel)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledGzipWriter{Writer: gw, pool: pool}, nil
+}
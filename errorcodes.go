@@ -0,0 +1,35 @@
+package tsdbclient
+
+import "sync"
+
+// errorCodes maps the server error codes to the errors returned for them by
+// Response.Error, in place of the error message of the server.
+var errorCodes = struct {
+	sync.RWMutex
+	m map[int]error
+}{m: map[int]error{
+	0x0603: ErrNotExistsTable, // TSDB_CODE_TDB_TABLE_NOT_EXIST
+	0x2603: ErrNotExistsTable, // TSDB_CODE_PAR_TABLE_NOT_EXIST of 3.0
+	0x2616: ErrNotExistsTable, // TSDB_CODE_PAR_DB_NOT_SPECIFIED
+	0x2662: ErrNotExistsTable, // TSDB_CODE_PAR_TABLE_NOT_EXIST
+}}
+
+// RegisterErrorCode maps the server error code to err, e.g. ErrNotExistsTable for
+// the code of a missing table on a server version not known by default. A nil err
+// removes the mapping.
+func RegisterErrorCode(code int, err error) {
+	errorCodes.Lock()
+	defer errorCodes.Unlock()
+	if err == nil {
+		delete(errorCodes.m, code)
+		return
+	}
+	errorCodes.m[code] = err
+}
+
+// errorForCode returns the error registered for the server error code, or nil.
+func errorForCode(code int) error {
+	errorCodes.RLock()
+	defer errorCodes.RUnlock()
+	return errorCodes.m[code]
+}
@@ -0,0 +1,117 @@
+package tsdbclient
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// ExplodeMode selects how ExplodeArrayField expands an array field.
+type ExplodeMode int8
+
+const (
+	_ ExplodeMode = iota
+	// ExplodeIndexedFields replaces the array with a field per element, named
+	// by the prefix and the index: ch_0, ch_1...
+	ExplodeIndexedFields
+	// ExplodeChannelPoints writes a point per element, with the index in a
+	// channel tag and the element in a field of the prefix name. The other fields
+	// of the point are written with each of them.
+	ExplodeChannelPoints
+)
+
+// ArrayExplode describes the expansion of an array field, see ExplodeArrayField.
+type ArrayExplode struct {
+	// Field is the array field.
+	Field string
+
+	// Mode selects indexed fields or a point per element, defaults to ExplodeIndexedFields.
+	Mode ExplodeMode
+
+	// Prefix names the fields written, Field if empty.
+	Prefix string
+
+	// Tag is the channel tag of ExplodeChannelPoints, "channel" if empty.
+	Tag string
+}
+
+// explodedPoint is the tags and fields of a point after expansion of its arrays.
+type explodedPoint struct {
+	tags   map[string]string
+	fields map[string]interface{}
+}
+
+// explodeArrays applies the expansions of a measurement to a point, the fields
+// which are not slices or arrays are left unchanged.
+func explodeArrays(explodes []ArrayExplode, tags map[string]string, fields map[string]interface{}) []explodedPoint {
+	points := []explodedPoint{{tags: tags, fields: fields}}
+	for _, e := range explodes {
+		var out []explodedPoint
+		for _, p := range points {
+			out = append(out, e.apply(p)...)
+		}
+		points = out
+	}
+	return points
+}
+
+func (e ArrayExplode) apply(p explodedPoint) []explodedPoint {
+	v := reflect.ValueOf(p.fields[e.Field])
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		return []explodedPoint{p}
+	}
+	prefix := e.Prefix
+	if len(prefix) == 0 {
+		prefix = e.Field
+	}
+
+	rest := make(map[string]interface{}, len(p.fields))
+	for k, f := range p.fields {
+		if k != e.Field {
+			rest[k] = f
+		}
+	}
+	if e.Mode != ExplodeChannelPoints {
+		for i := 0; i < v.Len(); i++ {
+			rest[prefix+"_"+strconv.Itoa(i)] = v.Index(i).Interface()
+		}
+		return []explodedPoint{{tags: p.tags, fields: rest}}
+	}
+
+	tag := e.Tag
+	if len(tag) == 0 {
+		tag = "channel"
+	}
+	points := make([]explodedPoint, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		tags := make(map[string]string, len(p.tags)+1)
+		for k, t := range p.tags {
+			tags[k] = t
+		}
+		tags[tag] = strconv.Itoa(i)
+		fields := make(map[string]interface{}, len(rest)+1)
+		for k, f := range rest {
+			fields[k] = f
+		}
+		fields[prefix] = v.Index(i).Interface()
+		points = append(points, explodedPoint{tags: tags, fields: fields})
+	}
+	return points
+}
+
+// addPoints adds the points of a measurement to the batch, flattening its nested
+// fields and expanding its array fields as configured. A zero t leaves the
+// timestamp to the server.
+func (client *tsdbClient) addPoints(bps BatchPoints, name string, tags map[string]string, fields map[string]interface{}, t time.Time) error {
+	if len(client.flattenSeparator) > 0 {
+		fields = flattenFields(fields, client.flattenSeparator)
+	}
+	for _, p := range explodeArrays(client.arrayExplodes[name], tags, fields) {
+		pt, err := NewDataPoint(name, p.tags, p.fields, t)
+		if err != nil {
+			return err
+		}
+		bps.AddPoint(pt)
+	}
+	return nil
+}
@@ -0,0 +1,358 @@
+package tsdbclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Derivation computes a derived column from the other columns of a row,
+// parsed from "name = expression", e.g. "power = voltage * current".
+// Expressions support numbers, column names (backquoted if needed),
+// + - * / %, parentheses and the functions abs, sqrt, min, max, pow.
+// An operand that is NULL or not numeric makes the result NULL.
+type Derivation struct {
+	Name string
+	expr exprNode
+}
+
+// ParseDerivation parses a "name = expression" derivation.
+func ParseDerivation(s string) (*Derivation, error) {
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid derivation %q: missing `=`", s)
+	}
+	name := strings.Trim(strings.TrimSpace(s[:i]), "`")
+	if len(name) == 0 {
+		return nil, fmt.Errorf("invalid derivation %q: empty name", s)
+	}
+
+	p := &exprParser{src: s[i+1:]}
+	p.next()
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid derivation %q: %v", s, err)
+	}
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("invalid derivation %q: unexpected %q", s, p.tok.text)
+	}
+	return &Derivation{Name: name, expr: expr}, nil
+}
+
+// Apply sets the derived column of the row, nil if an operand is missing.
+func (d *Derivation) Apply(row map[string]interface{}) {
+	if v, ok := d.expr.eval(row); ok {
+		row[d.Name] = v
+	} else {
+		row[d.Name] = nil
+	}
+}
+
+// ApplyDerivations applies the derivations in order to every row,
+// so later derivations may use the columns of earlier ones.
+func ApplyDerivations(rows []map[string]interface{}, derivations ...*Derivation) {
+	for _, row := range rows {
+		for _, d := range derivations {
+			d.Apply(row)
+		}
+	}
+}
+
+type exprNode interface {
+	eval(row map[string]interface{}) (float64, bool)
+}
+
+type numberNode float64
+
+func (n numberNode) eval(map[string]interface{}) (float64, bool) {
+	return float64(n), true
+}
+
+type columnNode string
+
+func (c columnNode) eval(row map[string]interface{}) (float64, bool) {
+	return toFloat(row[string(c)])
+}
+
+type unaryNode struct {
+	x exprNode
+}
+
+func (u unaryNode) eval(row map[string]interface{}) (float64, bool) {
+	v, ok := u.x.eval(row)
+	return -v, ok
+}
+
+type binaryNode struct {
+	op   byte
+	l, r exprNode
+}
+
+func (b binaryNode) eval(row map[string]interface{}) (float64, bool) {
+	l, ok := b.l.eval(row)
+	if !ok {
+		return 0, false
+	}
+	r, ok := b.r.eval(row)
+	if !ok {
+		return 0, false
+	}
+	switch b.op {
+	case '+':
+		return l + r, true
+	case '-':
+		return l - r, true
+	case '*':
+		return l * r, true
+	case '/':
+		if r == 0 {
+			return 0, false
+		}
+		return l / r, true
+	case '%':
+		if r == 0 {
+			return 0, false
+		}
+		return math.Mod(l, r), true
+	}
+	return 0, false
+}
+
+type callNode struct {
+	name string
+	args []exprNode
+}
+
+var exprFuncs = map[string]struct {
+	arity int
+	fn    func(args []float64) float64
+}{
+	"abs":  {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt": {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"min":  {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":  {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	"pow":  {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+}
+
+func (c callNode) eval(row map[string]interface{}) (float64, bool) {
+	args := make([]float64, len(c.args))
+	for i, a := range c.args {
+		v, ok := a.eval(row)
+		if !ok {
+			return 0, false
+		}
+		args[i] = v
+	}
+	return exprFuncs[c.name].fn(args), true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int8:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOp
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string
+}
+
+type exprParser struct {
+	src string
+	pos int
+	tok exprToken
+	err error
+}
+
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		p.tok = exprToken{kind: tokEOF}
+		return
+	}
+
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.' ||
+			p.src[p.pos] == 'e' || p.src[p.pos] == 'E' ||
+			(p.src[p.pos] == '-' || p.src[p.pos] == '+') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E')) {
+			p.pos++
+		}
+		p.tok = exprToken{kind: tokNumber, text: p.src[start:p.pos]}
+	case c == '`':
+		end := strings.IndexByte(p.src[p.pos+1:], '`')
+		if end < 0 {
+			p.err = fmt.Errorf("unterminated `")
+			p.tok = exprToken{kind: tokEOF}
+			return
+		}
+		p.pos += end + 2
+		p.tok = exprToken{kind: tokIdent, text: p.src[start+1 : p.pos-1]}
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || p.src[p.pos] == '.' ||
+			unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+		p.tok = exprToken{kind: tokIdent, text: p.src[start:p.pos]}
+	default:
+		p.pos++
+		p.tok = exprToken{kind: tokOp, text: string(c)}
+	}
+}
+
+func (p *exprParser) isOp(ops string) bool {
+	return p.tok.kind == tokOp && strings.Contains(ops, p.tok.text)
+}
+
+// parseExpr parses sums of terms.
+func (p *exprParser) parseExpr() (exprNode, error) {
+	l, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+-") {
+		op := p.tok.text[0]
+		p.next()
+		r, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		l = binaryNode{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+// parseTerm parses products of factors.
+func (p *exprParser) parseTerm() (exprNode, error) {
+	l, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*/%") {
+		op := p.tok.text[0]
+		p.next()
+		r, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		l = binaryNode{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) parseFactor() (exprNode, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	switch tok := p.tok; {
+	case tok.kind == tokNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok.text)
+		}
+		p.next()
+		return numberNode(f), nil
+	case tok.kind == tokIdent:
+		p.next()
+		if !p.isOp("(") {
+			return columnNode(tok.text), nil
+		}
+		name := strings.ToLower(tok.text)
+		f, ok := exprFuncs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %q", tok.text)
+		}
+		p.next()
+		var args []exprNode
+		for !p.isOp(")") {
+			if len(args) > 0 {
+				if !p.isOp(",") {
+					return nil, fmt.Errorf("expected `,` in call of %s", name)
+				}
+				p.next()
+			}
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		p.next()
+		if len(args) != f.arity {
+			return nil, fmt.Errorf("%s expects %d arguments, got %d", name, f.arity, len(args))
+		}
+		return callNode{name: name, args: args}, nil
+	case p.isOp("-"):
+		p.next()
+		x, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{x: x}, nil
+	case p.isOp("+"):
+		p.next()
+		return p.parseFactor()
+	case p.isOp("("):
+		p.next()
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, fmt.Errorf("expected `)`")
+		}
+		p.next()
+		return x, nil
+	case tok.kind == tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	default:
+		return nil, fmt.Errorf("unexpected %q", tok.text)
+	}
+}
@@ -0,0 +1,45 @@
+package tsdbclient
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// flattenFields returns fields with the nested maps replaced by their leaves,
+// named by the keys of the path joined with sep, e.g. {"env": {"temp": 21}} gives
+// env_temp with sep "_". The values are normalized: json.Number to int64 or
+// float64, time.Time to an RFC3339 string, nil dropped.
+func flattenFields(fields map[string]interface{}, sep string) map[string]interface{} {
+	out := make(map[string]interface{}, len(fields))
+	flattenInto(out, "", fields, sep)
+	return out
+}
+
+func flattenInto(out map[string]interface{}, prefix string, fields map[string]interface{}, sep string) {
+	for k, v := range fields {
+		if len(prefix) > 0 {
+			k = prefix + sep + k
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			flattenInto(out, k, v, sep)
+		case map[string]string:
+			for sk, sv := range v {
+				out[k+sep+sk] = sv
+			}
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				out[k] = n
+			} else if f, err := v.Float64(); err == nil {
+				out[k] = f
+			} else {
+				out[k] = v.String()
+			}
+		case time.Time:
+			out[k] = v.Format(time.RFC3339Nano)
+		case nil:
+		default:
+			out[k] = v
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is matched by errors.Is for every QuotaExceededError.
+var ErrQuotaExceeded = errors.New("query quota exceeded")
+
+// QuotaExceededError reports which quota of a caller a query exceeded.
+type QuotaExceededError struct {
+	Caller string
+	Quota  string
+	Limit  interface{}
+	Actual interface{}
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("query quota exceeded for caller %q: %s limit %v, got %v", e.Caller, e.Quota, e.Limit, e.Actual)
+}
+
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// QueryQuota limits the queries of one caller, zero values are unlimited.
+type QueryQuota struct {
+	// MaxRows is the maximum number of rows a query may return.
+	MaxRows int
+
+	// MaxConcurrent is the maximum number of queries running at once.
+	MaxConcurrent int
+
+	// MaxTimeRange is the maximum time range between the bounds on the timestamp
+	// column in the where clause, a query without lower bound exceeds it.
+	MaxTimeRange time.Duration
+}
+
+type queryCallerKey struct{}
+
+// WithQueryCaller marks the queries issued with the returned context as made by caller.
+func WithQueryCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, queryCallerKey{}, caller)
+}
+
+// QueryCallerFrom returns the caller set by WithQueryCaller, empty if none.
+func QueryCallerFrom(ctx context.Context) string {
+	caller, _ := ctx.Value(queryCallerKey{}).(string)
+	return caller
+}
+
+// QueryGovernor enforces per-caller query quotas on the client side.
+type QueryGovernor struct {
+	// TimestampColumns are the column names checked for MaxTimeRange,
+	// defaults to "ts" and "_ts".
+	TimestampColumns []string
+
+	// Precision of integer timestamp literals, defaults to "ms".
+	Precision string
+
+	lock    sync.Mutex
+	def     QueryQuota
+	quotas  map[string]QueryQuota
+	running map[string]int
+}
+
+// NewQueryGovernor returns a governor applying def to callers without their own quota.
+func NewQueryGovernor(def QueryQuota) *QueryGovernor {
+	return &QueryGovernor{
+		def:     def,
+		quotas:  make(map[string]QueryQuota),
+		running: make(map[string]int),
+	}
+}
+
+// SetQuota sets the quota of a caller.
+func (g *QueryGovernor) SetQuota(caller string, q QueryQuota) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.quotas[caller] = q
+}
+
+func (g *QueryGovernor) quota(caller string) QueryQuota {
+	if q, ok := g.quotas[caller]; ok {
+		return q
+	}
+	return g.def
+}
+
+// acquire checks the quotas applying before the query and takes a concurrency slot.
+func (g *QueryGovernor) acquire(caller, sql string) (func(), QueryQuota, error) {
+	g.lock.Lock()
+	q := g.quota(caller)
+	if q.MaxConcurrent > 0 && g.running[caller] >= q.MaxConcurrent {
+		g.lock.Unlock()
+		return nil, q, &QuotaExceededError{Caller: caller, Quota: "concurrent queries", Limit: q.MaxConcurrent, Actual: q.MaxConcurrent + 1}
+	}
+	g.running[caller]++
+	g.lock.Unlock()
+
+	release := func() {
+		g.lock.Lock()
+		g.running[caller]--
+		if g.running[caller] <= 0 {
+			delete(g.running, caller)
+		}
+		g.lock.Unlock()
+	}
+
+	if q.MaxTimeRange > 0 {
+		if span, ok := g.timeRange(sql); !ok || span > q.MaxTimeRange {
+			release()
+			actual := interface{}("unbounded")
+			if ok {
+				actual = span
+			}
+			return nil, q, &QuotaExceededError{Caller: caller, Quota: "time range", Limit: q.MaxTimeRange, Actual: actual}
+		}
+	}
+	return release, q, nil
+}
+
+var timeBoundPattern = regexp.MustCompile("(?i)`?(\\w+)`?\\s*(>=|<=|>|<)\\s*('[^']*'|now\\s*(?:\\(\\s*\\))?\\s*(?:[-+]\\s*\\d+[a-z]+)?|-?\\d+)")
+
+// timeRange returns the span between the bounds on the timestamp columns,
+// false if the query has no lower bound.
+func (g *QueryGovernor) timeRange(sql string) (time.Duration, bool) {
+	columns := g.TimestampColumns
+	if len(columns) == 0 {
+		columns = []string{"ts", "_ts"}
+	}
+
+	var lower, upper time.Time
+	now := time.Now()
+	for _, m := range timeBoundPattern.FindAllStringSubmatch(sql, -1) {
+		if !containsFold(columns, m[1]) {
+			continue
+		}
+		t, ok := parseTimeLiteral(m[3], now, g.Precision)
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(m[2], ">") {
+			if lower.IsZero() || t.After(lower) {
+				lower = t
+			}
+		} else if upper.IsZero() || t.Before(upper) {
+			upper = t
+		}
+	}
+
+	if lower.IsZero() {
+		return 0, false
+	}
+	if upper.IsZero() {
+		upper = now
+	}
+	return upper.Sub(lower), true
+}
+
+func containsFold(s []string, v string) bool {
+	for _, e := range s {
+		if strings.EqualFold(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+var timeLiteralLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+var nowOffsetPattern = regexp.MustCompile(`(?i)^now\s*(?:\(\s*\))?\s*(?:([-+])\s*(\d+)([a-z]+))?$`)
+
+// parseTimeLiteral parses a quoted datetime, an integer timestamp or a `now - 1h` expression.
+func parseTimeLiteral(lit string, now time.Time, precision string) (time.Time, bool) {
+	lit = strings.TrimSpace(lit)
+
+	if strings.HasPrefix(lit, "'") {
+		s := strings.Trim(lit, "'")
+		for _, layout := range timeLiteralLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+
+	if m := nowOffsetPattern.FindStringSubmatch(lit); m != nil {
+		if len(m[1]) == 0 {
+			return now, true
+		}
+		d, err := parseDurationLiteral(m[2] + m[3])
+		if err != nil {
+			return time.Time{}, false
+		}
+		if m[1] == "-" {
+			return now.Add(-d), true
+		}
+		return now.Add(d), true
+	}
+
+	n, err := strconv.ParseInt(lit, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	switch precision {
+	case "us", "u":
+		return time.UnixMicro(n), true
+	case "ns", "n":
+		return time.Unix(0, n), true
+	default:
+		return time.UnixMilli(n), true
+	}
+}
+
+// parseDurationLiteral parses TDengine duration literals like 10s, 5m, 1h, 2d, 1w.
+func parseDurationLiteral(s string) (time.Duration, error) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	n, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	unit := map[string]time.Duration{
+		"b": time.Nanosecond, "u": time.Microsecond, "a": time.Millisecond,
+		"s": time.Second, "m": time.Minute, "h": time.Hour,
+		"d": 24 * time.Hour, "w": 7 * 24 * time.Hour,
+	}[strings.ToLower(s[i:])]
+	if unit == 0 {
+		return 0, fmt.Errorf("unsupported duration unit: %s", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// Wrap returns a Client enforcing the quotas on the queries of c.
+func (g *QueryGovernor) Wrap(c Client) Client {
+	return &governedClient{Client: c, governor: g}
+}
+
+type governedClient struct {
+	Client
+	governor *QueryGovernor
+}
+
+func (c *governedClient) Query(q Query) (*Response, error) {
+	return c.QueryContext(context.Background(), q)
+}
+
+func (c *governedClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	caller := QueryCallerFrom(ctx)
+	release, quota, err := c.governor.acquire(caller, q.Command)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := c.Client.QueryContext(ctx, q)
+	if err != nil {
+		return resp, err
+	}
+	if quota.MaxRows > 0 && resp != nil && len(resp.Data) > quota.MaxRows {
+		return nil, &QuotaExceededError{Caller: caller, Quota: "rows", Limit: quota.MaxRows, Actual: len(resp.Data)}
+	}
+	return resp, nil
+}
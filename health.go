@@ -0,0 +1,175 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// HealthReport is the result of Health, suitable for readiness endpoints.
+type HealthReport struct {
+	// Reachable is true when at least one probe got a response from the server.
+	Reachable bool
+
+	// Authenticated is true when the server accepted the credentials.
+	Authenticated bool
+
+	// DatabaseExists is true when the checked database exists, it is not checked
+	// when no database is given.
+	DatabaseExists bool
+
+	Version string
+	Cluster ClusterStatus
+
+	// Probes is the number of probes sent and Failures those which failed.
+	Probes   int
+	Failures int
+
+	// Latency is the round-trip latency of the successful probes.
+	Latency LatencyPercentiles
+
+	// Err is the last error encountered.
+	Err error
+
+	checkedDatabase bool
+}
+
+// LatencyPercentiles summarizes probe round-trip latencies.
+type LatencyPercentiles struct {
+	Min time.Duration
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// Ready reports whether the server is reachable and authenticated, and the
+// database exists if one was checked.
+func (r *HealthReport) Ready() bool {
+	return r.Reachable && r.Authenticated && (r.DatabaseExists || !r.checkedDatabase)
+}
+
+// Health sends probes pings to the server, defaulting to 1, and checks that
+// database exists unless it is empty. A failed probe does not stop the next
+// ones, so transient errors are retried.
+func (c *client) Health(ctx context.Context, database string, probes int) *HealthReport {
+	return health(ctx, c, database, probes)
+}
+
+func health(ctx context.Context, c Client, database string, probes int) *HealthReport {
+	if probes <= 0 {
+		probes = 1
+	}
+
+	report := &HealthReport{}
+	latencies := make([]time.Duration, 0, probes)
+	for i := 0; i < probes && ctx.Err() == nil; i++ {
+		report.Probes++
+		res, err := c.PingContext(ctx)
+		if err != nil {
+			report.Failures++
+			report.Err = err
+			if reachedServer(err) {
+				report.Reachable = true
+			}
+			continue
+		}
+		report.Reachable = true
+		report.Authenticated = true
+		report.Version = res.Version
+		report.Cluster = res.Cluster
+		latencies = append(latencies, res.RTT)
+	}
+	if report.Probes == 0 {
+		report.Err = ctx.Err()
+	}
+	report.Latency = latencyPercentiles(latencies)
+
+	if len(database) == 0 || !report.Authenticated {
+		return report
+	}
+	report.checkedDatabase = true
+	q := NewQuery("select name from information_schema.ins_databases where name = "+QuoteString(database), "", "")
+	resp, err := c.QueryContext(ctx, q)
+	if err == nil {
+		err = resp.Error()
+	}
+	if err != nil {
+		report.Err = err
+		return report
+	}
+	if resp.Rows == 0 {
+		report.Err = fmt.Errorf("database %s does not exist", database)
+		return report
+	}
+	report.DatabaseExists = true
+	return report
+}
+
+// reachedServer reports whether err was returned by the server rather than
+// by the transport.
+func reachedServer(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code != http.StatusBadGateway && statusErr.code != http.StatusServiceUnavailable &&
+			statusErr.code != http.StatusGatewayTimeout
+	}
+	var respErr *pingResponseError
+	return errors.As(err, &respErr)
+}
+
+// pingResponseError is an error response of the server to a ping.
+type pingResponseError struct {
+	err error
+}
+
+func (e *pingResponseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *pingResponseError) Unwrap() error {
+	return e.err
+}
+
+// latencyPercentiles returns the nearest-rank percentiles of latencies.
+func latencyPercentiles(latencies []time.Duration) LatencyPercentiles {
+	if len(latencies) == 0 {
+		return LatencyPercentiles{}
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := func(p float64) time.Duration {
+		i := int(p*float64(len(sorted))+0.5) - 1
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(sorted) {
+			i = len(sorted) - 1
+		}
+		return sorted[i]
+	}
+	return LatencyPercentiles{
+		Min: sorted[0],
+		P50: rank(0.5),
+		P90: rank(0.9),
+		P99: rank(0.99),
+		Max: sorted[len(sorted)-1],
+	}
+}
+
+// Health checks the server and the client database, see HealthReport.
+func (client *tsdbClient) Health(ctx context.Context, probes int) *HealthReport {
+	if client.httpClient == nil || client.initialErr != nil {
+		return &HealthReport{Err: fmt.Errorf("not created http client for tdengine: %v", client.initialErr)}
+	}
+	return client.httpClient.Health(ctx, client.dbConfig.DBName, probes)
+}
+
+// Health checks the server and the database of the default client.
+func Health(ctx context.Context, probes int) *HealthReport {
+	return clientWrapper.Health(ctx, probes)
+}
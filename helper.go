@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jeagle929/tsdbclient/models"
-	"log"
 	"strconv"
 	"strings"
 	"sync"
@@ -14,8 +13,7 @@ import (
 )
 
 const (
-	tsdbTimeStampFormat = "2006-01-02T15:04:05.999999999Z"
-	taosPollTimeoutMs   = 5000
+	taosPollTimeoutMs = 500
 )
 
 var (
@@ -27,13 +25,44 @@ type TSDBClient interface {
 	GetHttpClient() Client
 
 	QueryData(string, bool) ([]map[string]interface{}, error)
+	QueryDataContext(context.Context, string, bool) ([]map[string]interface{}, error)
+	QueryStream(ctx context.Context, sql string) (*QueryIterator, error)
+	FetchDashboard(ctx context.Context, queries map[string]Query) (map[string]ResultSet, error)
+	QueryAcrossDatabases(ctx context.Context, dbs []string, sqlTemplate string) (ResultSet, error)
 	WriteData(int64, string, map[string]string, map[string]interface{}) error
 	Close() error
 
 	Subscribe(ctx context.Context, topic string, chMessage chan<- TSDBSubscribedMessage) error
+	SubscribeTopics(ctx context.Context, topics []string, chMessage chan<- TSDBSubscribedMessage) error
+	SubscribeWithFilter(ctx context.Context, topic string, filter SubscribeFilter, chMessage chan<- TSDBSubscribedMessage) error
+	SubscribeWithConfig(ctx context.Context, topic string, conf SubscribeConfig, chMessage chan<- TSDBSubscribedMessage) error
+	SubscribeEnsureTopic(ctx context.Context, spec TopicSpec, chMessage chan<- TSDBSubscribedMessage) error
+	SubscribePool(ctx context.Context, topic string, conf ConsumerPoolConfig, handler MessageHandler) error
+	NewConsumer(topics []string, conf SubscribeConfig) (*Consumer, error)
+	SubscriptionStats() []SubscriptionStats
+	Stats() Stats
+	Health(ctx context.Context, probes int) *HealthReport
+	ChangePassword(ctx context.Context, user, newPass string) error
+	Prepare(sql string) (*PreparedStmt, error)
+	Schema(ctx context.Context, stable string) (*STableSchema, error)
+	InvalidateSchema(stable string)
+	WatchSchemas(ctx context.Context, stables []string, interval time.Duration, onChange func(SchemaChanged)) error
+	ColumnStats(ctx context.Context, table string, columns []string, r TimeRange) ([]ColumnStat, error)
+	SampleRows(ctx context.Context, table string, n int, strategy SampleStrategy) (ResultSet, error)
+	SkewCheck(ctx context.Context) (SkewReport, error)
+	ReplaySpool()
+	SpooledBytes() int64
+	RejectedPoints() []RejectedPoint
 	UnSubscribe(topic string) error
 
 	WriteDataBatch(points models.Points) error
+	WriteStruct(v interface{}) error
+	WriteAPI(opts WriteOptions) WriteAPI
+
+	ForDatabase(db string) TSDBClient
+	CreateDatabase(name string, opts DatabaseOptions) error
+	CreateSTable(name string, columns, tags []Column) error
+	CreateChildTable(name, stable string, tagValues map[string]interface{}) error
 }
 
 type tsdbClient struct {
@@ -42,35 +71,161 @@ type tsdbClient struct {
 		DBAddr    string
 		DBName    string
 		Precision string
-		DBUser    string
-		DBPass    string
+		WriteAddr string
 	}
 	initialErr error
+	creds      *credentials
 
 	//consumers map[string]TSDBSubscribeConsumer
 	//lockRW    sync.RWMutex
 
 	defaultNumberValue interface{}
+
+	writeBackend BackendMode
+	stmtWriter   *StmtWriter
+	stmtCreds    *userPassword
+	stmtLock     sync.Mutex
+
+	dedup *pointDeduplicator
+
+	missingTimestamp TimestampPolicy
+	timestampBounds  TimestampBounds
+	clock            *clockOffset
+	spool            *diskSpool
+	queryCache       *queryCache
+	logger           Logger
+	auditor          *auditWriter
+	keepFilter       *keepFilter
+	autoCreateTables bool
+
+	timestampFormat   TimestampFormat
+	timestampLocation *time.Location
+
+	arrayExplodes    map[string][]ArrayExplode
+	flattenSeparator string
+
+	writeTTL     int
+	tableNameKey string
+
+	schemas  *schemaRegistry
+	rejected *rejectedRing
+
+	subStats subscriptionRegistry
+	metrics  *clientMetrics
+
+	databases *databaseCache
+	parent    *tsdbClient
+
+	dashboardConcurrency int
 }
 
 func NewTDEngineClient(opts ...DBOption) TSDBClient {
 	dbOpt := newDBOptions(opts...)
 	config := HTTPConfig{
-		Addr:     dbOpt.DatabaseAddr,
-		Username: dbOpt.DatabaseUser,
-		Password: dbOpt.DatabasePass,
+		Addr:       dbOpt.DatabaseAddr,
+		ReadAddr:   dbOpt.ReadAddr,
+		WriteAddr:  dbOpt.WriteAddr,
+		Username:   dbOpt.DatabaseUser,
+		Password:   dbOpt.DatabasePass,
+		WriteRetry: dbOpt.WriteRetry,
+
+		credentials: newCredentials(dbOpt.DatabaseUser, dbOpt.DatabasePass),
+
+		WriteChecksum:       dbOpt.WriteChecksum,
+		VerifyContentLength: dbOpt.VerifyContentLength,
+		WriteProtocol:       dbOpt.WriteProtocol,
+		TokenAuth:           dbOpt.TokenAuth,
+		TokenRefresh:        dbOpt.TokenRefresh,
+		ReplicaAddrs:        dbOpt.ReplicaAddrs,
+		PingQuery:           dbOpt.PingQuery,
+		Logger:              dbOpt.Logger,
+		DialControl:         dbOpt.DialControl,
+		LogDials:            dbOpt.LogDials,
+		TLSConfig:           dbOpt.TLSConfig,
+		StrictTLS:           dbOpt.StrictTLS,
+		SPKIPins:            dbOpt.SPKIPins,
+		Middlewares:         dbOpt.Middlewares,
+		WriteEncoding:       dbOpt.WriteEncoding,
+		GzipLevel:           dbOpt.GzipLevel,
+		LimitPolicy:         dbOpt.LimitPolicy,
+		MaxPointsPerRequest: dbOpt.MaxPointsPerRequest,
+		MaxBodySize:         dbOpt.MaxBodySize,
+		WriteConcurrency:    dbOpt.WriteConcurrency,
+		MaxIdleConns:        dbOpt.MaxIdleConns,
+		MaxIdleConnsPerHost: dbOpt.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     dbOpt.MaxConnsPerHost,
+		IdleConnTimeout:     dbOpt.IdleConnTimeout,
+		KeepAlive:           dbOpt.KeepAlive,
+		DisableKeepAlives:   dbOpt.DisableKeepAlives,
+		HTTPClient:          dbOpt.HTTPClient,
+		RoundTripper:        dbOpt.RoundTripper,
 	}
 
 	cli := &tsdbClient{
 		//consumers:          make(map[string]TSDBSubscribeConsumer),
 		defaultNumberValue: dbOpt.DefaultNumberValue,
+		writeBackend:       dbOpt.WriteBackend,
+		missingTimestamp:   dbOpt.MissingTimestamp,
+		timestampBounds:    dbOpt.TimestampBounds,
+		timestampFormat:    dbOpt.TimestampFormat,
+		timestampLocation:  dbOpt.TimestampLocation,
+		arrayExplodes:      dbOpt.ArrayExplodes,
+		flattenSeparator:   dbOpt.FlattenSeparator,
+		writeTTL:           dbOpt.WriteTTL,
+		tableNameKey:       dbOpt.TableNameKey,
+		schemas:            newSchemaRegistry(dbOpt.SchemaCacheTTL),
+		clock:              &clockOffset{adjust: dbOpt.AdjustClockSkew},
+		metrics:            newClientMetrics(),
+		creds:              config.credentials,
+		autoCreateTables:   dbOpt.AutoCreateTables,
+		databases:          newDatabaseCache(dbOpt.DatabaseCacheSize),
+
+		dashboardConcurrency: dbOpt.DashboardConcurrency,
+		logger:               dbOpt.Logger,
+	}
+	if dbOpt.Audit != nil && cli.initialErr == nil {
+		cli.auditor = newAuditWriter(cli, *dbOpt.Audit)
+	}
+	if dbOpt.DropExpired {
+		cli.keepFilter = &keepFilter{onDrop: dbOpt.OnExpired}
+	}
+	if dbOpt.QuarantineSize > 0 {
+		cli.rejected = newRejectedRing(dbOpt.QuarantineSize)
+	}
+	if dbOpt.DedupWindow > 0 {
+		cli.dedup = newPointDeduplicator(dbOpt.DedupWindow, dbOpt.DedupSize)
+	}
+	if dbOpt.Transport == WebsocketTransport {
+		cli.httpClient, cli.initialErr = NewWebsocketClient(config)
+	} else {
+		cli.httpClient, cli.initialErr = NewHTTPClient(config)
+	}
+	if cli.initialErr == nil {
+		cli.httpClient = &instrumentedClient{Client: cli.httpClient, metrics: cli.metrics}
+		if dbOpt.CollapseQueries {
+			cli.httpClient = newQueryFlight(dbOpt.LimitPolicy).Wrap(cli.httpClient)
+		}
+		if dbOpt.QueryGovernor != nil {
+			cli.httpClient = dbOpt.QueryGovernor.Wrap(cli.httpClient)
+		}
+		if dbOpt.QueryCacheTTL > 0 {
+			cli.queryCache = newQueryCache(dbOpt.QueryCacheTTL, dbOpt.QueryCacheSize, dbOpt.LimitPolicy)
+			cli.httpClient = cli.queryCache.Wrap(cli.httpClient)
+		}
 	}
-	cli.httpClient, cli.initialErr = NewHTTPClient(config)
 	cli.dbConfig.DBAddr = dbOpt.DatabaseAddr
+	cli.dbConfig.WriteAddr = dbOpt.DatabaseAddr
+	if len(dbOpt.WriteAddr) > 0 {
+		cli.dbConfig.WriteAddr = dbOpt.WriteAddr
+	}
 	cli.dbConfig.DBName = dbOpt.DatabaseName
 	cli.dbConfig.Precision = dbOpt.PrecisionUnit
-	cli.dbConfig.DBUser = dbOpt.DatabaseUser
-	cli.dbConfig.DBPass = dbOpt.DatabasePass
+
+	if len(dbOpt.SpoolDir) > 0 && cli.initialErr == nil {
+		if cli.spool, cli.initialErr = newDiskSpool(dbOpt.SpoolDir, dbOpt.SpoolMaxBytes, dbOpt.SpoolKeys, cli.log()); cli.initialErr == nil {
+			go cli.spool.run(cli.replaySpooled)
+		}
+	}
 
 	return cli
 }
@@ -79,55 +234,67 @@ func (client *tsdbClient) GetHttpClient() Client {
 	return client.httpClient
 }
 
-func (client *tsdbClient) QueryData(sql string, convertNumber bool) (result []map[string]interface{}, err error) {
+func (client *tsdbClient) QueryData(sql string, convertNumber bool) ([]map[string]interface{}, error) {
+	return client.QueryDataContext(context.Background(), sql, convertNumber)
+}
+
+// QueryDataContext is QueryData with a context controlling cancellation and deadline.
+func (client *tsdbClient) QueryDataContext(ctx context.Context, sql string, convertNumber bool) (result []map[string]interface{}, err error) {
 
 	if client.httpClient == nil || client.initialErr != nil {
 		err = fmt.Errorf("not created http client for tdengine: %v", client.initialErr)
 		return
 	}
 
+	q := withContextOptions(ctx, NewQuery(sql, client.dbConfig.DBName, client.dbConfig.Precision))
+	tsFormat, tsLocation := client.timestampFormat, client.timestampLocation
+	if q.TimestampFormat != 0 {
+		tsFormat, tsLocation = q.TimestampFormat, q.Location
+	}
+
 	var resp *Response
-	resp, err = client.httpClient.Query(NewQuery(sql, client.dbConfig.DBName, client.dbConfig.Precision))
+	resp, err = client.httpClient.QueryContext(ctx, q)
 	if err == nil {
 		if err = resp.Error(); err != nil {
-			if err == ErrNotExistsTable {
+			if errors.Is(err, ErrNotExistsTable) {
 				return result, nil
 			}
 			return nil, err
 		}
+		if resp.Warning != nil {
+			client.log().Warn("query result incomplete", "sql", sql, "warning", resp.Warning)
+		}
+		columns, e := resp.columnMetas()
+		if e != nil && len(resp.Data) > 0 {
+			return nil, e
+		}
 		for _, r := range resp.Data {
 			row := map[string]interface{}{}
-			for i, c := range resp.ColumnMeta {
-				// c is column meta, format: [column name, column type, type size]
-				if len(c) != 3 {
-					return nil, errors.New("column meta data length no equal 3")
-				}
-				cn := c[0].(string)
+			for i, c := range columns {
+				cn := c.Name
 				// if column name is `_`, ignore
 				if cn == "_" {
 					continue
 				}
 				if convertNumber {
-					switch c[1].(string) {
-					case "BIGINT", "INT", "TINYINT", "SMALLINT", "TINYINT UNSIGNED", "SMALLINT UNSIGNED", "INT UNSIGNED", "BIGINT UNSIGNED":
+					switch c.Type {
+					case TypeBigInt, TypeInt, TypeTinyInt, TypeSmallInt, TypeUTinyInt, TypeUSmallInt, TypeUInt, TypeUBigInt:
 						if num, ok := r[i].(json.Number); ok {
 							row[cn], _ = num.Int64()
 						} else {
 							row[cn] = client.defaultNumberValue
 						}
 						//row[cn], _ = r[i].(json.Number).Int64()
-					case "FLOAT", "DOUBLE":
+					case TypeFloat, TypeDouble:
 						if num, ok := r[i].(json.Number); ok {
 							row[cn], _ = num.Float64()
 						} else {
 							row[cn] = client.defaultNumberValue
 						}
 						//row[cn], _ = r[i].(json.Number).Float64()
-					case "TIMESTAMP":
-						if ts, ee := time.Parse(tsdbTimeStampFormat, r[i].(string)); ee == nil {
-							row[cn] = ts.Unix()
-						} else {
-							row[cn] = 0
+					case TypeTimestamp:
+						if row[cn], err = decodeTimestamp(r[i], tsFormat, tsLocation); err != nil {
+							return nil, fmt.Errorf("column %s: %w", cn, err)
 						}
 					default:
 						row[cn] = r[i]
@@ -145,10 +312,7 @@ func (client *tsdbClient) QueryData(sql string, convertNumber bool) (result []ma
 
 func (client *tsdbClient) WriteData(ts int64, name string, tags map[string]string, fields map[string]interface{}) error {
 
-	bps, _ := NewBatchPoints(BatchPointsConfig{
-		Precision: client.dbConfig.Precision,
-		Database:  client.dbConfig.DBName,
-	})
+	bps := client.newBatch()
 
 	if ts > 0 {
 		var t time.Time
@@ -176,98 +340,377 @@ func (client *tsdbClient) WriteData(ts int64, name string, tags map[string]strin
 			return fmt.Errorf("invalid timestamp %d, valid digit range: [3|10-19]", ts)
 		}
 
-		if pt, err := NewDataPoint(name, tags, fields, t); err != nil {
+		if err := client.addPoints(bps, name, tags, fields, t); err != nil {
 			return err
-		} else {
-			bps.AddPoint(pt)
 		}
 	} else {
-		if pt, err := NewDataPoint(name, tags, fields); err != nil {
+		if err := client.addPoints(bps, name, tags, fields, time.Time{}); err != nil {
 			return err
-		} else {
-			bps.AddPoint(pt)
 		}
 	}
 
-	return client.httpClient.Write(bps)
+	return client.write(bps)
 
 }
 
 func (client *tsdbClient) Subscribe(ctx context.Context, topic string, chMessage chan<- TSDBSubscribedMessage) error {
-	return client.subscribe(ctx, topic, chMessage)
+	return client.subscribe(ctx, topic, SubscribeConfig{}, chMessage)
+}
+
+// SubscribeWithFilter delivers only the messages accepted by filter, a nil filter accepts all.
+func (client *tsdbClient) SubscribeWithFilter(ctx context.Context, topic string, filter SubscribeFilter, chMessage chan<- TSDBSubscribedMessage) error {
+	return client.subscribe(ctx, topic, SubscribeConfig{Filter: filter}, chMessage)
+}
+
+// SubscribeWithConfig subscribes the topic with the given filter and rebalance callbacks.
+func (client *tsdbClient) SubscribeWithConfig(ctx context.Context, topic string, conf SubscribeConfig, chMessage chan<- TSDBSubscribedMessage) error {
+	return client.subscribe(ctx, topic, conf, chMessage)
+}
+
+// SubscribeEnsureTopic creates the topic described by spec if missing before subscribing.
+func (client *tsdbClient) SubscribeEnsureTopic(ctx context.Context, spec TopicSpec, chMessage chan<- TSDBSubscribedMessage) error {
+	sql, err := spec.Statement()
+	if err != nil {
+		return err
+	}
+	if err = client.execContext(ctx, sql); err != nil {
+		return err
+	}
+	return client.subscribe(ctx, spec.Name, SubscribeConfig{}, chMessage)
+}
+
+// Stats returns the counters of the writes, queries and subscriptions of this client.
+func (client *tsdbClient) Stats() Stats {
+	s := client.metrics.snapshot()
+	s.Subscriptions = client.subStats.snapshot()
+	return s
+}
+
+// SubscriptionStats returns the counters of every topic subscribed through this client.
+func (client *tsdbClient) SubscriptionStats() []SubscriptionStats {
+	return client.subStats.snapshot()
 }
 
 func (client *tsdbClient) WriteDataBatch(points models.Points) error {
 	if points != nil && points.Len() > 0 {
-		bps, _ := NewBatchPoints(BatchPointsConfig{
-			Precision: client.dbConfig.Precision,
-			Database:  client.dbConfig.DBName,
-		})
+		bps := client.newBatch()
 
 		for _, point := range points {
 			bps.AddPoint(NewPointFrom(point))
 		}
 
-		return client.httpClient.Write(bps)
+		return client.write(bps)
+	}
+	return nil
+}
+
+// write sends the batch through the configured write backend.
+func (client *tsdbClient) write(bps BatchPoints) (err error) {
+	defer func() {
+		if err != nil {
+			client.quarantine(bps, err)
+		}
+	}()
+	now := client.clock.now()
+	if err := applyTimestampPolicy(client.missingTimestamp, bps, now); err != nil {
+		return err
+	}
+	if err := client.timestampBounds.check(bps, now); err != nil {
+		return err
+	}
+	if client.keepFilter != nil {
+		var err error
+		if bps, err = client.keepFilter.filter(client, bps); err != nil {
+			return err
+		}
+		if len(bps.Points()) == 0 {
+			return nil
+		}
+	}
+	if client.dedup == nil {
+		return client.deliver(bps)
+	}
+
+	bps = client.dedup.filter(bps)
+	if len(bps.Points()) == 0 {
+		return nil
 	}
+	if err := client.deliver(bps); err != nil {
+		return err
+	}
+	client.dedup.mark(bps)
 	return nil
 }
 
-func (client *tsdbClient) subscribe(ctx context.Context, topic string, chMessage chan<- TSDBSubscribedMessage) error {
+// newBatch returns an empty batch of the client database and write parameters.
+func (client *tsdbClient) newBatch() BatchPoints {
+	bps, _ := NewBatchPoints(BatchPointsConfig{
+		Precision:    client.dbConfig.Precision,
+		Database:     client.dbConfig.DBName,
+		TTL:          client.writeTTL,
+		TableNameKey: client.tableNameKey,
+	})
+	return bps
+}
 
-	if len(topic) == 0 {
+func (client *tsdbClient) writeBackendBatch(bps BatchPoints) error {
+	// the requests of the batch share its req_id, recorded by the audit
+	reqID := requestID(context.Background())
+	ctx := WithReqID(context.Background(), reqID)
+	err := client.sendBatch(ctx, bps)
+	if err != nil && client.autoCreateTables && isNotExistsTable(err) {
+		if e := client.createSTables(bps); e != nil {
+			err = errors.Join(err, e)
+		} else {
+			err = client.sendBatch(ctx, bps)
+		}
+	}
+	client.metrics.recordWrite(bps, err)
+	if client.writeBackend == StmtBackend {
+		reqID = 0
+	}
+	client.audit(bps, reqID, err)
+	if client.queryCache != nil {
+		// a failed batch may have been written in part
+		client.queryCache.invalidate(measurements(bps)...)
+	}
+	return err
+}
+
+func (client *tsdbClient) sendBatch(ctx context.Context, bps BatchPoints) error {
+	switch client.writeBackend {
+	case StmtBackend:
+	case SQLBackend:
+		return client.insertBatch(ctx, bps)
+	default:
+		return client.httpClient.WriteContext(ctx, bps)
+	}
+
+	w, err := client.getStmtWriter()
+	if err != nil {
+		return err
+	}
+	defer w.users.Done()
+	return w.Write(bps)
+}
+
+// getStmtWriter returns the stmt connection of the client, it is opened again
+// after the credentials changed. The caller calls w.users.Done when done with
+// it: the replaced connection is closed once its users are done.
+func (client *tsdbClient) getStmtWriter() (*StmtWriter, error) {
+	client.stmtLock.Lock()
+	defer client.stmtLock.Unlock()
+
+	creds := client.creds.load()
+	if client.stmtWriter != nil && client.stmtCreds != creds {
+		client.stmtWriter.retire()
+		client.stmtWriter = nil
+	}
+	if client.stmtWriter == nil {
+		w, err := NewStmtWriter(client.dbConfig.WriteAddr, creds.user, creds.password,
+			client.dbConfig.DBName, client.dbConfig.Precision)
+		if err != nil {
+			return nil, err
+		}
+		client.stmtWriter, client.stmtCreds = w, creds
+	}
+	client.stmtWriter.users.Add(1)
+	return client.stmtWriter, nil
+}
+
+func (client *tsdbClient) subscribe(ctx context.Context, topic string, conf SubscribeConfig, chMessage chan<- TSDBSubscribedMessage) error {
+	return client.subscribeTopics(ctx, []string{topic}, conf, chMessage)
+}
+
+// SubscribeTopics subscribes to the topics with one consumer, the consumer group is
+// named after the topics joined by "_" and the stats are reported under the topics
+// joined by ",".
+func (client *tsdbClient) SubscribeTopics(ctx context.Context, topics []string, chMessage chan<- TSDBSubscribedMessage) error {
+	return client.subscribeTopics(ctx, topics, SubscribeConfig{}, chMessage)
+}
+
+// subscribeTopics consumes the topics until ctx is done or the subscription failed.
+// chMessage belongs to the caller and is left open, several subscriptions may share it.
+func (client *tsdbClient) subscribeTopics(ctx context.Context, topics []string, conf SubscribeConfig, chMessage chan<- TSDBSubscribedMessage) error {
+
+	if len(topics) == 0 || containsString(topics, "") {
 		return errors.New("invalid args: topic is empty")
 	}
+	topic := strings.Join(topics, ",")
 
 	if chMessage == nil {
 		return errors.New("invalid args: chMessage is nil")
 	}
 
-	tsdbCons, err := newConsumer(client.dbConfig.DBAddr, client.dbConfig.DBUser, client.dbConfig.DBPass, topic)
+	stats := client.subStats.get(topic)
+	reconnect := conf.Reconnect.backoff()
+	failures := 0
+	for {
+		err := client.consumeTopics(ctx, topics, conf, chMessage, func() {
+			conf.notifyStatus(SubscriptionStatus{Topic: topic, State: StateConnected, Attempt: failures + 1})
+			failures = 0
+		})
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		failures++
+		if !reconnect.enabled() || failures >= reconnect.MaxAttempts {
+			conf.notifyStatus(SubscriptionStatus{Topic: topic, State: StateGaveUp, Attempt: failures, Err: err})
+			return err
+		}
+
+		client.log().Warn("subscribe reconnect after error", "topic", topic, "error", err)
+		conf.notifyStatus(SubscriptionStatus{Topic: topic, State: StateDisconnected, Attempt: failures, Err: err})
+		if err := reconnect.wait(ctx, failures); err != nil {
+			return err
+		}
+		stats.reconnects.Add(1)
+		conf.notifyStatus(SubscriptionStatus{Topic: topic, State: StateReconnecting, Attempt: failures + 1})
+	}
+}
+
+// consumeTopics runs one consumer until ctx is done or it fails, connected is called
+// once the consumer subscribed.
+func (client *tsdbClient) consumeTopics(ctx context.Context, topics []string, conf SubscribeConfig,
+	chMessage chan<- TSDBSubscribedMessage, connected func()) error {
+	topic := strings.Join(topics, ",")
+
+	creds := client.creds.load()
+	tsdbCons, err := newConsumer(client.dbConfig.DBAddr, creds.user, creds.password, strings.Join(topics, "_"), conf)
 	if err != nil {
 		return err
 	}
 	defer tsdbCons.Close()
 
-	err = tsdbCons.Subscribe(topic, nil)
+	err = tsdbCons.SubscribeTopics(topics, nil)
 	if err != nil {
 		return err
 	}
 	defer tsdbCons.Unsubscribe()
+	connected()
+
+	var idle func() error
+	committer := newOffsetCommitter()
+	defer committer.close()
+	if conf.ManualCommit {
+		idle = func() error {
+			committer.serve(tsdbCons)
+			return nil
+		}
+	}
+
+	err = client.consume(ctx, tsdbCons, topic, conf, func(msg TSDBSubscribedMessage) error {
+		if conf.ManualCommit {
+			committer.serve(tsdbCons)
+			// never drop uncommitted messages, a later commit would skip them
+			m, err := newCommittableMessage(tsdbCons, msg, committer.commit)
+			if err != nil {
+				return err
+			}
+			for {
+				select {
+				case chMessage <- m:
+					return nil
+				case req := <-committer.requests:
+					// the reader may commit before it reads again
+					committer.handle(tsdbCons, req)
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+		select {
+		case chMessage <- msg:
+		default:
+			client.subStats.get(topic).dropped.Add(1)
+			client.log().Warn("subscribe chan message full", "topic", topic)
+		}
+		return nil
+	}, idle)
+	if err != nil {
+		return err
+	}
+
+	if e := tsdbCons.Unsubscribe(); e != nil {
+		client.log().Error("subscribe unsubscribe error", "topic", topic, "error", e)
+		return e
+	}
+	client.log().Info("subscribe unsubscribe success", "topic", topic)
+	return nil
+
+}
+
+// consume polls the subscribed consumer until ctx is done, passing every message accepted
+// by the filter to deliver. idle, if set, is called after a poll returned no message.
+// It returns nil once ctx is done, or the first error of polling, deliver or idle.
+func (client *tsdbClient) consume(ctx context.Context, tsdbCons taosConsumer, topic string, conf SubscribeConfig,
+	deliver func(TSDBSubscribedMessage) error, idle func() error) error {
+
+	stats := client.subStats.get(topic)
+	tracker := newAssignmentTracker(topic, conf)
+	if tracker != nil {
+		defer tracker.revokeAll()
+	}
+	pollTimeoutMs := conf.pollTimeoutMs()
+
+	var idleTimer *time.Timer
+	if conf.IdleSleep > 0 {
+		idleTimer = time.NewTimer(conf.IdleSleep)
+		defer idleTimer.Stop()
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("[tsdbclient] Subscribe timeout to ready unsubscribe...")
-			if e := tsdbCons.Unsubscribe(); e != nil {
-				log.Printf("[tsdbclient] Subscribe unsubscribe error: %v\n", e)
-				return e
-			} else {
-				log.Println("[tsdbclient] Subscribe unsubscribe success.")
-				close(chMessage)
-				log.Println("[tsdbclient] Subscribe receive channel closed")
-			}
+			client.log().Info("subscribe done, ready to unsubscribe", "topic", topic)
 			return nil
 		default:
-			if ev := tsdbCons.Poll(taosPollTimeoutMs); ev != nil {
-				switch e := ev.(type) {
-				case TSDBSubscribedMessage:
-					select {
-					case chMessage <- e:
-					default:
-						log.Println("[tsdbclient] Subscribe chan message full")
-					}
-				case error:
-					log.Printf("[tsdbclient] Subscribe tmq error: %v\n", e)
-					//close(chMessage)
-					return e
-				default:
-					log.Printf("[tsdbclient] Subscribe not expected receive type: %T\n", e)
+		}
+
+		if tracker != nil {
+			if changed, e := tracker.check(tsdbCons); e != nil {
+				client.log().Error("subscribe assignment error", "topic", topic, "error", e)
+			} else if changed {
+				stats.rebalances.Add(1)
+			}
+		}
+
+		stats.polls.Add(1)
+		ev := tsdbCons.Poll(pollTimeoutMs)
+		if ev == nil {
+			if idle != nil {
+				if err := idle(); err != nil {
+					return err
+				}
+			}
+			if idleTimer != nil {
+				idleTimer.Reset(conf.IdleSleep)
+				select {
+				case <-ctx.Done():
+				case <-idleTimer.C:
 				}
 			}
+			continue
 		}
-	}
 
+		switch e := ev.(type) {
+		case TSDBSubscribedMessage:
+			stats.record(e, time.Now())
+			if conf.Filter != nil && !conf.Filter(e) {
+				stats.filtered.Add(1)
+				continue
+			}
+			if err := deliver(e); err != nil {
+				return err
+			}
+		case error:
+			stats.pollErrors.Add(1)
+			client.log().Error("subscribe tmq error", "topic", topic, "error", e)
+			return e
+		default:
+			client.log().Warn("subscribe not expected receive type", "topic", topic, "type", fmt.Sprintf("%T", e))
+		}
+	}
 }
 
 // Deprecated: replace with subscribe args ctx
@@ -296,9 +739,31 @@ func (client *tsdbClient) Close() error {
 	//	v.Close()
 	//}
 	//clear(client.consumers)
+	client.closeStmtWriter()
+	if client.parent != nil {
+		return nil
+	}
+	if client.spool != nil {
+		client.spool.shutdown()
+	}
+	if client.auditor != nil {
+		client.auditor.close()
+	}
+	client.databases.close()
 	return client.httpClient.Close()
 }
 
+// closeStmtWriter closes the stmt connection once the writes and prepared
+// statements using it are done.
+func (client *tsdbClient) closeStmtWriter() {
+	client.stmtLock.Lock()
+	if client.stmtWriter != nil {
+		client.stmtWriter.retire()
+		client.stmtWriter = nil
+	}
+	client.stmtLock.Unlock()
+}
+
 func init() {
 	once.Do(func() {
 		if clientWrapper == nil {
@@ -310,8 +775,27 @@ func init() {
 ///////////////////////////////////////////////////////////////////////////////////////////////
 
 func ReadData(sql string, opts ...DBOption) ([]map[string]interface{}, error) {
+	return ReadDataContext(context.Background(), sql, opts...)
+}
+
+// ReadDataContext is ReadData with a context controlling cancellation and deadline.
+func ReadDataContext(ctx context.Context, sql string, opts ...DBOption) ([]map[string]interface{}, error) {
 	dbOpt := newDBOptions(opts...)
-	return clientWrapper.QueryData(sql, dbOpt.ConvertNumber)
+
+	derivations := make([]*Derivation, 0, len(dbOpt.Derivations))
+	for _, expr := range dbOpt.Derivations {
+		d, err := ParseDerivation(expr)
+		if err != nil {
+			return nil, err
+		}
+		derivations = append(derivations, d)
+	}
+
+	rows, err := clientWrapper.QueryDataContext(ctx, sql, dbOpt.ConvertNumber)
+	if err == nil && len(derivations) > 0 {
+		ApplyDerivations(rows, derivations...)
+	}
+	return rows, err
 }
 
 func WriteData(name string, tag map[string]string, fields map[string]interface{}, opts ...DBOption) error {
@@ -320,11 +804,20 @@ func WriteData(name string, tag map[string]string, fields map[string]interface{}
 }
 
 func QueryData(sql string, opts ...DBOption) (columns []string, rows [][]interface{}, err error) {
+	return QueryDataContext(context.Background(), sql, opts...)
+}
+
+// QueryDataContext is QueryData with a context controlling cancellation and deadline.
+func QueryDataContext(ctx context.Context, sql string, opts ...DBOption) (columns []string, rows [][]interface{}, err error) {
 	if client := clientWrapper.GetHttpClient(); client != nil {
 		dbOpt := newDBOptions(opts...)
-		if resp, e := client.Query(NewQuery(sql, dbOpt.DatabaseName, dbOpt.PrecisionUnit)); e == nil {
-			for _, cm := range resp.ColumnMeta {
-				columns = append(columns, cm[0].(string))
+		if resp, e := client.QueryContext(ctx, NewQuery(sql, dbOpt.DatabaseName, dbOpt.PrecisionUnit)); e == nil {
+			metas, e := resp.columnMetas()
+			if e != nil {
+				return nil, nil, e
+			}
+			for _, c := range metas {
+				columns = append(columns, c.Name)
 			}
 			rows = resp.Data
 		} else {
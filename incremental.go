@@ -0,0 +1,139 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IncrementalHandler receives the rows of table written after its watermark,
+// ordered by time.
+type IncrementalHandler func(ctx context.Context, table string, resp *Response) error
+
+// IncrementalReader reads the rows of tables added since its previous run, for
+// syncing them into other systems on a schedule. The watermark of a table, the
+// last timestamp read, is saved in Store once Handle accepted its rows, so a
+// failed run is read again by the next one. Rows written later with a timestamp
+// at or before the watermark are not read, unless within Overlap of it.
+type IncrementalReader struct {
+	// Client runs the queries, in its database.
+	Client TSDBClient
+
+	// Store persists the watermarks by table name, a store must not be shared
+	// by the readers of different databases.
+	Store CheckpointStore
+
+	// Tables are the tables or super tables to read.
+	Tables []string
+
+	// Column is the timestamp column, "ts" if empty.
+	Column string
+
+	// Precision is the precision of the database, "ms" if empty.
+	Precision string
+
+	// Start is where a table without watermark is read from, all its rows if zero.
+	Start time.Time
+
+	// Overlap reads again the rows of that long before the watermark, for the
+	// rows landing late or out of order. Handle receives them again and must
+	// skip those it already handled.
+	Overlap time.Duration
+
+	// Handle receives the new rows of each table.
+	Handle IncrementalHandler
+}
+
+// Run reads the new rows of the tables in order, it stops at the first error.
+func (r *IncrementalReader) Run(ctx context.Context) error {
+	if r.Client == nil || r.Store == nil {
+		return errors.New("invalid args: incremental `Client` or `Store` is nil")
+	}
+	if r.Handle == nil {
+		return errors.New("invalid args: incremental `Handle` is nil")
+	}
+	column := r.Column
+	if len(column) == 0 {
+		column = "ts"
+	}
+	for _, table := range r.Tables {
+		if err := r.read(ctx, table, column); err != nil {
+			return fmt.Errorf("table %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func (r *IncrementalReader) read(ctx context.Context, table, column string) error {
+	watermark, ok, err := r.Store.Load(ctx, table)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		watermark = r.Start
+	}
+
+	tableName, err := QuoteIdent(table)
+	if err != nil {
+		return err
+	}
+	columnName, err := QuoteIdent(column)
+	if err != nil {
+		return err
+	}
+	sql := fmt.Sprintf("select * from %s order by %s", tableName, columnName)
+	if !watermark.IsZero() {
+		after, err := timestampLiteral(watermark.Add(-r.Overlap), r.Precision)
+		if err != nil {
+			return err
+		}
+		sql = fmt.Sprintf("select * from %s where %s > %s order by %s", tableName, columnName, after, columnName)
+	}
+
+	it, err := r.Client.QueryStream(WithQueryOrigin(ctx, BatchOrigin), sql)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	index := -1
+	for i, name := range it.Columns() {
+		if name == column {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("timestamp column %s not found", column)
+	}
+	resp := &Response{ColumnMeta: it.ColumnMeta, Columns: it.ColumnTypes()}
+	last := watermark
+	for it.Next() {
+		row := it.Row()
+		if index >= len(row) {
+			return fmt.Errorf("row has %d values, expected %d", len(row), len(it.ColumnMeta))
+		}
+		ts, err := timestampValue(row[index])
+		if err != nil {
+			return err
+		}
+		if ts.After(last) {
+			last = ts
+		}
+		resp.Data = append(resp.Data, row)
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	resp.Warning = it.Warning()
+	resp.Rows = len(resp.Data)
+	if resp.Rows == 0 {
+		return nil
+	}
+
+	if err := r.Handle(ctx, table, resp); err != nil {
+		return err
+	}
+	return r.Store.Save(ctx, table, last)
+}
@@ -0,0 +1,73 @@
+package tsdbclient
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// durationUnits are the units of TDengine duration literals from the largest,
+// without months and years which have no fixed length.
+var durationUnits = []struct {
+	unit string
+	d    time.Duration
+}{
+	{"w", 7 * 24 * time.Hour},
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+	{"a", time.Millisecond},
+	{"u", time.Microsecond},
+	{"b", time.Nanosecond},
+}
+
+// Interval returns the TDengine literal of the window length d, in its largest
+// exact unit: 10*time.Second is "10s", 1500*time.Millisecond is "1500a". It is
+// empty when d is not positive, which the query builder rejects.
+//
+//	Select("_wstart", "avg(current)").From("meters").Interval(Interval(10 * time.Second))
+func Interval(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	for _, u := range durationUnits {
+		if d%u.d == 0 {
+			return strconv.FormatInt(int64(d/u.d), 10) + u.unit
+		}
+	}
+	return ""
+}
+
+// Sliding returns the TDengine literal of the sliding step d, see Interval.
+func Sliding(d time.Duration) string {
+	return Interval(d)
+}
+
+// Offset returns the TDengine literal of the window offset d, see Interval.
+func Offset(d time.Duration) string {
+	return Interval(d)
+}
+
+// CheckDuration checks that literal is a duration literal valid in a database of
+// the precision: nanoseconds need "ns" and microseconds "us" or "ns". An empty
+// precision accepts all units.
+func CheckDuration(literal, precision string) error {
+	if !durationLiteralPattern.MatchString(literal) {
+		return fmt.Errorf("invalid args: duration %q", literal)
+	}
+	unit := literal[len(literal)-1]
+	switch precision {
+	case "", "ns", "n":
+		return nil
+	case "us", "u":
+		if unit != 'b' {
+			return nil
+		}
+	default:
+		if unit != 'b' && unit != 'u' {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid args: duration %q is finer than the precision %s", literal, precision)
+}
@@ -0,0 +1,97 @@
+package tsdbclient
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keepFilter drops points older than the KEEP of the database, which the server
+// rejects together with the whole batch. KEEP is queried once on first use.
+type keepFilter struct {
+	onDrop func(points []*DataPoint)
+
+	lock sync.Mutex
+	keep time.Duration
+}
+
+// filter returns the batch without expired points, reporting them to onDrop.
+func (f *keepFilter) filter(client *tsdbClient, bp BatchPoints) (BatchPoints, error) {
+	keep, err := f.retention(client)
+	if err != nil {
+		return nil, err
+	}
+
+	min := time.Now().Add(-keep)
+	var dropped []*DataPoint
+	for _, p := range bp.Points() {
+		if p != nil && !p.Time().IsZero() && p.Time().Before(min) {
+			dropped = append(dropped, p)
+		}
+	}
+	if len(dropped) == 0 {
+		return bp, nil
+	}
+
+	out, _ := NewBatchPoints(configOf(bp))
+	for _, p := range bp.Points() {
+		if p != nil && (p.Time().IsZero() || !p.Time().Before(min)) {
+			out.AddPoint(p)
+		}
+	}
+	if f.onDrop != nil {
+		f.onDrop(dropped)
+	}
+	return out, nil
+}
+
+func (f *keepFilter) retention(client *tsdbClient) (time.Duration, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.keep > 0 {
+		return f.keep, nil
+	}
+
+	keep, err := queryDatabaseKeep(client)
+	if err != nil {
+		return 0, err
+	}
+	f.keep = keep
+	return keep, nil
+}
+
+// queryDatabaseKeep returns the longest KEEP of the client database.
+func queryDatabaseKeep(client *tsdbClient) (time.Duration, error) {
+	if client.httpClient == nil || client.initialErr != nil {
+		return 0, fmt.Errorf("not created http client for tdengine: %v", client.initialErr)
+	}
+
+	sql := fmt.Sprintf("select `keep` from information_schema.ins_databases where name = %s", QuoteString(client.dbConfig.DBName))
+	resp, err := client.httpClient.Query(NewQuery(sql, "", client.dbConfig.Precision))
+	if err != nil {
+		return 0, err
+	}
+	if err = resp.Error(); err != nil {
+		return 0, err
+	}
+	if len(resp.Data) == 0 || len(resp.Data[0]) == 0 {
+		return 0, fmt.Errorf("database %s not found", client.dbConfig.DBName)
+	}
+	return parseKeep(fmt.Sprint(resp.Data[0][0]))
+}
+
+// parseKeep parses a KEEP value like "3650d,3650d,3650d" or "5256000m,5256000m,5256000m".
+func parseKeep(s string) (time.Duration, error) {
+	var keep time.Duration
+	for _, v := range strings.Split(s, ",") {
+		d, err := parseDurationLiteral(strings.TrimSpace(v))
+		if err != nil {
+			return 0, fmt.Errorf("invalid keep %q: %v", s, err)
+		}
+		if d > keep {
+			keep = d
+		}
+	}
+	return keep, nil
+}
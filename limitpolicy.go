@@ -0,0 +1,56 @@
+package tsdbclient
+
+import "context"
+
+// QueryOrigin tells where a query comes from, for the LimitPolicy.
+type QueryOrigin int8
+
+const (
+	_ QueryOrigin = iota
+	// BatchOrigin marks the queries of batch jobs, the default.
+	BatchOrigin
+	// InteractiveOrigin marks the queries typed or clicked by a user.
+	InteractiveOrigin
+	// AdminOrigin marks the queries of admin tools and consoles.
+	AdminOrigin
+)
+
+type queryOriginKey struct{}
+
+// WithQueryOrigin marks the queries issued with the returned context as coming from origin.
+func WithQueryOrigin(ctx context.Context, origin QueryOrigin) context.Context {
+	return context.WithValue(ctx, queryOriginKey{}, origin)
+}
+
+// QueryOriginFrom returns the origin set by WithQueryOrigin, BatchOrigin if none.
+func QueryOriginFrom(ctx context.Context) QueryOrigin {
+	if origin, ok := ctx.Value(queryOriginKey{}).(QueryOrigin); ok {
+		return origin
+	}
+	return BatchOrigin
+}
+
+// LimitPolicy returns the LIMIT appended to a select of ctx without one, zero
+// leaves the query as is.
+type LimitPolicy func(ctx context.Context, q Query) int
+
+// policyLimit returns the LIMIT the policy appends to q under ctx, zero when q
+// has a limit of its own or MaxRows.
+func policyLimit(ctx context.Context, q Query, policy LimitPolicy) int {
+	if policy == nil || q.MaxRows > 0 || hasLimit(q.Command) {
+		return 0
+	}
+	return max(policy(ctx, q), 0)
+}
+
+// InteractiveLimit is a LimitPolicy limiting the selects of interactive and
+// admin contexts to n rows, leaving batch jobs untouched.
+func InteractiveLimit(n int) LimitPolicy {
+	return func(ctx context.Context, q Query) int {
+		switch QueryOriginFrom(ctx) {
+		case InteractiveOrigin, AdminOrigin:
+			return n
+		}
+		return 0
+	}
+}
@@ -0,0 +1,319 @@
+package tsdbclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jeagle929/tsdbclient/models"
+)
+
+// FieldDistribution generates the value of a field for a series at a time.
+type FieldDistribution func(r *rand.Rand, series int, t time.Time) interface{}
+
+// UniformFloat draws floats uniformly from [min, max).
+func UniformFloat(min, max float64) FieldDistribution {
+	return func(r *rand.Rand, _ int, _ time.Time) interface{} {
+		return min + r.Float64()*(max-min)
+	}
+}
+
+// NormalFloat draws floats from a normal distribution.
+func NormalFloat(mean, stddev float64) FieldDistribution {
+	return func(r *rand.Rand, _ int, _ time.Time) interface{} {
+		return mean + r.NormFloat64()*stddev
+	}
+}
+
+// UniformInt draws integers uniformly from [min, max), always min when max <= min.
+func UniformInt(min, max int64) FieldDistribution {
+	return func(r *rand.Rand, _ int, _ time.Time) interface{} {
+		if max <= min {
+			return min
+		}
+		return min + r.Int63n(max-min)
+	}
+}
+
+// SineWave follows a sine of the period, phase shifted per series, with uniform noise.
+// Without a positive period the sine stays at its value at the series phase.
+func SineWave(amplitude float64, period time.Duration, noise float64) FieldDistribution {
+	return func(r *rand.Rand, series int, t time.Time) interface{} {
+		phase := float64(series)
+		if period > 0 {
+			phase += float64(t.UnixNano()%int64(period)) / float64(period) * 2 * math.Pi
+		}
+		return amplitude*math.Sin(phase) + (r.Float64()*2-1)*noise
+	}
+}
+
+// Generator produces synthetic points of one measurement. Points are generated round
+// robin over the series, every series advancing by Step. Generator is NOT thread-safe.
+type Generator struct {
+	// Measurement of the points.
+	Measurement string
+
+	// Series is the number of distinct series, defaults to 1.
+	Series int
+
+	// TagKey is the tag distinguishing the series, defaults to "series".
+	TagKey string
+
+	// Fields generates the field values.
+	Fields map[string]FieldDistribution
+
+	// Start is the timestamp of the first point of every series, defaults to now.
+	Start time.Time
+
+	// End stops the generation once reached, optional.
+	End time.Time
+
+	// Step is the time between two points of a series, defaults to 1s.
+	Step time.Duration
+
+	// Seed of the random source.
+	Seed int64
+
+	rnd    *rand.Rand
+	keys   []string
+	next   int
+	cursor time.Time
+}
+
+// ErrGeneratorDone is returned once the generator reached End.
+var ErrGeneratorDone = errors.New("generator reached the end of its time range")
+
+func (g *Generator) init() error {
+	if g.rnd != nil {
+		return nil
+	}
+	if len(g.Measurement) == 0 {
+		return errors.New("invalid args: generator `Measurement` is empty")
+	}
+	if len(g.Fields) == 0 {
+		return errors.New("invalid args: generator `Fields` is empty")
+	}
+	if g.Series <= 0 {
+		g.Series = 1
+	}
+	if len(g.TagKey) == 0 {
+		g.TagKey = "series"
+	}
+	if g.Start.IsZero() {
+		g.Start = time.Now()
+	}
+	if g.Step <= 0 {
+		g.Step = time.Second
+	}
+	for k := range g.Fields {
+		g.keys = append(g.keys, k)
+	}
+	sort.Strings(g.keys)
+	g.rnd = rand.New(rand.NewSource(g.Seed))
+	g.cursor = g.Start
+	return nil
+}
+
+// Points generates the next n points, fewer once End is reached.
+func (g *Generator) Points(n int) ([]*DataPoint, error) {
+	if err := g.init(); err != nil {
+		return nil, err
+	}
+
+	points := make([]*DataPoint, 0, n)
+	for len(points) < n {
+		if !g.End.IsZero() && !g.cursor.Before(g.End) {
+			if len(points) == 0 {
+				return nil, ErrGeneratorDone
+			}
+			break
+		}
+
+		fields := make(map[string]interface{}, len(g.keys))
+		for _, k := range g.keys {
+			fields[k] = g.Fields[k](g.rnd, g.next, g.cursor)
+		}
+		p, err := NewDataPoint(g.Measurement,
+			map[string]string{g.TagKey: fmt.Sprintf("s%d", g.next)}, fields, g.cursor)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+
+		if g.next++; g.next == g.Series {
+			g.next = 0
+			g.cursor = g.cursor.Add(g.Step)
+		}
+	}
+	return points, nil
+}
+
+// LineProtocol generates the next n points as line protocol of the precision.
+func (g *Generator) LineProtocol(n int, precision string) ([]byte, error) {
+	points, err := g.Points(n)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	for _, p := range points {
+		b.WriteString(p.PrecisionString(precision))
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+// LoadTest writes generated points to a client and reports the achieved throughput.
+type LoadTest struct {
+	// Client receives the writes.
+	Client TSDBClient
+
+	// Generator produces the points.
+	Generator *Generator
+
+	// Rate is the target of points per second, 0 for unlimited.
+	Rate int
+
+	// BatchSize is the number of points per write, defaults to 1000.
+	BatchSize int
+
+	// Concurrency is the number of concurrent writers, defaults to 1.
+	Concurrency int
+
+	// Duration stops the test once elapsed, optional.
+	Duration time.Duration
+}
+
+// LoadTestReport summarizes a load test.
+type LoadTestReport struct {
+	Points     int64
+	Batches    int64
+	Errors     int64
+	Elapsed    time.Duration
+	Throughput float64 // points per second
+
+	LatencyP50 time.Duration
+	LatencyP99 time.Duration
+	LatencyMax time.Duration
+
+	// FirstError is the first write error.
+	FirstError error
+}
+
+// Run writes until ctx is done, Duration elapsed or the generator is exhausted.
+func (l *LoadTest) Run(ctx context.Context) (LoadTestReport, error) {
+	var report LoadTestReport
+	if l.Client == nil || l.Generator == nil {
+		return report, errors.New("invalid args: load test `Client` or `Generator` is nil")
+	}
+	if l.BatchSize <= 0 {
+		l.BatchSize = 1000
+	}
+	if l.Concurrency <= 0 {
+		l.Concurrency = 1
+	}
+	if l.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.Duration)
+		defer cancel()
+	}
+
+	batches := make(chan models.Points, l.Concurrency)
+	var genErr error
+	go func() {
+		defer close(batches)
+
+		var interval time.Duration
+		if l.Rate > 0 {
+			interval = time.Duration(float64(time.Second) * float64(l.BatchSize) / float64(l.Rate))
+		}
+		next := time.Now()
+		for ctx.Err() == nil {
+			points, err := l.Generator.Points(l.BatchSize)
+			if err != nil {
+				if err != ErrGeneratorDone {
+					genErr = err
+				}
+				return
+			}
+			batch := make(models.Points, len(points))
+			for i, p := range points {
+				batch[i] = p.pt
+			}
+
+			if interval > 0 {
+				next = next.Add(interval)
+				if d := time.Until(next); d > 0 {
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(d):
+					}
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case batches <- batch:
+			}
+		}
+	}()
+
+	var (
+		wg        sync.WaitGroup
+		lock      sync.Mutex
+		latencies []time.Duration
+		points    atomic.Int64
+		count     atomic.Int64
+		failed    atomic.Int64
+	)
+	start := time.Now()
+	for i := 0; i < l.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				begin := time.Now()
+				err := l.Client.WriteDataBatch(batch)
+				elapsed := time.Since(begin)
+
+				count.Add(1)
+				if err != nil {
+					failed.Add(1)
+					lock.Lock()
+					if report.FirstError == nil {
+						report.FirstError = err
+					}
+					lock.Unlock()
+					continue
+				}
+				points.Add(int64(len(batch)))
+				lock.Lock()
+				latencies = append(latencies, elapsed)
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	report.Elapsed = time.Since(start)
+	report.Points = points.Load()
+	report.Batches = count.Load()
+	report.Errors = failed.Load()
+	if report.Elapsed > 0 {
+		report.Throughput = float64(report.Points) / report.Elapsed.Seconds()
+	}
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		report.LatencyP50 = latencies[len(latencies)*50/100]
+		report.LatencyP99 = latencies[len(latencies)*99/100]
+		report.LatencyMax = latencies[len(latencies)-1]
+	}
+	return report, genErr
+}
@@ -0,0 +1,188 @@
+package tsdbclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// Logger receives the internal logs of the package. The keyvals are alternating
+// keys and values, as in slog.
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+var packageLogger atomic.Value
+
+func init() {
+	packageLogger.Store(loggerHolder{stdLogger{}})
+}
+
+// loggerHolder keeps atomic.Value stores of a consistent concrete type.
+type loggerHolder struct {
+	Logger
+}
+
+// SetLogger sets the logger of the clients without their own, and of the helpers
+// not bound to a client. It defaults to the standard log package, without debug logs.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = stdLogger{}
+	}
+	packageLogger.Store(loggerHolder{l})
+}
+
+func defaultLogger() Logger {
+	return redactingLogger{packageLogger.Load().(loggerHolder).Logger}
+}
+
+// stdLogger writes to the standard log package, debug logs are dropped.
+type stdLogger struct{}
+
+func (stdLogger) print(level, msg string, keyvals []interface{}) {
+	var b strings.Builder
+	b.WriteString("[tsdbclient] ")
+	b.WriteString(level)
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	writeKeyvals(&b, keyvals)
+	log.Println(b.String())
+}
+
+func writeKeyvals(b *strings.Builder, keyvals []interface{}) {
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 < len(keyvals) {
+			fmt.Fprintf(b, " %v=%v", keyvals[i], keyvals[i+1])
+		} else {
+			fmt.Fprintf(b, " %v", keyvals[i])
+		}
+	}
+}
+
+func (stdLogger) Debug(string, ...interface{}) {}
+
+func (l stdLogger) Info(msg string, keyvals ...interface{}) {
+	l.print("INFO", msg, keyvals)
+}
+
+func (l stdLogger) Warn(msg string, keyvals ...interface{}) {
+	l.print("WARN", msg, keyvals)
+}
+
+func (l stdLogger) Error(msg string, keyvals ...interface{}) {
+	l.print("ERROR", msg, keyvals)
+}
+
+// NopLogger discards all logs.
+type NopLogger struct{}
+
+func (NopLogger) Debug(string, ...interface{}) {}
+func (NopLogger) Info(string, ...interface{})  {}
+func (NopLogger) Warn(string, ...interface{})  {}
+func (NopLogger) Error(string, ...interface{}) {}
+
+// NewSlogLogger adapts a slog.Logger, slog.Default() if nil.
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return slogLogger{l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Debug(msg string, keyvals ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelDebug, msg, keyvals...)
+}
+
+func (s slogLogger) Info(msg string, keyvals ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelInfo, msg, keyvals...)
+}
+
+func (s slogLogger) Warn(msg string, keyvals ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelWarn, msg, keyvals...)
+}
+
+func (s slogLogger) Error(msg string, keyvals ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelError, msg, keyvals...)
+}
+
+// SugaredLogger is the structured logging of zap's *zap.SugaredLogger.
+type SugaredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+// NewSugaredLogger adapts a zap sugared logger, e.g. NewSugaredLogger(zapLogger.Sugar()).
+func NewSugaredLogger(l SugaredLogger) Logger {
+	return sugaredLogger{l}
+}
+
+type sugaredLogger struct {
+	l SugaredLogger
+}
+
+func (s sugaredLogger) Debug(msg string, keyvals ...interface{}) { s.l.Debugw(msg, keyvals...) }
+func (s sugaredLogger) Info(msg string, keyvals ...interface{})  { s.l.Infow(msg, keyvals...) }
+func (s sugaredLogger) Warn(msg string, keyvals ...interface{})  { s.l.Warnw(msg, keyvals...) }
+func (s sugaredLogger) Error(msg string, keyvals ...interface{}) { s.l.Errorw(msg, keyvals...) }
+
+// LeveledLogger is the leveled logging of logrus' *logrus.Logger and *logrus.Entry.
+type LeveledLogger interface {
+	Debug(args ...interface{})
+	Info(args ...interface{})
+	Warn(args ...interface{})
+	Error(args ...interface{})
+}
+
+// NewLeveledLogger adapts a logrus logger, the keyvals are appended to the message
+// as key=value.
+func NewLeveledLogger(l LeveledLogger) Logger {
+	return leveledLogger{l}
+}
+
+type leveledLogger struct {
+	l LeveledLogger
+}
+
+func formatMessage(msg string, keyvals []interface{}) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	writeKeyvals(&b, keyvals)
+	return b.String()
+}
+
+func (s leveledLogger) Debug(msg string, keyvals ...interface{}) {
+	s.l.Debug(formatMessage(msg, keyvals))
+}
+
+func (s leveledLogger) Info(msg string, keyvals ...interface{}) {
+	s.l.Info(formatMessage(msg, keyvals))
+}
+
+func (s leveledLogger) Warn(msg string, keyvals ...interface{}) {
+	s.l.Warn(formatMessage(msg, keyvals))
+}
+
+func (s leveledLogger) Error(msg string, keyvals ...interface{}) {
+	s.l.Error(formatMessage(msg, keyvals))
+}
+
+// log returns the logger of the client, the package logger if it has none.
+// Credentials are redacted from the logs.
+func (client *tsdbClient) log() Logger {
+	if client.logger != nil {
+		return redactingLogger{client.logger}
+	}
+	return defaultLogger()
+}
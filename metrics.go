@@ -0,0 +1,344 @@
+package tsdbclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	taoserrors "github.com/taosdata/driver-go/v3/errors"
+)
+
+// LatencyBuckets are the upper bounds of the query latency histogram.
+var LatencyBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// PointAgeBuckets are the upper bounds of the point age histogram.
+var PointAgeBuckets = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour,
+	6 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+// LatencyHistogram counts latencies per bucket, Counts[i] counts the latencies
+// up to Bounds[i] and above Bounds[i-1], the last count is above all bounds.
+type LatencyHistogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+func newLatencyHistogram(bounds []time.Duration) LatencyHistogram {
+	return LatencyHistogram{Bounds: bounds, Counts: make([]uint64, len(bounds)+1)}
+}
+
+// add counts d in its bucket.
+func (h *LatencyHistogram) add(d time.Duration) {
+	i := 0
+	for i < len(h.Bounds) && d > h.Bounds[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+func (h LatencyHistogram) clone() LatencyHistogram {
+	h.Bounds = append([]time.Duration(nil), h.Bounds...)
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+// ProbeStats are the results of the LatencyProbes run with a client.
+type ProbeStats struct {
+	Samples uint64
+
+	// Failures counts the samples with an error, the probe points not written or
+	// not visible before the timeout.
+	Failures uint64
+
+	// QueryLatency and TopicLatency count the time from the write of the probe
+	// points until they were returned by a query and delivered by the probe
+	// topic, in LatencyBuckets.
+	QueryLatency LatencyHistogram
+	TopicLatency LatencyHistogram
+}
+
+// Stats are the cumulative counters of a client since it was created.
+type Stats struct {
+	Since time.Time
+
+	Writes        uint64
+	WriteErrors   uint64
+	PointsWritten uint64
+	BytesWritten  uint64
+
+	Queries      uint64
+	QueryErrors  uint64
+	QueryLatency LatencyHistogram
+
+	// PointAge counts the age of the points written, the time from their timestamp
+	// to their write, in PointAgeBuckets. Late data shows in the upper buckets,
+	// points with timestamps in the future count as 0.
+	PointAge LatencyHistogram
+
+	// ErrorsByCode counts the failed writes and queries by TDengine error code in
+	// hex, "http_<status>" for other HTTP failures, "transport" for network
+	// errors, "canceled" for the context ones and "other".
+	ErrorsByCode map[string]uint64
+
+	Subscriptions []SubscriptionStats
+
+	// WriteAPIs are the operating points of the open WriteAPIs.
+	WriteAPIs []BatchingStats
+
+	Probes ProbeStats
+}
+
+// PointsPerSecond returns the average write rate since the client was created.
+func (s Stats) PointsPerSecond() float64 {
+	d := time.Since(s.Since).Seconds()
+	if d <= 0 {
+		return 0
+	}
+	return float64(s.PointsWritten) / d
+}
+
+type clientMetrics struct {
+	since time.Time
+
+	writes        atomic.Uint64
+	writeErrors   atomic.Uint64
+	pointsWritten atomic.Uint64
+	bytesWritten  atomic.Uint64
+
+	queries     atomic.Uint64
+	queryErrors atomic.Uint64
+
+	lock          sync.Mutex
+	latencyCounts []uint64
+	latencyCount  uint64
+	latencySum    time.Duration
+	errorsByCode  map[string]uint64
+
+	ageCounts []uint64
+	ageCount  uint64
+	ageSum    time.Duration
+
+	writeAPIs []*writeAPI
+
+	probeSamples  uint64
+	probeFailures uint64
+	probeQuery    LatencyHistogram
+	probeTopic    LatencyHistogram
+}
+
+func newClientMetrics() *clientMetrics {
+	return &clientMetrics{
+		since:         time.Now(),
+		latencyCounts: make([]uint64, len(LatencyBuckets)+1),
+		errorsByCode:  make(map[string]uint64),
+		ageCounts:     make([]uint64, len(PointAgeBuckets)+1),
+		probeQuery:    newLatencyHistogram(LatencyBuckets),
+		probeTopic:    newLatencyHistogram(LatencyBuckets),
+	}
+}
+
+func (m *clientMetrics) recordWrite(bp BatchPoints, err error) {
+	m.writes.Add(1)
+	if err != nil {
+		m.writeErrors.Add(1)
+		m.recordError(err)
+		return
+	}
+
+	var size int
+	now := time.Now()
+	m.lock.Lock()
+	for _, p := range bp.Points() {
+		if p == nil {
+			continue
+		}
+		size += p.pt.StringSize() + 1
+		if !p.Time().IsZero() {
+			m.recordAge(max(now.Sub(p.Time()), 0))
+		}
+	}
+	m.lock.Unlock()
+	m.pointsWritten.Add(uint64(len(bp.Points())))
+	m.bytesWritten.Add(uint64(size))
+}
+
+func (m *clientMetrics) recordProbe(sample LatencySample) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.probeSamples++
+	if sample.Err != nil {
+		m.probeFailures++
+	}
+	if sample.Query > 0 {
+		m.probeQuery.add(sample.Query)
+	}
+	if sample.Topic > 0 {
+		m.probeTopic.add(sample.Topic)
+	}
+}
+
+func (m *clientMetrics) addWriteAPI(w *writeAPI) {
+	m.lock.Lock()
+	m.writeAPIs = append(m.writeAPIs, w)
+	m.lock.Unlock()
+}
+
+func (m *clientMetrics) removeWriteAPI(w *writeAPI) {
+	m.lock.Lock()
+	m.writeAPIs = slices.DeleteFunc(m.writeAPIs, func(e *writeAPI) bool { return e == w })
+	m.lock.Unlock()
+}
+
+// recordAge counts the age of a point written, m.lock must be held.
+func (m *clientMetrics) recordAge(age time.Duration) {
+	i := 0
+	for i < len(PointAgeBuckets) && age > PointAgeBuckets[i] {
+		i++
+	}
+	m.ageCounts[i]++
+	m.ageCount++
+	m.ageSum += age
+}
+
+func (m *clientMetrics) recordQuery(d time.Duration, resp *Response, err error) {
+	m.queries.Add(1)
+
+	m.lock.Lock()
+	i := 0
+	for i < len(LatencyBuckets) && d > LatencyBuckets[i] {
+		i++
+	}
+	m.latencyCounts[i]++
+	m.latencyCount++
+	m.latencySum += d
+	m.lock.Unlock()
+
+	if err == nil && resp != nil && resp.Code != 0 {
+		m.queryErrors.Add(1)
+		m.recordCode("0x" + strconv.FormatInt(int64(resp.Code), 16))
+	} else if err != nil {
+		m.queryErrors.Add(1)
+		m.recordError(err)
+	}
+}
+
+func (m *clientMetrics) recordError(err error) {
+	if code, ok := taosErrorCode(err); ok {
+		m.recordCode("0x" + strconv.FormatInt(int64(code), 16))
+		return
+	}
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr):
+		m.recordCode("transport")
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		m.recordCode("canceled")
+	default:
+		var statusErr *httpStatusError
+		if errors.As(err, &statusErr) {
+			m.recordCode("http_" + strconv.Itoa(statusErr.code))
+		} else {
+			m.recordCode("other")
+		}
+	}
+}
+
+// taosErrorCode returns the TDengine error code of err, from the driver or from
+// the body of an error response of taosAdapter.
+func taosErrorCode(err error) (int, bool) {
+	var taosErr *taoserrors.TaosError
+	if errors.As(err, &taosErr) {
+		return int(taosErr.Code), true
+	}
+	var statusErr *httpStatusError
+	var body adapterError
+	if errors.As(err, &statusErr) && json.Unmarshal([]byte(statusErr.msg), &body) == nil && body.Code != 0 {
+		return body.Code, true
+	}
+	return 0, false
+}
+
+func (m *clientMetrics) recordCode(code string) {
+	m.lock.Lock()
+	m.errorsByCode[code]++
+	m.lock.Unlock()
+}
+
+func (m *clientMetrics) snapshot() Stats {
+	s := Stats{
+		Since:         m.since,
+		Writes:        m.writes.Load(),
+		WriteErrors:   m.writeErrors.Load(),
+		PointsWritten: m.pointsWritten.Load(),
+		BytesWritten:  m.bytesWritten.Load(),
+		Queries:       m.queries.Load(),
+		QueryErrors:   m.queryErrors.Load(),
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	s.QueryLatency = LatencyHistogram{
+		Bounds: append([]time.Duration(nil), LatencyBuckets...),
+		Counts: append([]uint64(nil), m.latencyCounts...),
+		Count:  m.latencyCount,
+		Sum:    m.latencySum,
+	}
+	s.PointAge = LatencyHistogram{
+		Bounds: append([]time.Duration(nil), PointAgeBuckets...),
+		Counts: append([]uint64(nil), m.ageCounts...),
+		Count:  m.ageCount,
+		Sum:    m.ageSum,
+	}
+	s.ErrorsByCode = make(map[string]uint64, len(m.errorsByCode))
+	for k, v := range m.errorsByCode {
+		s.ErrorsByCode[k] = v
+	}
+	for _, w := range m.writeAPIs {
+		s.WriteAPIs = append(s.WriteAPIs, w.batching.stats())
+	}
+	s.Probes = ProbeStats{
+		Samples:      m.probeSamples,
+		Failures:     m.probeFailures,
+		QueryLatency: m.probeQuery.clone(),
+		TopicLatency: m.probeTopic.clone(),
+	}
+	return s
+}
+
+// instrumentedClient records the queries of the wrapped Client.
+type instrumentedClient struct {
+	Client
+	metrics *clientMetrics
+}
+
+func (c *instrumentedClient) Query(q Query) (*Response, error) {
+	return c.QueryContext(context.Background(), q)
+}
+
+func (c *instrumentedClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	start := time.Now()
+	resp, err := c.Client.QueryContext(ctx, q)
+	c.metrics.recordQuery(time.Since(start), resp, err)
+	return resp, err
+}
+
+// GetStats returns the counters of the default client.
+func GetStats() Stats {
+	return clientWrapper.Stats()
+}
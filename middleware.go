@@ -0,0 +1,25 @@
+package tsdbclient
+
+import "net/http"
+
+// Middleware wraps the transport of the HTTP client, e.g. to add headers, sign
+// requests, log them or inject faults.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to http.RoundTripper, for writing middlewares.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// chainMiddlewares wraps rt with the middlewares, the first one is the outermost
+// and sees the requests first.
+func chainMiddlewares(rt http.RoundTripper, middlewares []Middleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i] != nil {
+			rt = middlewares[i](rt)
+		}
+	}
+	return rt
+}
@@ -451,7 +451,7 @@ func parsePoint(buf []byte, defaultTime time.Time, precision string) (Point, err
 func GetPrecisionMultiplier(precision string) int64 {
 	d := time.Nanosecond
 	switch precision {
-	case "u":
+	case "u", "us", "µs", "μs":
 		d = time.Microsecond
 	case "ms":
 		d = time.Millisecond
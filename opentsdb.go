@@ -0,0 +1,187 @@
+package tsdbclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteProtocol is the format points are written to taosAdapter in.
+type WriteProtocol string
+
+const (
+	// InfluxLineProtocol writes to the influxdb endpoint.
+	InfluxLineProtocol WriteProtocol = ""
+	// OpenTSDBTelnet writes to the OpenTSDB telnet endpoint, numeric and bool fields
+	// only, tags without spaces or '='.
+	OpenTSDBTelnet WriteProtocol = "opentsdb-telnet"
+	// OpenTSDBJSON writes to the OpenTSDB json endpoint.
+	OpenTSDBJSON WriteProtocol = "opentsdb-json"
+)
+
+type writeProtocolKey struct{}
+
+// withWriteProtocol returns a context writing in protocol instead of the one of
+// the client, for the writes of the client itself like the audit rows.
+func withWriteProtocol(ctx context.Context, protocol WriteProtocol) context.Context {
+	return context.WithValue(ctx, writeProtocolKey{}, protocol)
+}
+
+// writeProtocol returns the protocol of the writes under ctx.
+func (c *client) writeProtocol(ctx context.Context) WriteProtocol {
+	if protocol, ok := ctx.Value(writeProtocolKey{}).(WriteProtocol); ok {
+		return protocol
+	}
+	return c.protocol
+}
+
+const (
+	OpenTSDBTelnetURL = "opentsdb/v1/put/telnet"
+	OpenTSDBJSONURL   = "opentsdb/v1/put/json"
+)
+
+// openTSDBMetric names the metric of a field: the measurement for a field named
+// "value", else measurement_field, as OpenTSDB metrics have a single value.
+func openTSDBMetric(measurement, field string) string {
+	if field == "value" {
+		return measurement
+	}
+	return measurement + "_" + field
+}
+
+// openTSDBTimestamp returns the timestamp of the point in seconds for precision
+// "s", else in milliseconds, the units of OpenTSDB: finer precisions are truncated
+// to milliseconds, an error with StrictPrecision. Points without time are stamped
+// with now, the server would store a 0 timestamp as is.
+func openTSDBTimestamp(p *DataPoint, bp BatchPoints, now time.Time) (int64, error) {
+	t := p.Time()
+	if t.IsZero() {
+		t = now
+	}
+	unit := time.Millisecond
+	if bp.Precision() == "s" {
+		unit = time.Second
+	}
+	if bp.StrictPrecision() && t.UnixNano()%int64(unit) != 0 {
+		return 0, fmt.Errorf("%w: point %s at %s, opentsdb precision %s",
+			ErrPrecisionTruncated, p.Name(), t.Format(time.RFC3339Nano), unit)
+	}
+	if unit == time.Second {
+		return t.Unix(), nil
+	}
+	return t.UnixMilli(), nil
+}
+
+// checkOpenTSDBTelnetTag rejects the tags the telnet format can't hold, those empty
+// or with a space or '='.
+func checkOpenTSDBTelnetTag(k, v string) error {
+	if len(k) == 0 || len(v) == 0 || strings.ContainsAny(k, " =\t\r\n") || strings.ContainsAny(v, " =\t\r\n") {
+		return fmt.Errorf("invalid opentsdb telnet tag %q=%q", k, v)
+	}
+	return nil
+}
+
+func sortedFieldNames(fields map[string]interface{}) []string {
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func encodeOpenTSDBTelnet(w io.Writer, bp BatchPoints) error {
+	var b strings.Builder
+	now := time.Now()
+	for _, p := range bp.Points() {
+		if p == nil {
+			continue
+		}
+		fields, err := p.Fields()
+		if err != nil {
+			return err
+		}
+		ts, err := openTSDBTimestamp(p, bp, now)
+		if err != nil {
+			return err
+		}
+
+		tags := p.Tags()
+		tagNames := make([]string, 0, len(tags))
+		for k, v := range tags {
+			if err := checkOpenTSDBTelnetTag(k, v); err != nil {
+				return fmt.Errorf("point %s: %w", p.Name(), err)
+			}
+			tagNames = append(tagNames, k)
+		}
+		sort.Strings(tagNames)
+
+		for _, f := range sortedFieldNames(fields) {
+			var value string
+			switch v := fields[f].(type) {
+			case float64:
+				value = strconv.FormatFloat(v, 'g', -1, 64)
+			case int64:
+				value = strconv.FormatInt(v, 10)
+			case uint64:
+				value = strconv.FormatUint(v, 10)
+			case bool:
+				value = strconv.FormatBool(v)
+			default:
+				return fmt.Errorf("unsupported opentsdb telnet field type %T of %s", v, f)
+			}
+
+			b.Reset()
+			b.WriteString(openTSDBMetric(p.Name(), f))
+			b.WriteString(" " + strconv.FormatInt(ts, 10) + " " + value)
+			for _, k := range tagNames {
+				b.WriteString(" " + k + "=" + tags[k])
+			}
+			b.WriteByte('\n')
+			if _, err := io.WriteString(w, b.String()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type openTSDBPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     interface{}       `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+func encodeOpenTSDBJSON(w io.Writer, bp BatchPoints) error {
+	var points []openTSDBPoint
+	now := time.Now()
+	for _, p := range bp.Points() {
+		if p == nil {
+			continue
+		}
+		fields, err := p.Fields()
+		if err != nil {
+			return err
+		}
+		ts, err := openTSDBTimestamp(p, bp, now)
+		if err != nil {
+			return err
+		}
+		tags := p.Tags()
+		for _, f := range sortedFieldNames(fields) {
+			points = append(points, openTSDBPoint{
+				Metric:    openTSDBMetric(p.Name(), f),
+				Timestamp: ts,
+				Value:     fields[f],
+				Tags:      tags,
+			})
+		}
+	}
+	return json.NewEncoder(w).Encode(points)
+}
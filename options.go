@@ -1,12 +1,17 @@
 package tsdbclient
 
 import (
+	"crypto/tls"
 	"fmt"
+	"net/http"
 	"os"
+	"time"
 )
 
 type DbOptions struct {
 	DatabaseAddr  string
+	ReadAddr      string
+	WriteAddr     string
 	DatabaseName  string
 	PrecisionUnit string
 	DatabaseUser  string
@@ -16,6 +21,98 @@ type DbOptions struct {
 	Timestamp     int64
 
 	DefaultNumberValue interface{}
+
+	WriteBackend BackendMode
+
+	DedupWindow time.Duration
+	DedupSize   int
+
+	QueryGovernor *QueryGovernor
+
+	Derivations []string
+
+	WriteRetry RetryPolicy
+
+	MissingTimestamp TimestampPolicy
+
+	TimestampBounds TimestampBounds
+
+	DropExpired bool
+	OnExpired   func(points []*DataPoint)
+
+	AdjustClockSkew bool
+
+	TokenAuth    bool
+	TokenRefresh time.Duration
+
+	SpoolDir      string
+	SpoolMaxBytes int64
+	SpoolKeys     SpoolKeyProvider
+
+	PingQuery string
+
+	Logger Logger
+
+	Audit *AuditConfig
+
+	DialControl DialControl
+	LogDials    bool
+
+	Middlewares []Middleware
+
+	WriteEncoding ContentEncoding
+	GzipLevel     int
+
+	TLSConfig *tls.Config
+	StrictTLS bool
+	SPKIPins  []string
+
+	ReplicaAddrs    []string
+	QueryCacheTTL   time.Duration
+	QueryCacheSize  int
+	CollapseQueries bool
+
+	WriteChecksum       bool
+	VerifyContentLength bool
+
+	AutoCreateTables bool
+
+	DatabaseCacheSize int
+
+	DashboardConcurrency int
+
+	WriteProtocol WriteProtocol
+
+	Transport ClientTransport
+
+	LimitPolicy LimitPolicy
+
+	TimestampFormat   TimestampFormat
+	TimestampLocation *time.Location
+
+	MaxPointsPerRequest int
+	MaxBodySize         int
+	WriteConcurrency    int
+
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	KeepAlive           time.Duration
+	DisableKeepAlives   bool
+
+	HTTPClient   *http.Client
+	RoundTripper http.RoundTripper
+
+	ArrayExplodes    map[string][]ArrayExplode
+	FlattenSeparator string
+
+	WriteTTL     int
+	TableNameKey string
+
+	SchemaCacheTTL time.Duration
+
+	QuarantineSize int
 }
 
 type DBOption func(*DbOptions)
@@ -26,6 +123,20 @@ func DatabaseAddr(u string) DBOption {
 	}
 }
 
+// ReadAddr sends the queries to u instead of DatabaseAddr.
+func ReadAddr(u string) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.ReadAddr = u
+	}
+}
+
+// WriteAddr sends the writes to u instead of DatabaseAddr.
+func WriteAddr(u string) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.WriteAddr = u
+	}
+}
+
 func DatabaseName(d string) DBOption {
 	return func(dbOpts *DbOptions) {
 		dbOpts.DatabaseName = d
@@ -68,6 +179,370 @@ func DefaultNumberValue[T Number](v T) DBOption {
 	}
 }
 
+func WriteBackend(b BackendMode) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.WriteBackend = b
+	}
+}
+
+// DedupWindow drops points whose series and timestamp were already written
+// within the window, remembering at most size points (0 for the default).
+func DedupWindow(window time.Duration, size int) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.DedupWindow = window
+		dbOpts.DedupSize = size
+	}
+}
+
+// Governor enforces the query quotas of g on the client.
+func Governor(g *QueryGovernor) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.QueryGovernor = g
+	}
+}
+
+// Derive adds derived columns to the rows returned by ReadData,
+// each expression is of the form "power = voltage * current", see ParseDerivation.
+func Derive(exprs ...string) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.Derivations = append(dbOpts.Derivations, exprs...)
+	}
+}
+
+func WriteRetry(p RetryPolicy) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.WriteRetry = p
+	}
+}
+
+// MissingTimestamp sets how points written without a timestamp are handled,
+// defaults to TimestampServerAssign.
+func MissingTimestamp(p TimestampPolicy) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.MissingTimestamp = p
+	}
+}
+
+// TimestampRange fails writes of batches containing points more than maxFuture ahead
+// of or maxPast behind the client time, zero disables a bound.
+func TimestampRange(maxFuture, maxPast time.Duration) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.TimestampBounds = TimestampBounds{MaxFuture: maxFuture, MaxPast: maxPast}
+	}
+}
+
+// AdjustClockSkew corrects the timestamps assigned by TimestampClientNow and the
+// TimestampRange bounds by the clock offset measured by SkewCheck.
+func AdjustClockSkew(a bool) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.AdjustClockSkew = a
+	}
+}
+
+// TokenAuth logs in once and authenticates HTTP requests with the cached token.
+// Subscriptions still log in with the credentials, the tmq websocket has no token.
+func TokenAuth(t bool) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.TokenAuth = t
+	}
+}
+
+// TokenRefresh is how long a token of TokenAuth is used before logging in again.
+func TokenRefresh(d time.Duration) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.TokenRefresh = d
+	}
+}
+
+// Spool keeps the batches failing because the server is unreachable in files of dir,
+// bounded to maxBytes if positive, and replays them once it is reachable again.
+func Spool(dir string, maxBytes int64) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.SpoolDir = dir
+		dbOpts.SpoolMaxBytes = maxBytes
+	}
+}
+
+// SpoolEncryption encrypts the batches spooled with AES-GCM, with the keys of
+// keys, such as StaticSpoolKey. Segments spooled before are still replayed.
+func SpoolEncryption(keys SpoolKeyProvider) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.SpoolKeys = keys
+	}
+}
+
+// UseLogger sets the logger of the client, defaults to the logger set by SetLogger.
+func UseLogger(l Logger) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.Logger = l
+	}
+}
+
+// WriteAudit records the metadata of every write batch in an audit super table.
+func WriteAudit(conf AuditConfig) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.Audit = &conf
+	}
+}
+
+// EgressPolicy vets the connections of the HTTP client with control, and logs the
+// address each connection resolved to if logDials.
+func EgressPolicy(control DialControl, logDials bool) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.DialControl = control
+		dbOpts.LogDials = logDials
+	}
+}
+
+// WriteCompression compresses the HTTP writes with the encoding, gzipLevel sets the
+// level of GzipEncoding, 0 for the default and GzipNoCompression for none.
+func WriteCompression(encoding ContentEncoding, gzipLevel int) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.WriteEncoding = encoding
+		dbOpts.GzipLevel = gzipLevel
+	}
+}
+
+// Middlewares wraps the transport of the HTTP client, the first one is the outermost.
+func Middlewares(mw ...Middleware) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.Middlewares = append(dbOpts.Middlewares, mw...)
+	}
+}
+
+// TLS sets the TLS config of the HTTP client.
+func TLS(conf *tls.Config) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.TLSConfig = conf
+	}
+}
+
+// StrictTLS enforces the strict TLS mode of HTTPConfig.StrictTLS, the server
+// certificates are pinned to the CA of the TLS config or to the public keys.
+func StrictTLS(spkiPins ...string) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.StrictTLS = true
+		dbOpts.SPKIPins = spkiPins
+	}
+}
+
+// PingQuery sets the health check query of Ping, defaults to DefaultPingQuery.
+func PingQuery(q string) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.PingQuery = q
+	}
+}
+
+// ReplicaAddrs sets read replicas serving the queries with a MaxStaleness.
+func ReplicaAddrs(addrs ...string) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.ReplicaAddrs = addrs
+	}
+}
+
+// QueryCache caches the results of read-only queries for ttl, at most size of
+// them, defaults to 256. Queries opt out with NoCache.
+func QueryCache(ttl time.Duration, size int) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.QueryCacheTTL = ttl
+		dbOpts.QueryCacheSize = size
+	}
+}
+
+// CollapseQueries sends the identical read-only queries running at the same time
+// as one request, the callers share its result. Queries opt out with NoCache.
+func CollapseQueries() DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.CollapseQueries = true
+	}
+}
+
+// DropExpired drops the points older than the KEEP of the database before writing,
+// instead of the server rejecting the whole batch. The dropped points are passed
+// to onExpired if not nil.
+func DropExpired(onExpired func(points []*DataPoint)) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.DropExpired = true
+		dbOpts.OnExpired = onExpired
+	}
+}
+
+// WriteChecksum sends the SHA-256 of write payloads in a Content-Digest header.
+func WriteChecksum(c bool) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.WriteChecksum = c
+	}
+}
+
+// VerifyContentLength fails responses whose body length differs from their Content-Length.
+func VerifyContentLength(v bool) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.VerifyContentLength = v
+	}
+}
+
+// AutoCreateTables creates the missing super tables from the tags and fields of
+// the points when a write fails with a missing table, then retries the write once.
+func AutoCreateTables(a bool) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.AutoCreateTables = a
+	}
+}
+
+// DatabaseCacheSize bounds the number of clients kept by ForDatabase, defaults to 16.
+func DatabaseCacheSize(n int) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.DatabaseCacheSize = n
+	}
+}
+
+// DashboardConcurrency bounds the queries FetchDashboard runs at once, defaults to 8.
+func DashboardConcurrency(n int) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.DashboardConcurrency = n
+	}
+}
+
+// WriteFormat selects the protocol of HTTP writes, see WriteProtocol.
+func WriteFormat(p WriteProtocol) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.WriteProtocol = p
+	}
+}
+
+// Transport selects the connection to the server, REST by default or websocket
+// through driver-go, see ClientTransport. The websocket transport supports none
+// of the TLS, dial, connection pool and write settings of REST, the client
+// reports an error when one is set, see NewWebsocketClient.
+func Transport(t ClientTransport) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.Transport = t
+	}
+}
+
+// DefaultLimit appends the LIMIT returned by policy to the selects lacking one,
+// such as InteractiveLimit(1000) for the queries of WithQueryOrigin(ctx, InteractiveOrigin).
+func DefaultLimit(policy LimitPolicy) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.LimitPolicy = policy
+	}
+}
+
+// SplitWrites sends the batches beyond maxPoints points or maxBodySize bytes of
+// body in several requests, concurrency at once. Zero disables a limit.
+func SplitWrites(maxPoints, maxBodySize, concurrency int) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.MaxPointsPerRequest = maxPoints
+		dbOpts.MaxBodySize = maxBodySize
+		dbOpts.WriteConcurrency = concurrency
+	}
+}
+
+// ExplodeArrayField expands an array field of the points of measurement written
+// by WriteData, into indexed fields or a point per element, e.g. the 16 channel
+// readings of a device sent as one array:
+//
+//	ExplodeArrayField("meters", ArrayExplode{Field: "readings", Prefix: "ch"})
+func ExplodeArrayField(measurement string, e ArrayExplode) DBOption {
+	return func(dbOpts *DbOptions) {
+		if dbOpts.ArrayExplodes == nil {
+			dbOpts.ArrayExplodes = make(map[string][]ArrayExplode)
+		}
+		dbOpts.ArrayExplodes[measurement] = append(dbOpts.ArrayExplodes[measurement], e)
+	}
+}
+
+// ConnectionPool sizes the connections kept to the server, see the fields of the
+// same names of HTTPConfig. Zero values use the defaults.
+func ConnectionPool(maxIdle, maxIdlePerHost, maxPerHost int, idleTimeout time.Duration) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.MaxIdleConns = maxIdle
+		dbOpts.MaxIdleConnsPerHost = maxIdlePerHost
+		dbOpts.MaxConnsPerHost = maxPerHost
+		dbOpts.IdleConnTimeout = idleTimeout
+	}
+}
+
+// TCPKeepAlive sets the period of the TCP keep-alive probes, a negative d disables them.
+func TCPKeepAlive(d time.Duration) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.KeepAlive = d
+	}
+}
+
+// DisableKeepAlives opens a connection per request.
+func DisableKeepAlives() DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.DisableKeepAlives = true
+	}
+}
+
+// HTTPClient sends the requests through hc, such as a client instrumented or set
+// up for a corporate proxy, instead of one created from the options.
+func HTTPClient(hc *http.Client) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.HTTPClient = hc
+	}
+}
+
+// HTTPRoundTripper sends the requests through rt instead of a transport created
+// from the options.
+func HTTPRoundTripper(rt http.RoundTripper) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.RoundTripper = rt
+	}
+}
+
+// Flatten accepts nested maps in the fields of WriteData, such as decoded device
+// JSON, and writes their leaves as fields named by their path joined with sep.
+// The columns named with "." must be quoted in queries, "_" is the usual separator.
+func Flatten(sep string) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.FlattenSeparator = sep
+	}
+}
+
+// WriteTTL sets the time to live in days of the child tables created by the
+// writes of the client, see BatchPointsConfig.TTL.
+func WriteTTL(days int) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.WriteTTL = days
+	}
+}
+
+// TableNameKey names the child tables created by the writes of the client after
+// the value of tag, see BatchPointsConfig.TableNameKey.
+func TableNameKey(tag string) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.TableNameKey = tag
+	}
+}
+
+// SchemaCacheTTL is how long the schema of a super table described by Schema is
+// reused, 5m by default.
+func SchemaCacheTTL(ttl time.Duration) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.SchemaCacheTTL = ttl
+	}
+}
+
+// QuarantineRejected keeps the last n points whose write was refused, by the
+// validation of the client or by the server, with the reason, see RejectedPoints.
+func QuarantineRejected(n int) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.QuarantineSize = n
+	}
+}
+
+// TimestampDecoding sets how QueryData decodes the TIMESTAMP columns, Unix seconds
+// by default. Timestamps without zone are read in loc which defaults to UTC.
+func TimestampDecoding(format TimestampFormat, loc *time.Location) DBOption {
+	return func(dbOpts *DbOptions) {
+		dbOpts.TimestampFormat = format
+		dbOpts.TimestampLocation = loc
+	}
+}
+
 type Number interface {
 	int | float64
 }
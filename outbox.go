@@ -0,0 +1,261 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeagle929/tsdbclient/models"
+)
+
+const (
+	defaultOutboxTable    = "outbox"
+	defaultOutboxInterval = time.Second
+	defaultOutboxGrace    = time.Minute
+)
+
+// OutboxEvent is a record of the outbox table, published once the points written
+// with it landed.
+type OutboxEvent struct {
+	ID      string
+	Topic   string
+	Payload string
+	Time    time.Time
+}
+
+// OutboxPublisher publishes an event to another system. An event may be published
+// again after a failure or a restart, receivers should deduplicate by ID.
+type OutboxPublisher func(ctx context.Context, event OutboxEvent) error
+
+// Outbox writes points together with an event record in an outbox super table,
+// and publishes the recorded events in order from Run. The event is written only
+// after the points were, and it is marked as published in Store only after
+// Publish succeeded, so the events of the points which landed are published at
+// least once. Each outbox records its events in a child table of its own, and
+// Run reads again the events of the Grace before the last one published: those
+// recorded late, by concurrent writes or by other processes, are published then,
+// after the newer ones.
+//
+//	o := &tsdbclient.Outbox{Client: c, Store: store, Publish: publish}
+//	go o.Run(ctx)
+//	_, err := o.Write(ctx, points, "meters.updated", `{"device":"d1001"}`)
+type Outbox struct {
+	// Client writes the points and the events, in its database.
+	Client TSDBClient
+
+	// Table is the outbox super table, "outbox" if empty. It is created by the
+	// first event with the tags topic and writer and the columns id and payload.
+	Table string
+
+	// Writer identifies the outbox in the tag writer of its events, a random id
+	// if empty. The outboxes writing the same Table must have different ones.
+	Writer string
+
+	// Store persists the time of the last event published.
+	Store CheckpointStore
+
+	// Publish publishes the events.
+	Publish OutboxPublisher
+
+	// Interval is how often Run looks for events written by other processes,
+	// 1s if zero. The events written through this outbox are published at once.
+	Interval time.Duration
+
+	// Grace is how long before the last event published Run looks for the
+	// events recorded late, 1m if zero. The events recorded later than that
+	// are not published.
+	Grace time.Duration
+
+	// Precision is the precision of the database, "ms" if empty.
+	Precision string
+
+	lock      sync.Mutex
+	last      time.Time
+	writer    string
+	published map[string]time.Time
+	notify    chan struct{}
+	once      sync.Once
+}
+
+func (o *Outbox) init() {
+	o.once.Do(func() {
+		o.notify = make(chan struct{}, 1)
+		o.writer = o.Writer
+		if len(o.writer) == 0 {
+			o.writer = uuid.NewString()
+		}
+		o.published = make(map[string]time.Time)
+	})
+}
+
+func (o *Outbox) grace() time.Duration {
+	if o.Grace <= 0 {
+		return defaultOutboxGrace
+	}
+	return o.Grace
+}
+
+func (o *Outbox) table() string {
+	if len(o.Table) == 0 {
+		return defaultOutboxTable
+	}
+	return o.Table
+}
+
+// Write writes the points, then records an event of the topic and payload for
+// the publisher. The write may be retried when it fails, the points landed
+// again replace themselves.
+func (o *Outbox) Write(ctx context.Context, points models.Points, topic, payload string) (OutboxEvent, error) {
+	if o.Client == nil {
+		return OutboxEvent{}, errors.New("invalid args: outbox `Client` is nil")
+	}
+	if len(topic) == 0 {
+		return OutboxEvent{}, errors.New("invalid args: `topic` is empty")
+	}
+	if err := ctx.Err(); err != nil {
+		return OutboxEvent{}, err
+	}
+	o.init()
+
+	if err := o.Client.WriteDataBatch(points); err != nil {
+		return OutboxEvent{}, err
+	}
+
+	event := OutboxEvent{ID: uuid.NewString(), Topic: topic, Payload: payload}
+	var err error
+	if event.Time, err = o.nextTime(); err != nil {
+		return OutboxEvent{}, err
+	}
+	p, err := NewDataPoint(o.table(), map[string]string{"topic": topic, "writer": o.writer}, map[string]interface{}{"id": event.ID, "payload": payload}, event.Time)
+	if err != nil {
+		return OutboxEvent{}, err
+	}
+	if err := o.Client.WriteDataBatch(models.Points{p.pt}); err != nil {
+		return OutboxEvent{}, fmt.Errorf("points written, event not recorded: %w", err)
+	}
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	return event, nil
+}
+
+// nextTime returns the time of a new event, after the previous one by at least a
+// unit of the precision so two events of a topic in the child table of the
+// outbox never replace each other.
+func (o *Outbox) nextTime() (time.Time, error) {
+	unit, err := precisionUnit(o.Precision)
+	if err != nil {
+		return time.Time{}, err
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	t := time.Now().Truncate(unit)
+	if !t.After(o.last) {
+		t = o.last.Add(unit)
+	}
+	o.last = t
+	return t, nil
+}
+
+// Run publishes the recorded events in order until ctx is done, it stops at the
+// first error of Publish or of the store.
+func (o *Outbox) Run(ctx context.Context) error {
+	if o.Client == nil || o.Store == nil {
+		return errors.New("invalid args: outbox `Client` or `Store` is nil")
+	}
+	if o.Publish == nil {
+		return errors.New("invalid args: outbox `Publish` is nil")
+	}
+	o.init()
+
+	interval := o.Interval
+	if interval <= 0 {
+		interval = defaultOutboxInterval
+	}
+	r := &IncrementalReader{
+		Client:    o.Client,
+		Store:     o.Store,
+		Tables:    []string{o.table()},
+		Precision: o.Precision,
+		Overlap:   o.grace(),
+		Handle:    o.publish,
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Run(ctx); err != nil && !errors.Is(err, ErrNotExistsTable) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-o.notify:
+		}
+	}
+}
+
+// publish publishes the events of resp not published yet, saving the time of each
+// one published after the watermark so a failure publishes again only the events
+// after it. The ids published are kept for the grace window, to skip the events
+// read again.
+func (o *Outbox) publish(ctx context.Context, table string, resp *Response) error {
+	watermark, _, err := o.Store.Load(ctx, table)
+	if err != nil {
+		return err
+	}
+	defer o.forget(watermark.Add(-o.grace()))
+
+	index := make(map[string]int)
+	for i, name := range resp.Iter().Columns() {
+		index[name] = i
+	}
+	for _, name := range []string{"ts", "id", "topic", "payload"} {
+		if _, ok := index[name]; !ok {
+			return fmt.Errorf("outbox column %s not found", name)
+		}
+	}
+
+	for _, row := range resp.Data {
+		if len(row) < len(index) {
+			return fmt.Errorf("outbox row has %d values, expected %d", len(row), len(index))
+		}
+		ts, err := timestampValue(row[index["ts"]])
+		if err != nil {
+			return err
+		}
+		event := OutboxEvent{Time: ts}
+		event.ID, _ = row[index["id"]].(string)
+		event.Topic, _ = row[index["topic"]].(string)
+		event.Payload, _ = row[index["payload"]].(string)
+		if _, ok := o.published[event.ID]; ok {
+			continue
+		}
+		if err := o.Publish(ctx, event); err != nil {
+			return fmt.Errorf("publish event %s: %w", event.ID, err)
+		}
+		o.published[event.ID] = ts
+		if !ts.After(watermark) {
+			continue
+		}
+		if err := o.Store.Save(ctx, table, ts); err != nil {
+			return err
+		}
+		watermark = ts
+	}
+	return nil
+}
+
+// forget drops the ids of the events published before the grace window.
+func (o *Outbox) forget(before time.Time) {
+	for id, ts := range o.published {
+		if ts.Before(before) {
+			delete(o.published, id)
+		}
+	}
+}
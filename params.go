@@ -0,0 +1,159 @@
+package tsdbclient
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ident is a parameter rendered as a backquoted identifier, e.g. a table name.
+type Ident string
+
+// NewQueryWithParameters renders the named parameters into the command. Parameters
+// are referenced as $name outside of quoted strings and identifiers, and rendered as:
+// strings and []byte as quoted literals, numbers and bools as literals, time.Time as
+// a quoted RFC3339 literal, nil as NULL, Ident as a backquoted identifier and slices
+// as a parenthesized list for IN clauses.
+func NewQueryWithParameters(command string, params map[string]interface{}) (Query, error) {
+	sql, err := renderParameters(command, params)
+	if err != nil {
+		return Query{}, err
+	}
+	return NewQuery(sql, "", ""), nil
+}
+
+func renderParameters(command string, params map[string]interface{}) (string, error) {
+	var b strings.Builder
+	b.Grow(len(command))
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := skipQuoted(command, i)
+			b.WriteString(command[i:end])
+			i = end - 1
+		case c == '$' && i+1 < len(command) && isParamChar(command[i+1]):
+			j := i + 1
+			for j < len(command) && isParamChar(command[j]) {
+				j++
+			}
+			name := command[i+1 : j]
+			v, ok := params[name]
+			if !ok {
+				return "", fmt.Errorf("missing query parameter: %s", name)
+			}
+			lit, err := formatParameter(v)
+			if err != nil {
+				return "", fmt.Errorf("query parameter %s: %v", name, err)
+			}
+			b.WriteString(lit)
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// skipQuoted returns the index after the quoted region starting at i.
+func skipQuoted(s string, i int) int {
+	q := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case q:
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+func isParamChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// QuoteString returns s as a single quoted SQL string literal.
+func QuoteString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// QuoteIdent returns s as a backquoted identifier.
+func QuoteIdent(s string) (string, error) {
+	if len(s) == 0 {
+		return "", fmt.Errorf("empty identifier")
+	}
+	if strings.ContainsAny(s, "`\x00") {
+		return "", fmt.Errorf("invalid identifier: %q", s)
+	}
+	return "`" + s + "`", nil
+}
+
+func formatParameter(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return QuoteString(x), nil
+	case []byte:
+		return QuoteString(string(x)), nil
+	case Ident:
+		return QuoteIdent(string(x))
+	case bool:
+		return strconv.FormatBool(x), nil
+	case int:
+		return strconv.FormatInt(int64(x), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(x), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(x), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(x), 10), nil
+	case int64:
+		return strconv.FormatInt(x, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(x), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(x), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(x), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(x), 10), nil
+	case uint64:
+		return strconv.FormatUint(x, 10), nil
+	case float32:
+		return formatFloat(float64(x), 32)
+	case float64:
+		return formatFloat(x, 64)
+	case time.Time:
+		return QuoteString(x.Format(time.RFC3339Nano)), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		if rv.Len() == 0 {
+			return "", fmt.Errorf("empty list")
+		}
+		items := make([]string, rv.Len())
+		for i := range items {
+			item, err := formatParameter(rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			items[i] = item
+		}
+		return "(" + strings.Join(items, ", ") + ")", nil
+	}
+	return "", fmt.Errorf("unsupported type %T", v)
+}
+
+func formatFloat(f float64, bits int) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("unsupported float value %v", f)
+	}
+	return strconv.FormatFloat(f, 'g', -1, bits), nil
+}
@@ -0,0 +1,111 @@
+package tsdbclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	// DefaultPingQuery is the health check query of PingContext, it returns the server version.
+	DefaultPingQuery = "select server_version() as version"
+
+	// defaultPingTimeout bounds pings without deadline when the client has no Timeout.
+	defaultPingTimeout = 10 * time.Second
+)
+
+// ClusterStatus is the availability of the cluster as reported by "show cluster alive".
+type ClusterStatus int8
+
+const (
+	// ClusterUnknown is reported by servers not supporting "show cluster alive".
+	ClusterUnknown ClusterStatus = iota - 1
+	ClusterUnavailable
+	ClusterAvailable
+	ClusterPartiallyAvailable
+)
+
+func (s ClusterStatus) String() string {
+	switch s {
+	case ClusterUnavailable:
+		return "unavailable"
+	case ClusterAvailable:
+		return "available"
+	case ClusterPartiallyAvailable:
+		return "partially available"
+	default:
+		return "unknown"
+	}
+}
+
+// PingResult is the result of PingContext.
+type PingResult struct {
+	// Version is the server version, set when the health check query returns a
+	// string in its first column as DefaultPingQuery does.
+	Version string
+
+	Cluster ClusterStatus
+
+	// RTT is the round-trip time of the health check query.
+	RTT time.Duration
+}
+
+// Ping will check to see if the server is up.
+// Ping returns how long the request took, the version of the server it connected to, and an error if one occurred.
+func (c *client) Ping() (time.Duration, string, error) {
+	res, err := c.PingContext(context.Background())
+	if err != nil {
+		return 0, "", err
+	}
+	return res.RTT, res.Version, nil
+}
+
+// PingContext runs the health check query, HTTPConfig.PingQuery or DefaultPingQuery,
+// and reads the cluster status. Without deadline on ctx nor Timeout on the client,
+// it gives up after 10s.
+func (c *client) PingContext(ctx context.Context) (*PingResult, error) {
+	return ping(ctx, c, c.pingQuery, c.httpClient.Timeout > 0)
+}
+
+// ping runs the health check query with c, bounded by defaultPingTimeout unless
+// the client has a timeout of its own.
+func ping(ctx context.Context, c Client, pingQuery string, hasTimeout bool) (*PingResult, error) {
+	if _, ok := ctx.Deadline(); !ok && !hasTimeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultPingTimeout)
+		defer cancel()
+	}
+
+	query := pingQuery
+	if len(query) == 0 {
+		query = DefaultPingQuery
+	}
+	now := time.Now()
+	resp, err := c.QueryContext(ctx, NewQuery(query, "", "").With(NoCache()))
+	if err != nil {
+		return nil, err
+	}
+	res := &PingResult{RTT: time.Since(now), Cluster: ClusterUnknown}
+	if resp == nil {
+		return nil, errors.New("get server version response empty")
+	}
+	if err := resp.Error(); err != nil {
+		return nil, &pingResponseError{err: err}
+	}
+	if resp.Rows > 0 && len(resp.Data[resp.Rows-1]) > 0 {
+		res.Version, _ = resp.Data[resp.Rows-1][0].(string)
+	} else if len(pingQuery) == 0 {
+		return nil, errors.New("get server version response empty")
+	}
+
+	resp, err = c.QueryContext(ctx, NewQuery("show cluster alive", "", "").With(NoCache()))
+	if err == nil && resp.Error() == nil && resp.Rows > 0 && len(resp.Data[0]) > 0 {
+		if n, ok := resp.Data[0][0].(json.Number); ok {
+			if v, err := n.Int64(); err == nil && v >= 0 && v <= 2 {
+				res.Cluster = ClusterStatus(v)
+			}
+		}
+	}
+	return res, nil
+}
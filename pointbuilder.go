@@ -0,0 +1,113 @@
+package tsdbclient
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jeagle929/tsdbclient/models"
+)
+
+// PointBuilder builds a DataPoint, validating its name, tags and fields as they
+// are added instead of at write time. Errors are kept until Build, so calls can
+// be chained:
+//
+//	p, err := NewPointBuilder("meters").Tag("location", "sh").Field("current", 1.2).At(ts).Build()
+//
+// A builder may be Reset and reused for the next point, keeping its buffers. It
+// is not safe for concurrent use.
+type PointBuilder struct {
+	name   string
+	tags   map[string]string
+	fields map[string]interface{}
+	t      time.Time
+	err    error
+}
+
+// NewPointBuilder starts a point of the measurement.
+func NewPointBuilder(measurement string) *PointBuilder {
+	b := &PointBuilder{tags: make(map[string]string), fields: make(map[string]interface{})}
+	return b.Reset(measurement)
+}
+
+// Reset starts another point of the measurement, reusing the buffers of the builder.
+func (b *PointBuilder) Reset(measurement string) *PointBuilder {
+	clear(b.tags)
+	clear(b.fields)
+	b.name, b.t, b.err = measurement, time.Time{}, nil
+	if len(measurement) == 0 {
+		b.setErr(errors.New("invalid args: measurement is empty"))
+	}
+	return b
+}
+
+func (b *PointBuilder) setErr(err error) *PointBuilder {
+	if b.err == nil {
+		b.err = err
+	}
+	return b
+}
+
+// Tag sets a tag, the value replaces the previous one of the key.
+func (b *PointBuilder) Tag(key, value string) *PointBuilder {
+	if len(key) == 0 {
+		return b.setErr(errors.New("invalid args: tag key is empty"))
+	}
+	if len(value) == 0 {
+		return b.setErr(fmt.Errorf("invalid args: tag %s value is empty", key))
+	}
+	b.tags[key] = value
+	return b
+}
+
+// Field sets a field of type bool, integer, float, string or []byte. A NaN or
+// infinite float, which the server does not store, is logged and left out.
+func (b *PointBuilder) Field(key string, value interface{}) *PointBuilder {
+	if len(key) == 0 {
+		return b.setErr(errors.New("invalid args: field key is empty"))
+	}
+	var f float64
+	switch v := value.(type) {
+	case bool, string, []byte,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64:
+		b.fields[key] = value
+		return b
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	case nil:
+		return b.setErr(fmt.Errorf("invalid args: field %s is nil", key))
+	default:
+		return b.setErr(fmt.Errorf("invalid args: field %s has unsupported type %T", key, value))
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		defaultLogger().Warn("point field dropped", "measurement", b.name, "field", key, "value", f)
+		return b
+	}
+	b.fields[key] = value
+	return b
+}
+
+// At sets the timestamp, the server assigns its time to a point without.
+func (b *PointBuilder) At(t time.Time) *PointBuilder {
+	b.t = t
+	return b
+}
+
+// Build returns the point, or the first error of the calls building it.
+func (b *PointBuilder) Build() (*DataPoint, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.fields) == 0 {
+		return nil, fmt.Errorf("invalid args: point %s has no field", b.name)
+	}
+	pt, err := models.NewPoint(b.name, models.NewTags(b.tags), b.fields, b.t)
+	if err != nil {
+		return nil, err
+	}
+	return &DataPoint{pt: pt}, nil
+}
@@ -0,0 +1,262 @@
+package tsdbclient
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/taosdata/driver-go/v3/common/param"
+	"github.com/taosdata/driver-go/v3/ws/stmt"
+)
+
+// PreparedStmt is a statement prepared once on the server through the websocket
+// stmt interface and executed with batches of bound rows. For frequent inserts
+// into known schemas it is much faster than line protocol over REST. It is not
+// safe for concurrent use.
+//
+//	s, err := client.Prepare("insert into ? using meters tags(?) values(?,?)")
+//	if err != nil {
+//		return err
+//	}
+//	defer s.Close()
+//	_ = s.SetTableName("d1001")
+//	_ = s.SetTags("california")
+//	_ = s.BindBatch([][]interface{}{{time.Now(), 10.3}})
+//	affected, err := s.Exec()
+//
+// The Go type of the values selects the column type: time.Time binds TIMESTAMP,
+// int8 to int64 and uint8 to uint64 the integers of the same size, float32 and
+// float64 FLOAT and DOUBLE, string and []byte VARCHAR, nil NULL.
+type PreparedStmt struct {
+	stmt      *stmt.Stmt
+	writer    *StmtWriter
+	precision int
+	bound     int
+}
+
+// Prepare prepares the sql through the stmt connection of the client, in its
+// database and precision.
+func (client *tsdbClient) Prepare(sql string) (*PreparedStmt, error) {
+	if len(sql) == 0 {
+		return nil, errors.New("invalid args: `sql` is empty")
+	}
+	w, err := client.getStmtWriter()
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := w.connector.Init()
+	if err != nil {
+		w.users.Done()
+		return nil, err
+	}
+	if err = s.Prepare(sql); err != nil {
+		_ = s.Close()
+		w.users.Done()
+		return nil, err
+	}
+	// the connection stays open until Close, even if the credentials change
+	return &PreparedStmt{stmt: s, writer: w, precision: w.precision}, nil
+}
+
+// Prepare prepares the sql with the default client.
+func Prepare(sql string) (*PreparedStmt, error) {
+	return clientWrapper.Prepare(sql)
+}
+
+// SetTableName sets the table of an insert into ?, the rows bound after it go to it.
+func (s *PreparedStmt) SetTableName(name string) error {
+	return s.stmt.SetTableName(name)
+}
+
+// SetTags sets the tags of an insert into ? using stable tags(...), creating the
+// child table when it doesn't exist.
+func (s *PreparedStmt) SetTags(tags ...interface{}) error {
+	values := param.NewParam(len(tags))
+	types := param.NewColumnType(len(tags))
+	for i, tag := range tags {
+		if tag == nil {
+			return fmt.Errorf("tag %d: unable to infer the type of a null", i)
+		}
+		if err := bindValue(values, types, i, i, tags, s.precision); err != nil {
+			return fmt.Errorf("tag %d: %w", i, err)
+		}
+	}
+	return s.stmt.SetTags(values, types)
+}
+
+// BindBatch binds rows of values, one per placeholder of the values clause, and
+// adds them to the batch run by Exec. A column must have the same type in all rows.
+func (s *PreparedStmt) BindBatch(rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	n := len(rows[0])
+	for i, row := range rows {
+		if len(row) != n {
+			return fmt.Errorf("invalid args: row %d has %d values, expected %d", i, len(row), n)
+		}
+	}
+
+	params := make([]*param.Param, n)
+	types := param.NewColumnType(n)
+	column := make([]interface{}, len(rows))
+	for c := 0; c < n; c++ {
+		for r, row := range rows {
+			column[r] = row[c]
+		}
+		params[c] = param.NewParam(len(rows))
+		if err := bindColumn(params[c], types, column, s.precision); err != nil {
+			return fmt.Errorf("column %d: %w", c, err)
+		}
+	}
+
+	if err := s.stmt.BindParam(params, types); err != nil {
+		return err
+	}
+	if err := s.stmt.AddBatch(); err != nil {
+		return err
+	}
+	s.bound += len(rows)
+	return nil
+}
+
+// Exec executes the rows bound since the last Exec and returns the number of rows inserted.
+func (s *PreparedStmt) Exec() (int, error) {
+	if s.bound == 0 {
+		return 0, nil
+	}
+	s.bound = 0
+	if err := s.stmt.Exec(); err != nil {
+		return 0, err
+	}
+	return s.stmt.GetAffectedRows(), nil
+}
+
+// Close releases the statement on the server.
+func (s *PreparedStmt) Close() error {
+	if s.writer == nil {
+		return nil
+	}
+	err := s.stmt.Close()
+	s.writer.users.Done()
+	s.writer = nil
+	return err
+}
+
+// bindColumn sets the values of one column in p and adds its type, from the
+// first value which is not nil.
+func bindColumn(p *param.Param, types *param.ColumnType, values []interface{}, precision int) error {
+	first := -1
+	for i, v := range values {
+		if v != nil {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return errors.New("unable to infer the type of a column of nulls")
+	}
+	for i := range values {
+		if err := bindValue(p, types, i, first, values, precision); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bindValue sets values[i] at offset i of p, the type of values[first] is added
+// to types when i is first.
+func bindValue(p *param.Param, types *param.ColumnType, i, first int, values []interface{}, precision int) error {
+	v := values[i]
+	if v == nil {
+		p.SetNull(i)
+		return nil
+	}
+	if i != first && fmt.Sprintf("%T", v) != fmt.Sprintf("%T", values[first]) {
+		return fmt.Errorf("conflicting types %T and %T", values[first], v)
+	}
+
+	switch v := v.(type) {
+	case time.Time:
+		p.SetTimestamp(i, v, precision)
+	case bool:
+		p.SetBool(i, v)
+	case int8:
+		p.SetTinyint(i, int(v))
+	case int16:
+		p.SetSmallint(i, int(v))
+	case int32:
+		p.SetInt(i, int(v))
+	case int:
+		p.SetBigint(i, v)
+	case int64:
+		p.SetBigint(i, int(v))
+	case uint8:
+		p.SetUTinyint(i, uint(v))
+	case uint16:
+		p.SetUSmallint(i, uint(v))
+	case uint32:
+		p.SetUInt(i, uint(v))
+	case uint:
+		p.SetUBigint(i, v)
+	case uint64:
+		p.SetUBigint(i, uint(v))
+	case float32:
+		p.SetFloat(i, v)
+	case float64:
+		p.SetDouble(i, v)
+	case string:
+		p.SetBinary(i, []byte(v))
+	case []byte:
+		p.SetBinary(i, v)
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	if i != first {
+		return nil
+	}
+
+	switch values[first].(type) {
+	case time.Time:
+		types.AddTimestamp()
+	case bool:
+		types.AddBool()
+	case int8:
+		types.AddTinyint()
+	case int16:
+		types.AddSmallint()
+	case int32:
+		types.AddInt()
+	case int, int64:
+		types.AddBigint()
+	case uint8:
+		types.AddUTinyint()
+	case uint16:
+		types.AddUSmallint()
+	case uint32:
+		types.AddUInt()
+	case uint, uint64:
+		types.AddUBigint()
+	case float32:
+		types.AddFloat()
+	case float64:
+		types.AddDouble()
+	default:
+		types.AddBinary(maxBinarySize(values))
+	}
+	return nil
+}
+
+func maxBinarySize(values []interface{}) int {
+	size := 0
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			size = max(size, len(v))
+		case []byte:
+			size = max(size, len(v))
+		}
+	}
+	return size
+}
@@ -0,0 +1,233 @@
+package tsdbclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	tmqcommon "github.com/taosdata/driver-go/v3/common/tmq"
+)
+
+const (
+	defaultProbeMeasurement  = "tsdbclient_probe"
+	defaultProbeInterval     = 30 * time.Second
+	defaultProbeTimeout      = 10 * time.Second
+	defaultProbePollInterval = 100 * time.Millisecond
+)
+
+// ErrProbeTimeout is reported when a probe point did not become visible in time.
+var ErrProbeTimeout = errors.New("probe point not visible before timeout")
+
+// LatencySample is the result of one probe point.
+type LatencySample struct {
+	// Written is the timestamp of the probe point.
+	Written time.Time
+
+	// Query is the time from the write until the point was returned by a query.
+	Query time.Duration
+
+	// Topic is the time from the write until the point was delivered by the probe
+	// topic, zero if no topic is configured or it was not delivered in time.
+	Topic time.Duration
+
+	// Err is the error writing or querying the point.
+	Err error
+}
+
+// LatencyProbe periodically writes a synthetic point and measures how long until it is
+// visible to queries and, optionally, to a subscription of a probe topic. The
+// results are counted in the Probes of the Stats of the client.
+type LatencyProbe struct {
+	// Client writes and queries the probe points.
+	Client TSDBClient
+
+	// Measurement of the probe points, defaults to "tsdbclient_probe". The points
+	// carry a `probe` tag unique to the probe and an integer `seq` field.
+	Measurement string
+
+	// Interval between probe points, defaults to 30s.
+	Interval time.Duration
+
+	// Timeout for a point to become visible, defaults to 10s.
+	Timeout time.Duration
+
+	// PollInterval between visibility queries, defaults to 100ms.
+	PollInterval time.Duration
+
+	// Topic is a topic covering the probe measurement, optional. Delivered rows are
+	// matched by the point timestamp and seq value.
+	Topic string
+
+	// OnSample receives the result of every probe point.
+	OnSample func(LatencySample)
+
+	id      string
+	lock    sync.Mutex
+	waiters map[int64]*probeWaiter
+}
+
+type probeWaiter struct {
+	written time.Time
+	ch      chan time.Time
+}
+
+// Run writes probe points until ctx is done.
+func (p *LatencyProbe) Run(ctx context.Context) error {
+	if p.Client == nil {
+		return errors.New("invalid args: probe `Client` is nil")
+	}
+	if len(p.Measurement) == 0 {
+		p.Measurement = defaultProbeMeasurement
+	}
+	if p.Interval <= 0 {
+		p.Interval = defaultProbeInterval
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = defaultProbeTimeout
+	}
+	if p.PollInterval <= 0 {
+		p.PollInterval = defaultProbePollInterval
+	}
+	p.id = uuid.NewString()
+	p.waiters = make(map[int64]*probeWaiter)
+
+	if len(p.Topic) > 0 {
+		ch := make(chan TSDBSubscribedMessage, 16)
+		go func() {
+			defer close(ch)
+			if err := p.Client.Subscribe(ctx, p.Topic, ch); err != nil {
+				defaultLogger().Error("probe subscribe error", "topic", p.Topic, "error", err)
+			}
+		}()
+		go p.receive(ch)
+	}
+
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	var seq int64
+	for {
+		seq++
+		sample := p.probe(ctx, seq)
+		if c, ok := p.Client.(*tsdbClient); ok {
+			c.metrics.recordProbe(sample)
+		}
+		if p.OnSample != nil {
+			p.OnSample(sample)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *LatencyProbe) probe(ctx context.Context, seq int64) LatencySample {
+	written := time.Now().Truncate(time.Millisecond)
+	sample := LatencySample{Written: written}
+
+	var topicCh chan time.Time
+	if len(p.Topic) > 0 {
+		topicCh = make(chan time.Time, 1)
+		p.lock.Lock()
+		p.waiters[seq] = &probeWaiter{written: written, ch: topicCh}
+		p.lock.Unlock()
+		defer func() {
+			p.lock.Lock()
+			delete(p.waiters, seq)
+			p.lock.Unlock()
+		}()
+	}
+
+	err := p.Client.WriteData(written.UnixMilli(), p.Measurement,
+		map[string]string{"probe": p.id}, map[string]interface{}{"seq": seq})
+	if err != nil {
+		sample.Err = err
+		return sample
+	}
+
+	deadline := time.NewTimer(p.Timeout)
+	defer deadline.Stop()
+
+	sql := fmt.Sprintf("select `seq` from `%s` where `probe` = '%s' and `seq` = %d", p.Measurement, p.id, seq)
+	for sample.Query == 0 {
+		rows, e := p.Client.QueryData(sql, false)
+		if e != nil {
+			sample.Err = e
+			return sample
+		}
+		if len(rows) > 0 {
+			sample.Query = time.Since(written)
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			sample.Err = ctx.Err()
+			return sample
+		case <-deadline.C:
+			sample.Err = ErrProbeTimeout
+			return sample
+		case <-time.After(p.PollInterval):
+		}
+	}
+
+	if topicCh != nil {
+		select {
+		case t := <-topicCh:
+			sample.Topic = t.Sub(written)
+		case <-ctx.Done():
+		case <-deadline.C:
+		}
+	}
+	return sample
+}
+
+func (p *LatencyProbe) receive(ch <-chan TSDBSubscribedMessage) {
+	for msg := range ch {
+		received := time.Now()
+		blocks, ok := msg.Value().([]*tmqcommon.Data)
+		if !ok {
+			continue
+		}
+		for _, b := range blocks {
+			if b == nil {
+				continue
+			}
+			for _, row := range b.Data {
+				p.match(row, received)
+			}
+		}
+	}
+}
+
+func (p *LatencyProbe) match(row []driver.Value, received time.Time) {
+	if len(row) < 2 {
+		return
+	}
+	ts, ok := row[0].(time.Time)
+	if !ok {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, v := range row[1:] {
+		seq, ok := v.(int64)
+		if !ok {
+			continue
+		}
+		if w, ok := p.waiters[seq]; ok && w.written.Equal(ts) {
+			select {
+			case w.ch <- received:
+			default:
+			}
+		}
+	}
+}
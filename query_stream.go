@@ -0,0 +1,232 @@
+package tsdbclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// QueryIterator decodes the rows of a query result one at a time while reading
+// the response, so results larger than memory can be exported. It must be closed.
+//
+//	it, err := c.QueryStream(ctx, NewQuery("select * from meters", "power", "ms"))
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		row := it.Row()
+//	}
+//	return it.Err()
+type QueryIterator struct {
+	// ColumnMeta is the column meta of the result, [column name, column type, type size].
+	ColumnMeta [][]interface{}
+
+	columns []ColumnMeta
+
+	body    io.ReadCloser
+	cancel  context.CancelFunc
+	dec     *json.Decoder
+	pending [][]interface{}
+	row     []interface{}
+	rows    int
+	maxRows int
+	warning error
+	err     error
+	inData  bool
+	done    bool
+}
+
+// QueryStream sends a command to the server and returns an iterator over the rows.
+func (c *client) QueryStream(ctx context.Context, q Query) (*QueryIterator, error) {
+	reqID := requestID(ctx)
+	it, err := c.queryStream(WithReqID(ctx, reqID), q)
+	return it, withReqID(err, reqID)
+}
+
+func (c *client) queryStream(ctx context.Context, q Query) (*QueryIterator, error) {
+	ctx, q, cancel := prepareQuery(ctx, q, c.limitPolicy)
+	req, err := c.createDefaultRequest(ctx, q)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		cancel()
+		return nil, err
+	}
+
+	it := &QueryIterator{body: resp.Body, dec: json.NewDecoder(resp.Body), cancel: cancel, maxRows: q.MaxRows}
+	it.dec.UseNumber()
+	if err := it.readHeader(resp.StatusCode); err != nil {
+		it.Close()
+		return nil, err
+	}
+	return it, nil
+}
+
+// newBufferedIterator iterates over the rows of a response already read.
+func newBufferedIterator(resp *Response) *QueryIterator {
+	columns, _ := resp.columnMetas()
+	return &QueryIterator{ColumnMeta: resp.ColumnMeta, columns: columns, pending: resp.Data, inData: true, warning: resp.Warning}
+}
+
+// readHeader reads the fields of the response up to the first row.
+func (it *QueryIterator) readHeader(status int) error {
+	if err := it.expectDelim('{'); err != nil {
+		return err
+	}
+
+	var head Response
+	for it.dec.More() {
+		tok, err := it.dec.Token()
+		if err != nil {
+			return fmt.Errorf("unable to decode json: %v", err)
+		}
+		switch tok {
+		case "code":
+			err = it.dec.Decode(&head.Code)
+		case "desc":
+			err = it.dec.Decode(&head.Desc)
+		case "column_meta":
+			if err = it.dec.Decode(&it.ColumnMeta); err == nil {
+				it.columns, _ = parseColumnMeta(it.ColumnMeta)
+			}
+		case "data":
+			if err = head.Error(); err != nil {
+				return err
+			}
+			if err = it.expectDelim('['); err != nil {
+				return err
+			}
+			it.inData = true
+			return nil
+		default:
+			var skip json.RawMessage
+			err = it.dec.Decode(&skip)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to decode json: %v", err)
+		}
+	}
+
+	if err := head.Error(); err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("received status code %d from server", status)
+	}
+	it.done = true
+	return nil
+}
+
+func (it *QueryIterator) expectDelim(d json.Delim) error {
+	tok, err := it.dec.Token()
+	if err != nil {
+		return fmt.Errorf("unable to decode json: %v", err)
+	}
+	if tok != d {
+		return fmt.Errorf("unable to decode json: expected %v, got %v", d, tok)
+	}
+	return nil
+}
+
+// Columns returns the column names of the result.
+func (it *QueryIterator) Columns() []string {
+	columns := make([]string, 0, len(it.ColumnMeta))
+	for _, c := range it.ColumnMeta {
+		if len(c) > 0 {
+			name, _ := c[0].(string)
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
+
+// ColumnTypes returns the typed column meta of the result.
+func (it *QueryIterator) ColumnTypes() []ColumnMeta {
+	return it.columns
+}
+
+// Next decodes the next row, it returns false at the end of the result or on error.
+func (it *QueryIterator) Next() bool {
+	if it.done || it.err != nil || !it.inData {
+		return false
+	}
+	if it.dec == nil {
+		if len(it.pending) == 0 {
+			it.done = true
+			return false
+		}
+		it.row, it.pending = it.pending[0], it.pending[1:]
+		return true
+	}
+	if !it.dec.More() {
+		it.done = true
+		return false
+	}
+	if it.maxRows > 0 && it.rows == it.maxRows {
+		it.warning = &RowLimitWarning{MaxRows: it.maxRows}
+		it.done = true
+		return false
+	}
+
+	var row []interface{}
+	if err := it.dec.Decode(&row); err != nil {
+		it.err = fmt.Errorf("unable to decode json: %v", err)
+		return false
+	}
+	it.row = row
+	it.rows++
+	return true
+}
+
+// Row returns the row decoded by the last call of Next.
+func (it *QueryIterator) Row() []interface{} {
+	return it.row
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *QueryIterator) Err() error {
+	return it.err
+}
+
+// Warning reports a result stopped before its end, such as a RowLimitWarning.
+func (it *QueryIterator) Warning() error {
+	return it.warning
+}
+
+// Close releases the response, the iteration may be stopped before its end.
+func (it *QueryIterator) Close() error {
+	it.done = true
+	if it.cancel != nil {
+		defer it.cancel()
+	}
+	if it.body == nil {
+		return nil
+	}
+	err := it.body.Close()
+	it.body = nil
+	return err
+}
+
+// QueryStream runs the sql in the client database and returns an iterator over the rows.
+func (client *tsdbClient) QueryStream(ctx context.Context, sql string) (*QueryIterator, error) {
+	if client.httpClient == nil || client.initialErr != nil {
+		return nil, fmt.Errorf("not created http client for tdengine: %v", client.initialErr)
+	}
+	return client.httpClient.QueryStream(ctx, NewQuery(sql, client.dbConfig.DBName, client.dbConfig.Precision))
+}
+
+// QueryStream runs the sql with the default client and returns an iterator over the rows.
+func QueryStream(ctx context.Context, sql string) (*QueryIterator, error) {
+	return clientWrapper.QueryStream(ctx, sql)
+}
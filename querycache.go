@@ -0,0 +1,246 @@
+package tsdbclient
+
+import (
+	"container/list"
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultQueryCacheSize = 256
+
+// QueryOption sets the hints of a query, such as its consistency and limits.
+type QueryOption func(q *Query)
+
+// NoCache makes the query bypass the query cache, it always reaches the server.
+func NoCache() QueryOption {
+	return func(q *Query) {
+		q.NoCache = true
+	}
+}
+
+// MaxStaleness accepts a result up to d old, from the query cache or from a read replica.
+func MaxStaleness(d time.Duration) QueryOption {
+	return func(q *Query) {
+		q.MaxStaleness = d
+	}
+}
+
+// QueryTimeout bounds the query by d, above the Timeout of the client if longer.
+func QueryTimeout(d time.Duration) QueryOption {
+	return func(q *Query) {
+		q.Timeout = d
+	}
+}
+
+// With returns the query with the options applied.
+func (q Query) With(opts ...QueryOption) Query {
+	for _, opt := range opts {
+		opt(&q)
+	}
+	return q
+}
+
+type queryOptionsKey struct{}
+
+// WithQueryOptions applies the options to the queries issued with the returned
+// context, for the APIs taking sql strings such as QueryDataContext.
+func WithQueryOptions(ctx context.Context, opts ...QueryOption) context.Context {
+	return context.WithValue(ctx, queryOptionsKey{}, append(queryOptionsFrom(ctx), opts...))
+}
+
+func queryOptionsFrom(ctx context.Context) []QueryOption {
+	opts, _ := ctx.Value(queryOptionsKey{}).([]QueryOption)
+	return opts
+}
+
+// withContextOptions applies the options of the context to the query.
+func withContextOptions(ctx context.Context, q Query) Query {
+	if opts := queryOptionsFrom(ctx); len(opts) > 0 {
+		return q.With(opts...)
+	}
+	return q
+}
+
+// cacheableQuery reports whether the command only reads, so its result may be cached.
+func cacheableQuery(command string) bool {
+	cmd := strings.ToLower(strings.TrimSpace(command))
+	return strings.HasPrefix(cmd, "select") || strings.HasPrefix(cmd, "show") || strings.HasPrefix(cmd, "describe")
+}
+
+// queryCache is a LRU of the successful responses of read-only queries. Entries
+// are served for ttl, or the MaxStaleness of the query if set, NoCache bypasses it.
+// The writes of the client invalidate the entries of the queries referencing the
+// measurements written, the sql statements those of the tables they reference.
+type queryCache struct {
+	ttl    time.Duration
+	size   int
+	policy LimitPolicy
+
+	lock  sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+}
+
+type queryCacheEntry struct {
+	key     string
+	resp    Response
+	fetched time.Time
+	tables  []string
+}
+
+// newQueryCache returns a cache of the queries of a client limited by policy,
+// whose limit is part of the key of the queries.
+func newQueryCache(ttl time.Duration, size int, policy LimitPolicy) *queryCache {
+	if size <= 0 {
+		size = defaultQueryCacheSize
+	}
+	return &queryCache{ttl: ttl, size: size, policy: policy, items: make(map[string]*list.Element), order: list.New()}
+}
+
+// queryCacheKey identifies the result of a query run with the limit of a
+// LimitPolicy, the commands differing only by whitespace or case share it.
+func queryCacheKey(q Query, limit int) string {
+	return q.Database + "\x00" + q.Precision + "\x00" + strconv.Itoa(q.MaxRows) + "\x00" + strconv.Itoa(limit) + "\x00" + FormatSQL(q.Command)
+}
+
+// get returns a copy of the cached response if it is at most maxAge old.
+func (c *queryCache) get(key string, maxAge time.Duration) (*Response, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*queryCacheEntry)
+	if time.Since(entry.fetched) > maxAge {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	resp := entry.resp
+	return &resp, true
+}
+
+func (c *queryCache) put(key string, resp *Response, tables []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		entry := e.Value.(*queryCacheEntry)
+		entry.resp, entry.fetched, entry.tables = *resp, time.Now(), tables
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.order.PushFront(&queryCacheEntry{key: key, resp: *resp, fetched: time.Now(), tables: tables})
+	if c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.items, e.Value.(*queryCacheEntry).key)
+	}
+}
+
+// invalidate removes the responses of the queries referencing one of the tables.
+func (c *queryCache) invalidate(tables ...string) {
+	if len(tables) == 0 {
+		return
+	}
+	written := make(map[string]struct{}, len(tables))
+	for _, t := range tables {
+		written[strings.ToLower(t)] = struct{}{}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for e := c.order.Front(); e != nil; {
+		next := e.Next()
+		entry := e.Value.(*queryCacheEntry)
+		for _, t := range entry.tables {
+			if _, ok := written[t]; ok {
+				c.order.Remove(e)
+				delete(c.items, entry.key)
+				break
+			}
+		}
+		e = next
+	}
+}
+
+// tableRefPattern matches the tables following from, join, into and using,
+// optionally qualified by their database.
+var tableRefPattern = regexp.MustCompile("(?i)\\b(?:from|join|into|using)\\s+((?:`[^`]+`|\\w+)(?:\\.(?:`[^`]+`|\\w+))?)")
+
+// referencedTables extracts the names of the tables of the command, lowercased
+// and without their database. It is a heuristic: subqueries and comments are not
+// told apart, which only invalidates more.
+func referencedTables(command string) []string {
+	var tables []string
+	for _, m := range tableRefPattern.FindAllStringSubmatch(command, -1) {
+		name := m[1]
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+		tables = append(tables, strings.ToLower(strings.Trim(name, "`")))
+	}
+	return tables
+}
+
+// Wrap returns a client serving queries from the cache. The cached responses share
+// their rows, which must not be modified.
+func (c *queryCache) Wrap(client Client) Client {
+	return &cachedClient{Client: client, cache: c}
+}
+
+type cachedClient struct {
+	Client
+	cache *queryCache
+}
+
+func (c *cachedClient) Query(q Query) (*Response, error) {
+	return c.QueryContext(context.Background(), q)
+}
+
+func (c *cachedClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	q = withContextOptions(ctx, q)
+	if !cacheableQuery(q.Command) {
+		// inserts and deletes through sql make the cached reads of their tables stale
+		resp, err := c.Client.QueryContext(ctx, q)
+		c.cache.invalidate(referencedTables(q.Command)...)
+		return resp, err
+	}
+	if q.NoCache {
+		return c.Client.QueryContext(ctx, q)
+	}
+
+	maxAge := c.cache.ttl
+	if q.MaxStaleness > 0 {
+		maxAge = q.MaxStaleness
+	}
+	key := queryCacheKey(q, policyLimit(ctx, q, c.cache.policy))
+	if resp, ok := c.cache.get(key, maxAge); ok {
+		return resp, nil
+	}
+
+	resp, err := c.Client.QueryContext(ctx, q)
+	if err == nil && resp != nil && resp.Error() == nil {
+		c.cache.put(key, resp, referencedTables(q.Command))
+	}
+	return resp, err
+}
+
+// measurements returns the distinct measurements of the batch.
+func measurements(bp BatchPoints) []string {
+	seen := make(map[string]struct{})
+	var names []string
+	for _, p := range bp.Points() {
+		if p == nil {
+			continue
+		}
+		if _, ok := seen[p.Name()]; !ok {
+			seen[p.Name()] = struct{}{}
+			names = append(names, p.Name())
+		}
+	}
+	return names
+}
@@ -0,0 +1,64 @@
+package tsdbclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryCacheKey(t *testing.T) {
+	base := Query{Command: "select * from meters", Database: "power", Precision: "ms"}
+	tests := []struct {
+		name  string
+		q     Query
+		limit int
+		same  bool
+	}{
+		{"identical", base, 0, true},
+		{"whitespace and case", Query{Command: "  SELECT *\n FROM meters; ", Database: "power", Precision: "ms"}, 0, true},
+		{"quoted text", Query{Command: "select * from meters where n = 'A'", Database: "power", Precision: "ms"}, 0, false},
+		{"database", Query{Command: base.Command, Database: "other", Precision: "ms"}, 0, false},
+		{"precision", Query{Command: base.Command, Database: "power", Precision: "us"}, 0, false},
+		{"max rows", Query{Command: base.Command, Database: "power", Precision: "ms", MaxRows: 10}, 0, false},
+		{"policy limit", base, 10, false},
+	}
+	key := queryCacheKey(base, 0)
+	for _, tt := range tests {
+		if got := queryCacheKey(tt.q, tt.limit) == key; got != tt.same {
+			t.Errorf("%s: same key = %v, want %v", tt.name, got, tt.same)
+		}
+	}
+}
+
+func TestQueryCacheInvalidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		written []string
+		kept    []string
+	}{
+		{"nothing written", nil, []string{"meters", "join", "other"}},
+		{"one table", []string{"meters"}, []string{"other"}},
+		{"case insensitive", []string{"OTHER"}, []string{"meters", "join"}},
+		{"joined table", []string{"d1"}, []string{"meters", "other"}},
+		{"unknown table", []string{"d9"}, []string{"meters", "join", "other"}},
+	}
+	queries := map[string]string{
+		"meters": "select * from power.meters",
+		"join":   "select * from meters join d1 on meters.ts = d1.ts",
+		"other":  "select * from `Other`",
+	}
+	for _, tt := range tests {
+		c := newQueryCache(time.Minute, 0, nil)
+		for key, command := range queries {
+			c.put(key, &Response{}, referencedTables(command))
+		}
+		c.invalidate(tt.written...)
+		if len(c.items) != len(tt.kept) {
+			t.Errorf("%s: %d entries kept, want %d", tt.name, len(c.items), len(tt.kept))
+		}
+		for _, key := range tt.kept {
+			if _, ok := c.get(key, time.Minute); !ok {
+				t.Errorf("%s: entry %s invalidated", tt.name, key)
+			}
+		}
+	}
+}
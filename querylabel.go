@@ -0,0 +1,33 @@
+package tsdbclient
+
+import (
+	"context"
+	"strings"
+)
+
+type queryLabelKey struct{}
+
+// WithQueryLabel returns a context labelling the statements run under it, e.g.
+// "service=billing;job=rollup". The label is sent as a leading SQL comment, so
+// DBAs can attribute the load in the server logs to services and jobs. A label
+// set again replaces the previous one.
+func WithQueryLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, queryLabelKey{}, label)
+}
+
+// queryLabel returns the label of ctx, empty if none.
+func queryLabel(ctx context.Context) string {
+	label, _ := ctx.Value(queryLabelKey{}).(string)
+	return label
+}
+
+// labelQuery returns the command prefixed with the label of ctx, or as is when
+// ctx has no label.
+func labelQuery(ctx context.Context, command string) string {
+	label := queryLabel(ctx)
+	if len(label) == 0 {
+		return command
+	}
+	label = strings.ReplaceAll(label, "*/", "* /")
+	return "/* " + label + " */ " + command
+}
@@ -0,0 +1,104 @@
+package tsdbclient
+
+import (
+	"regexp"
+	"strings"
+)
+
+const redacted = "xxxxx"
+
+// redactPatterns match the credentials which may appear in errors and logs: URL
+// user info, authorization headers, login paths, credential query parameters and
+// the passwords of user statements.
+var redactPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(://[^:/@\s]+:)[^@\s]+@`), "${1}" + redacted + "@"},
+	{regexp.MustCompile(`(?i)(authorization:?\s*"?(?:basic|taosd|bearer)\s+)[^\s"]+`), "${1}" + redacted},
+	{regexp.MustCompile(`(/rest/login/[^/\s]+/)[^\s"?]+`), "${1}" + redacted},
+	{regexp.MustCompile(`(?i)([?&](?:p|pass|password|token)=)[^&\s"]+`), "${1}" + redacted},
+	{regexp.MustCompile(`(?i)(\bpass(?:word)?\s+)'(?:[^'\\]|\\.)*'`), "${1}'" + redacted + "'"},
+	{regexp.MustCompile(`(?i)(\bpass(?:word)?\s+)"(?:[^"\\]|\\.)*"`), "${1}\"" + redacted + "\""},
+}
+
+// redact removes the credentials from s, and the secrets, e.g. the configured password.
+func redact(s string, secrets ...string) string {
+	for _, p := range redactPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	for _, secret := range secrets {
+		// short secrets would mangle unrelated text
+		if len(secret) >= 4 {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	return s
+}
+
+// redactedError is an error whose message had credentials removed, the original
+// error is still matched by errors.Is and errors.As.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError returns err with the credentials removed from its message.
+func redactError(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	r := redact(msg, secrets...)
+	if r == msg {
+		return err
+	}
+	if re, ok := err.(*redactedError); ok {
+		return &redactedError{msg: r, err: re.err}
+	}
+	return &redactedError{msg: r, err: err}
+}
+
+// redactingLogger removes the credentials from the messages and values it logs.
+type redactingLogger struct {
+	l Logger
+}
+
+func (r redactingLogger) values(keyvals []interface{}) []interface{} {
+	out := make([]interface{}, len(keyvals))
+	for i, v := range keyvals {
+		switch v := v.(type) {
+		case error:
+			out[i] = redactError(v)
+		case string:
+			out[i] = redact(v)
+		default:
+			out[i] = v
+		}
+	}
+	return out
+}
+
+func (r redactingLogger) Debug(msg string, keyvals ...interface{}) {
+	r.l.Debug(redact(msg), r.values(keyvals)...)
+}
+
+func (r redactingLogger) Info(msg string, keyvals ...interface{}) {
+	r.l.Info(redact(msg), r.values(keyvals)...)
+}
+
+func (r redactingLogger) Warn(msg string, keyvals ...interface{}) {
+	r.l.Warn(redact(msg), r.values(keyvals)...)
+}
+
+func (r redactingLogger) Error(msg string, keyvals ...interface{}) {
+	r.l.Error(redact(msg), r.values(keyvals)...)
+}
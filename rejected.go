@@ -0,0 +1,96 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// RejectedPoint is a point whose write was refused, by the validation of the
+// client or by the server.
+type RejectedPoint struct {
+	// Time is when the write failed.
+	Time time.Time
+
+	Database string
+
+	// Line is the point in line protocol.
+	Line string
+
+	// Reason is the error of the write, shared by the points of the batch.
+	Reason string
+}
+
+// rejectedRing keeps the last points rejected, see QuarantineRejected.
+type rejectedRing struct {
+	lock   sync.Mutex
+	points []RejectedPoint
+	next   int
+	full   bool
+}
+
+func newRejectedRing(size int) *rejectedRing {
+	return &rejectedRing{points: make([]RejectedPoint, size)}
+}
+
+// add records the points of the batch rejected with err.
+func (r *rejectedRing) add(bps BatchPoints, err error, now time.Time) {
+	reason := redact(err.Error())
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, p := range bps.Points() {
+		if p == nil {
+			continue
+		}
+		r.points[r.next] = RejectedPoint{Time: now, Database: bps.Database(), Line: p.PrecisionString(bps.Precision()), Reason: reason}
+		r.next = (r.next + 1) % len(r.points)
+		if r.next == 0 {
+			r.full = true
+		}
+	}
+}
+
+// snapshot returns the points kept, oldest first.
+func (r *rejectedRing) snapshot() []RejectedPoint {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.full {
+		return append([]RejectedPoint(nil), r.points[:r.next]...)
+	}
+	return append(append([]RejectedPoint(nil), r.points[r.next:]...), r.points[:r.next]...)
+}
+
+// isRejection reports whether a failed write was refused for its points, rather
+// than the server being unreachable or the write canceled.
+func isRejection(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || isUnreachable(err) {
+		return false
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return isRefused(context.Background(), err)
+	}
+	return true
+}
+
+// quarantine keeps the points of a batch rejected with err, when enabled.
+func (client *tsdbClient) quarantine(bps BatchPoints, err error) {
+	if client.rejected != nil && bps != nil && isRejection(err) {
+		client.rejected.add(bps, err, time.Now())
+	}
+}
+
+// RejectedPoints returns the last points rejected, oldest first, nil unless
+// enabled by QuarantineRejected. They are shared with the derived clients.
+func (client *tsdbClient) RejectedPoints() []RejectedPoint {
+	if client.rejected == nil {
+		return nil
+	}
+	return client.rejected.snapshot()
+}
+
+// RejectedPoints returns the last points rejected by the default client.
+func RejectedPoints() []RejectedPoint {
+	return clientWrapper.RejectedPoints()
+}
@@ -0,0 +1,108 @@
+package tsdbclient
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+)
+
+// Table is a query result formatted for rendering, the data of RenderTable templates.
+type Table struct {
+	Columns []TableColumn
+	Rows    [][]TableCell
+}
+
+// TableColumn is a column of a Table, Type is the TDengine type of the column.
+type TableColumn struct {
+	Name string
+	Type string
+}
+
+// TableCell is a formatted value, Null is true for NULL values.
+type TableCell struct {
+	Value   string
+	Null    bool
+	Numeric bool
+}
+
+// DefaultTableTemplate renders a Table as a HTML table, numbers right aligned.
+var DefaultTableTemplate = template.Must(template.New("table").Parse(`<table>
+<thead><tr>{{range .Columns}}<th title="{{.Type}}">{{.Name}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td{{if .Numeric}} style="text-align:right"{{end}}>{{if .Null}}<i>NULL</i>{{else}}{{.Value}}{{end}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+`))
+
+// NewTable formats the response in column order for rendering.
+func NewTable(resp *Response) (*Table, error) {
+	if resp == nil {
+		return nil, fmt.Errorf("invalid args: nil response")
+	}
+	if err := resp.Error(); err != nil {
+		return nil, err
+	}
+
+	columns, err := resp.columnMetas()
+	if err != nil {
+		return nil, err
+	}
+	t := &Table{Columns: make([]TableColumn, len(columns))}
+	for i, c := range columns {
+		t.Columns[i] = TableColumn{Name: c.Name, Type: string(c.Type)}
+	}
+
+	t.Rows = make([][]TableCell, 0, len(resp.Data))
+	for _, r := range resp.Data {
+		row := make([]TableCell, len(t.Columns))
+		for i := range row {
+			if i < len(r) {
+				row[i] = formatCell(r[i])
+			} else {
+				row[i] = TableCell{Null: true}
+			}
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, nil
+}
+
+// formatCell formats a value as decoded from the response, timestamps are kept
+// in the RFC 3339 form sent by the server.
+func formatCell(v interface{}) TableCell {
+	switch v := v.(type) {
+	case nil:
+		return TableCell{Null: true}
+	case json.Number:
+		return TableCell{Value: v.String(), Numeric: true}
+	case float64:
+		return TableCell{Value: strconv.FormatFloat(v, 'g', -1, 64), Numeric: true}
+	case bool:
+		return TableCell{Value: strconv.FormatBool(v)}
+	case string:
+		return TableCell{Value: v}
+	case []byte:
+		return TableCell{Value: base64.StdEncoding.EncodeToString(v)}
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return TableCell{Value: fmt.Sprint(v)}
+		}
+		return TableCell{Value: string(b)}
+	}
+}
+
+// RenderTable executes tmpl, DefaultTableTemplate if nil, with the Table of the response.
+func RenderTable(w io.Writer, resp *Response, tmpl *template.Template) error {
+	t, err := NewTable(resp)
+	if err != nil {
+		return err
+	}
+	if tmpl == nil {
+		tmpl = DefaultTableTemplate
+	}
+	return tmpl.Execute(w, t)
+}
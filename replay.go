@@ -0,0 +1,109 @@
+package tsdbclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jeagle929/tsdbclient/models"
+	tmqcommon "github.com/taosdata/driver-go/v3/common/tmq"
+)
+
+// ReplayTransform converts one row of a subscribed message into a point,
+// a nil point skips the row.
+type ReplayTransform func(db, table string, row []driver.Value) (*DataPoint, error)
+
+// Replayer subscribes a topic and writes its rows as points through another client,
+// for feeding read replicas or test environments from a production stream.
+type Replayer struct {
+	// Source is the client subscribing the topic.
+	Source TSDBClient
+
+	// Topic is the topic to replay.
+	Topic string
+
+	// Target is the client the points are written to.
+	Target TSDBClient
+
+	// Transform converts the rows, see ReplayColumns.
+	Transform ReplayTransform
+
+	// Config configures the subscription, offsets are committed only after the
+	// points of a message have been written.
+	Config ConsumerPoolConfig
+}
+
+// Run replays the topic until ctx is done or a write fails.
+func (r *Replayer) Run(ctx context.Context) error {
+	if r.Source == nil || r.Target == nil {
+		return errors.New("invalid args: replay `Source` or `Target` is nil")
+	}
+	if r.Transform == nil {
+		return errors.New("invalid args: replay `Transform` is nil")
+	}
+	return r.Source.SubscribePool(ctx, r.Topic, r.Config, r.replay)
+}
+
+func (r *Replayer) replay(_ context.Context, msg TSDBSubscribedMessage) error {
+	blocks, ok := msg.Value().([]*tmqcommon.Data)
+	if !ok {
+		return nil
+	}
+
+	var points models.Points
+	for _, b := range blocks {
+		if b == nil {
+			continue
+		}
+		for _, row := range b.Data {
+			p, err := r.Transform(msg.DBName(), b.TableName, row)
+			if err != nil {
+				return err
+			}
+			if p != nil {
+				points = append(points, p.pt)
+			}
+		}
+	}
+	return r.Target.WriteDataBatch(points)
+}
+
+// ReplayColumns returns a transform writing rows to the measurement, columns names the
+// values of a row in order and must start with the timestamp column. Columns listed
+// in tags become tags, nil values are skipped.
+func ReplayColumns(measurement string, columns []string, tags ...string) ReplayTransform {
+	isTag := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		isTag[t] = true
+	}
+
+	return func(_, _ string, row []driver.Value) (*DataPoint, error) {
+		if len(row) != len(columns) || len(row) == 0 {
+			return nil, fmt.Errorf("replay row has %d values, expected %d columns", len(row), len(columns))
+		}
+		ts, ok := row[0].(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("replay column %s is not a timestamp: %T", columns[0], row[0])
+		}
+
+		tagValues := map[string]string{}
+		fields := map[string]interface{}{}
+		for i := 1; i < len(row); i++ {
+			if row[i] == nil {
+				continue
+			}
+			v := row[i]
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			if isTag[columns[i]] {
+				tagValues[columns[i]] = fmt.Sprint(v)
+			} else {
+				fields[columns[i]] = v
+			}
+		}
+		return NewDataPoint(measurement, tagValues, fields, ts)
+	}
+}
@@ -0,0 +1,63 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/taosdata/driver-go/v3/common"
+)
+
+type reqIDKey struct{}
+
+// WithReqID returns a context sending id as the req_id of the requests made under
+// it, to find them in the server logs. Without it every request gets a new one.
+func WithReqID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, reqIDKey{}, id)
+}
+
+// ReqIDFrom returns the req_id set by WithReqID, 0 if none.
+func ReqIDFrom(ctx context.Context) int64 {
+	id, _ := ctx.Value(reqIDKey{}).(int64)
+	return id
+}
+
+// requestID returns the req_id of ctx, or a new one.
+func requestID(ctx context.Context) int64 {
+	if id := ReqIDFrom(ctx); id != 0 {
+		return id
+	}
+	return common.GetReqID()
+}
+
+// ReqIDError is an error of a request, with the req_id it was sent with.
+type ReqIDError struct {
+	ReqID int64
+	Err   error
+}
+
+func (e *ReqIDError) Error() string {
+	return fmt.Sprintf("%v (req_id 0x%x)", e.Err, e.ReqID)
+}
+
+func (e *ReqIDError) Unwrap() error {
+	return e.Err
+}
+
+// withReqID annotates err with the req_id of its request.
+func withReqID(err error, id int64) error {
+	var reqErr *ReqIDError
+	if err == nil || id == 0 || errors.As(err, &reqErr) {
+		return err
+	}
+	return &ReqIDError{ReqID: id, Err: err}
+}
+
+// ReqIDOf returns the req_id of the request err was returned by, if known.
+func ReqIDOf(err error) (int64, bool) {
+	var reqErr *ReqIDError
+	if errors.As(err, &reqErr) {
+		return reqErr.ReqID, true
+	}
+	return 0, false
+}
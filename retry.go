@@ -0,0 +1,102 @@
+package tsdbclient
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 5 * time.Second
+	defaultRetryMultiplier     = 2
+	defaultReconnectJitter     = 0.2
+)
+
+var defaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy configures the retries of failed writes and the reconnects of failed
+// subscriptions. For writes, network errors and the retryable status codes are
+// retried, other failures are returned at once.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one,
+	// values below 2 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry, defaults to 100ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between attempts, defaults to 5s.
+	MaxBackoff time.Duration
+
+	// Multiplier grows the wait after each retry, defaults to 2.
+	Multiplier float64
+
+	// Jitter randomizes each wait by up to this fraction of it, in [0, 1].
+	Jitter float64
+
+	// RetryableStatusCodes defaults to 429, 500, 502, 503 and 504.
+	RetryableStatusCodes []int
+}
+
+func (p RetryPolicy) enabled() bool {
+	return p.MaxAttempts > 1
+}
+
+func (p RetryPolicy) retryableStatus(code int) bool {
+	codes := p.RetryableStatusCodes
+	if len(codes) == 0 {
+		codes = defaultRetryableStatusCodes
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before the given retry, starting at 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	initial, max, mult := p.InitialBackoff, p.MaxBackoff, p.Multiplier
+	if initial <= 0 {
+		initial = defaultRetryInitialBackoff
+	}
+	if max <= 0 {
+		max = defaultRetryMaxBackoff
+	}
+	if mult < 1 {
+		mult = defaultRetryMultiplier
+	}
+
+	d := float64(initial)
+	for i := 1; i < retry && d < float64(max); i++ {
+		d *= mult
+	}
+	if d > float64(max) {
+		d = float64(max)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(d)
+}
+
+// wait sleeps before the given retry, it returns early with the error of ctx.
+func (p RetryPolicy) wait(ctx context.Context, retry int) error {
+	t := time.NewTimer(p.backoff(retry))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
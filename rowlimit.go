@@ -0,0 +1,92 @@
+package tsdbclient
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrRowLimit is matched by errors.Is for every RowLimitWarning.
+var ErrRowLimit = errors.New("result truncated by row limit")
+
+// RowLimitWarning is set as the Warning of a response truncated to the MaxRows
+// of its query.
+type RowLimitWarning struct {
+	MaxRows int
+}
+
+func (w *RowLimitWarning) Error() string {
+	return fmt.Sprintf("result truncated by row limit: more than %d rows", w.MaxRows)
+}
+
+func (w *RowLimitWarning) Is(target error) bool {
+	return target == ErrRowLimit
+}
+
+// MaxRows caps the rows of the result to n. A LIMIT is appended to a select
+// without one, and the rows above n are dropped with a RowLimitWarning.
+func MaxRows(n int) QueryOption {
+	return func(q *Query) {
+		q.MaxRows = n
+	}
+}
+
+// limitPattern matches a limit ending the statement, with its offset.
+var limitPattern = regexp.MustCompile(`(?i)\blimit\s+\d+(\s*,\s*\d+|\s+offset\s+\d+)?\s*;?\s*$`)
+
+// hasLimit reports whether the command is not a select or already has a limit of
+// its own, the limits of the subqueries don't count.
+func hasLimit(command string) bool {
+	cmd := strings.TrimSpace(command)
+	return !strings.HasPrefix(strings.ToLower(cmd), "select") || limitPattern.MatchString(topLevelSQL(cmd))
+}
+
+// topLevelSQL returns the sql with the quoted text and the text in parentheses
+// blanked, leaving the clauses of the outer statement.
+func topLevelSQL(sql string) string {
+	b := []byte(sql)
+	depth := 0
+	for i := 0; i < len(b); {
+		switch c := b[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			end := quotedEnd(sql, i)
+			for ; i < end; i++ {
+				b[i] = ' '
+			}
+		case c == '(':
+			depth++
+			b[i] = ' '
+			i++
+		case c == ')':
+			depth = max(depth-1, 0)
+			b[i] = ' '
+			i++
+		default:
+			if depth > 0 {
+				b[i] = ' '
+			}
+			i++
+		}
+	}
+	return string(b)
+}
+
+// appendLimit appends a limit of n rows to the command if it is a select without one.
+func appendLimit(command string, n int) string {
+	if hasLimit(command) {
+		return command
+	}
+	return strings.TrimRight(strings.TrimSpace(command), ";") + " limit " + strconv.Itoa(n)
+}
+
+// truncateRows drops the rows of resp above maxRows and sets its warning.
+func truncateRows(resp *Response, maxRows int) {
+	if maxRows <= 0 || resp == nil || len(resp.Data) <= maxRows {
+		return
+	}
+	resp.Data = resp.Data[:maxRows]
+	resp.Rows = maxRows
+	resp.Warning = &RowLimitWarning{MaxRows: maxRows}
+}
@@ -0,0 +1,64 @@
+package tsdbclient
+
+import "testing"
+
+func TestHasLimit(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"select * from meters", false},
+		{"select * from meters limit 10", true},
+		{"SELECT * FROM meters LIMIT 10;", true},
+		{"select * from meters limit 10, 20", true},
+		{"select * from meters limit 10 offset 20 ;", true},
+		{"select * from (select * from meters limit 10)", false},
+		{"select * from meters where name = 'limit 10'", false},
+		{"select * from meters where name = ' limit 10'", false},
+		{"insert into d0 values (now, 1)", true},
+		{"  show databases", true},
+	}
+	for _, tt := range tests {
+		if got := hasLimit(tt.command); got != tt.want {
+			t.Errorf("hasLimit(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestTopLevelSQL(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"select a from t", "select a from t"},
+		{"select count(a) from t", "select count    from t"},
+		{"select a from (select a from t limit 1) limit 2", "select a from                           limit 2"},
+		{"select 'a)' from t", "select      from t"},
+		{"select `a(` from t", "select      from t"},
+		{"select a from t)) limit 1", "select a from t   limit 1"},
+	}
+	for _, tt := range tests {
+		if got := topLevelSQL(tt.sql); got != tt.want {
+			t.Errorf("topLevelSQL(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestAppendLimit(t *testing.T) {
+	tests := []struct {
+		command string
+		n       int
+		want    string
+	}{
+		{"select * from meters", 10, "select * from meters limit 10"},
+		{" select * from meters; ", 10, "select * from meters limit 10"},
+		{"select * from meters limit 5", 10, "select * from meters limit 5"},
+		{"select * from (select * from meters limit 5)", 10, "select * from (select * from meters limit 5) limit 10"},
+		{"show tables", 10, "show tables"},
+	}
+	for _, tt := range tests {
+		if got := appendLimit(tt.command, tt.n); got != tt.want {
+			t.Errorf("appendLimit(%q, %d) = %q, want %q", tt.command, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package tsdbclient
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Rows iterates over the rows of a response like database/sql Rows, converting
+// the values to the types of their columns.
+//
+//	rows := resp.Iter()
+//	for rows.Next() {
+//		var ts time.Time
+//		var current float64
+//		var location sql.NullString
+//		if err := rows.Scan(&ts, &current, &location); err != nil {
+//			return err
+//		}
+//	}
+type Rows struct {
+	columns []string
+	types   []ColumnMeta
+	data    [][]interface{}
+	next    int
+	row     []interface{}
+	err     error
+}
+
+// Iter returns an iterator over the rows of the response, Rows being its row count.
+func (r *Response) Iter() *Rows {
+	rows := &Rows{data: r.Data}
+	rows.types, rows.err = r.columnMetas()
+	for _, c := range rows.types {
+		rows.columns = append(rows.columns, c.Name)
+	}
+	return rows
+}
+
+// ColumnTypes returns the typed column meta.
+func (rs *Rows) ColumnTypes() []ColumnMeta {
+	return rs.types
+}
+
+// Err returns the error of the column meta of the response, if any.
+func (rs *Rows) Err() error {
+	return rs.err
+}
+
+// Columns returns the column names.
+func (rs *Rows) Columns() []string {
+	return rs.columns
+}
+
+// Next moves to the next row, it returns false after the last one.
+func (rs *Rows) Next() bool {
+	if rs.err != nil || rs.next >= len(rs.data) {
+		rs.row = nil
+		return false
+	}
+	rs.row = rs.data[rs.next]
+	rs.next++
+	return true
+}
+
+// Scan copies the columns of the current row into dest, one per column. The
+// values are converted to the type of dest: a NULL can only be scanned into a
+// pointer, an interface or a sql.Scanner such as sql.NullInt64. A *interface{}
+// receives int64, uint64, float64, bool, string or time.Time by column type.
+func (rs *Rows) Scan(dest ...interface{}) error {
+	if rs.row == nil {
+		return errors.New("scan called without calling next")
+	}
+	if len(dest) != len(rs.columns) {
+		return fmt.Errorf("expected %d destination arguments in scan, not %d", len(rs.columns), len(dest))
+	}
+	for i, d := range dest {
+		var raw interface{}
+		if i < len(rs.row) {
+			raw = rs.row[i]
+		}
+		v, err := typedValue(raw, rs.types[i].Type)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", rs.columns[i], err)
+		}
+		if err := assignValue(d, v); err != nil {
+			return fmt.Errorf("column %s: %w", rs.columns[i], err)
+		}
+	}
+	return nil
+}
+
+// typedValue converts a value decoded from the response to the Go type of its column.
+func typedValue(v interface{}, typ ColumnType) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch typ {
+	case TypeTimestamp:
+		return timestampValue(v)
+	case TypeTinyInt, TypeSmallInt, TypeInt, TypeBigInt:
+		if n, ok := v.(json.Number); ok {
+			return strconv.ParseInt(string(n), 10, 64)
+		}
+	case TypeUTinyInt, TypeUSmallInt, TypeUInt, TypeUBigInt:
+		if n, ok := v.(json.Number); ok {
+			return strconv.ParseUint(string(n), 10, 64)
+		}
+	case TypeFloat, TypeDouble:
+		if n, ok := v.(json.Number); ok {
+			return n.Float64()
+		}
+	}
+	return v, nil
+}
+
+// assignValue stores v into the pointer dest, converting between numeric types
+// within range.
+func assignValue(dest interface{}, v interface{}) error {
+	switch d := dest.(type) {
+	case sql.Scanner:
+		return d.Scan(v)
+	case *interface{}:
+		*d = v
+		return nil
+	}
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() {
+		return fmt.Errorf("destination not a pointer: %T", dest)
+	}
+	return setValue(dv.Elem(), v)
+}
+
+func setValue(dv reflect.Value, v interface{}) error {
+	if v == nil {
+		switch dv.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return fmt.Errorf("converting NULL to %s is unsupported", dv.Type())
+	}
+	if dv.Kind() == reflect.Pointer {
+		p := reflect.New(dv.Type().Elem())
+		if err := setValue(p.Elem(), v); err != nil {
+			return err
+		}
+		dv.Set(p)
+		return nil
+	}
+	sv := reflect.ValueOf(v)
+	if sv.Type().AssignableTo(dv.Type()) {
+		dv.Set(sv)
+		return nil
+	}
+
+	switch dv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch v := v.(type) {
+		case int64:
+			n = v
+		case uint64:
+			if v > math.MaxInt64 {
+				return fmt.Errorf("converting %d to %s: value out of range", v, dv.Type())
+			}
+			n = int64(v)
+		default:
+			return fmt.Errorf("converting %T to %s is unsupported", v, dv.Type())
+		}
+		if dv.OverflowInt(n) {
+			return fmt.Errorf("converting %d to %s: value out of range", n, dv.Type())
+		}
+		dv.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		switch v := v.(type) {
+		case uint64:
+			n = v
+		case int64:
+			if v < 0 {
+				return fmt.Errorf("converting %d to %s: value out of range", v, dv.Type())
+			}
+			n = uint64(v)
+		default:
+			return fmt.Errorf("converting %T to %s is unsupported", v, dv.Type())
+		}
+		if dv.OverflowUint(n) {
+			return fmt.Errorf("converting %d to %s: value out of range", n, dv.Type())
+		}
+		dv.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, ok, err := numericValue(v)
+		if err != nil || !ok {
+			return fmt.Errorf("converting %T to %s is unsupported", v, dv.Type())
+		}
+		dv.SetFloat(f)
+		return nil
+	case reflect.String:
+		if t, ok := v.(time.Time); ok {
+			dv.SetString(t.Format(time.RFC3339Nano))
+		} else {
+			dv.SetString(fmt.Sprint(v))
+		}
+		return nil
+	case reflect.Slice:
+		if s, ok := v.(string); ok && dv.Type().Elem().Kind() == reflect.Uint8 {
+			dv.SetBytes([]byte(s))
+			return nil
+		}
+	}
+	return fmt.Errorf("converting %T to %s is unsupported", v, dv.Type())
+}
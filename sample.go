@@ -0,0 +1,109 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SampleStrategy selects the rows returned by SampleRows.
+type SampleStrategy int8
+
+const (
+	_ SampleStrategy = iota
+	// SampleFirst returns the oldest rows.
+	SampleFirst
+	// SampleLast returns the newest rows, oldest first.
+	SampleLast
+	// SampleSpread returns rows spread over the time range of the table, the first
+	// row of each of n windows of equal length. Windows without rows return none.
+	SampleSpread
+)
+
+// SampleRows returns at most n rows of the table, or super table, for previews,
+// without scanning it in full.
+func (client *tsdbClient) SampleRows(ctx context.Context, table string, n int, strategy SampleStrategy) (ResultSet, error) {
+	if client.httpClient == nil || client.initialErr != nil {
+		return ResultSet{}, fmt.Errorf("not created http client for tdengine: %v", client.initialErr)
+	}
+	if n <= 0 {
+		return ResultSet{}, errors.New("invalid args: `n` must be positive")
+	}
+	from, err := quoteQualified(table)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	limit := " limit " + strconv.Itoa(n)
+
+	var rs ResultSet
+	switch strategy {
+	case SampleFirst:
+		rs = client.fetch(ctx, client.sampleQuery("select * from "+from+" order by _rowts asc"+limit))
+	case SampleLast:
+		rs = client.fetch(ctx, client.sampleQuery("select * from "+from+" order by _rowts desc"+limit))
+		for i, j := 0, len(rs.Rows)-1; i < j; i, j = i+1, j-1 {
+			rs.Rows[i], rs.Rows[j] = rs.Rows[j], rs.Rows[i]
+		}
+	case SampleSpread:
+		return client.sampleSpread(ctx, from, n)
+	default:
+		return ResultSet{}, fmt.Errorf("not support strategy: %d", strategy)
+	}
+	return rs, rs.Err
+}
+
+// sampleSpread returns the first row of n windows over the time range of the table.
+func (client *tsdbClient) sampleSpread(ctx context.Context, from string, n int) (ResultSet, error) {
+	bounds := client.fetch(ctx, client.sampleQuery("select first(_rowts), last(_rowts) from "+from))
+	if bounds.Err != nil {
+		return ResultSet{}, bounds.Err
+	}
+	if len(bounds.Rows) == 0 || len(bounds.Rows[0]) < 2 || bounds.Rows[0][0] == nil {
+		// an empty table, queried for its columns
+		rs := client.fetch(ctx, client.sampleQuery("select * from "+from+" limit 0"))
+		return rs, rs.Err
+	}
+	first, err := timestampValue(bounds.Rows[0][0])
+	if err != nil {
+		return ResultSet{}, err
+	}
+	last, err := timestampValue(bounds.Rows[0][1])
+	if err != nil {
+		return ResultSet{}, err
+	}
+
+	unit, err := precisionUnit(client.dbConfig.Precision)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	// windows of a whole number of units, covering the last row
+	window := (last.Sub(first)/time.Duration(n)/unit + 1) * unit
+	start, err := timestampLiteral(first, client.dbConfig.Precision)
+	if err != nil {
+		return ResultSet{}, err
+	}
+	sql := fmt.Sprintf("select first(*) from %s where _rowts >= %s interval(%s) limit %d", from, start, Interval(window), n)
+	rs := client.fetch(ctx, client.sampleQuery(sql))
+	if rs.Err != nil {
+		return ResultSet{}, rs.Err
+	}
+	for i, c := range rs.Columns {
+		// first(current) is named current
+		if strings.HasPrefix(c, "first(") && strings.HasSuffix(c, ")") {
+			rs.Columns[i] = strings.Trim(c[len("first("):len(c)-1], "`")
+		}
+	}
+	return rs, nil
+}
+
+func (client *tsdbClient) sampleQuery(sql string) Query {
+	return NewQuery(sql, client.dbConfig.DBName, client.dbConfig.Precision)
+}
+
+// SampleRows returns at most n rows of the table with the default client.
+func SampleRows(ctx context.Context, table string, n int, strategy SampleStrategy) (ResultSet, error) {
+	return clientWrapper.SampleRows(ctx, table, n, strategy)
+}
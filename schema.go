@@ -0,0 +1,278 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnType is a TDengine data type.
+type ColumnType string
+
+const (
+	TypeTimestamp ColumnType = "TIMESTAMP"
+	TypeBool      ColumnType = "BOOL"
+	TypeTinyInt   ColumnType = "TINYINT"
+	TypeSmallInt  ColumnType = "SMALLINT"
+	TypeInt       ColumnType = "INT"
+	TypeBigInt    ColumnType = "BIGINT"
+	TypeUTinyInt  ColumnType = "TINYINT UNSIGNED"
+	TypeUSmallInt ColumnType = "SMALLINT UNSIGNED"
+	TypeUInt      ColumnType = "INT UNSIGNED"
+	TypeUBigInt   ColumnType = "BIGINT UNSIGNED"
+	TypeFloat     ColumnType = "FLOAT"
+	TypeDouble    ColumnType = "DOUBLE"
+	TypeBinary    ColumnType = "BINARY"
+	TypeVarchar   ColumnType = "VARCHAR"
+	TypeNchar     ColumnType = "NCHAR"
+	TypeJSON      ColumnType = "JSON"
+	TypeVarBinary ColumnType = "VARBINARY"
+	TypeGeometry  ColumnType = "GEOMETRY"
+)
+
+// hasLength reports whether the type requires a length.
+func (t ColumnType) hasLength() bool {
+	return t == TypeBinary || t == TypeVarchar || t == TypeNchar
+}
+
+// Column is a column or tag definition, Length is required by BINARY, VARCHAR and NCHAR.
+type Column struct {
+	Name   string
+	Type   ColumnType
+	Length int
+}
+
+func (c Column) definition() (string, error) {
+	name, err := QuoteIdent(c.Name)
+	if err != nil {
+		return "", err
+	}
+	if len(c.Type) == 0 {
+		return "", fmt.Errorf("invalid args: column %s has no type", c.Name)
+	}
+	if c.Type.hasLength() {
+		if c.Length <= 0 {
+			return "", fmt.Errorf("invalid args: column %s of type %s has no length", c.Name, c.Type)
+		}
+		return fmt.Sprintf("%s %s(%d)", name, c.Type, c.Length), nil
+	}
+	return fmt.Sprintf("%s %s", name, c.Type), nil
+}
+
+// DatabaseOptions are the options of CreateDatabase, zero values use the server defaults.
+type DatabaseOptions struct {
+	// Precision is the timestamp precision, "ms", "us" or "ns".
+	Precision string
+
+	// Keep is how long data is retained, in minutes granularity.
+	Keep time.Duration
+
+	// Duration is the time range stored in one data file, in minutes granularity.
+	Duration time.Duration
+
+	Replica  int
+	VGroups  int
+	Buffer   int
+	WalLevel int
+
+	// CacheModel is one of "none", "last_row", "last_value" and "both".
+	CacheModel string
+}
+
+// CreateDatabaseSQL returns the DDL creating the database if it does not exist.
+func CreateDatabaseSQL(name string, opts DatabaseOptions) (string, error) {
+	db, err := QuoteIdent(name)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("create database if not exists ")
+	b.WriteString(db)
+	if len(opts.Precision) > 0 {
+		switch opts.Precision {
+		case "ms", "us", "ns":
+		default:
+			return "", fmt.Errorf("invalid args: unsupported precision %s", opts.Precision)
+		}
+		b.WriteString(" precision " + QuoteString(opts.Precision))
+	}
+	if opts.Keep > 0 {
+		b.WriteString(" keep " + formatMinutesLiteral(opts.Keep))
+	}
+	if opts.Duration > 0 {
+		b.WriteString(" duration " + formatMinutesLiteral(opts.Duration))
+	}
+	if opts.Replica > 0 {
+		b.WriteString(" replica " + strconv.Itoa(opts.Replica))
+	}
+	if opts.VGroups > 0 {
+		b.WriteString(" vgroups " + strconv.Itoa(opts.VGroups))
+	}
+	if opts.Buffer > 0 {
+		b.WriteString(" buffer " + strconv.Itoa(opts.Buffer))
+	}
+	if opts.WalLevel > 0 {
+		b.WriteString(" wal_level " + strconv.Itoa(opts.WalLevel))
+	}
+	if len(opts.CacheModel) > 0 {
+		b.WriteString(" cachemodel " + QuoteString(opts.CacheModel))
+	}
+	return b.String(), nil
+}
+
+// formatMinutesLiteral formats d as a duration literal in days, hours or minutes.
+func formatMinutesLiteral(d time.Duration) string {
+	m := int64(d / time.Minute)
+	if m <= 0 {
+		m = 1
+	}
+	switch {
+	case m%(24*60) == 0:
+		return strconv.FormatInt(m/(24*60), 10) + "d"
+	case m%60 == 0:
+		return strconv.FormatInt(m/60, 10) + "h"
+	default:
+		return strconv.FormatInt(m, 10) + "m"
+	}
+}
+
+// CreateSTableSQL returns the DDL creating the super table if it does not exist.
+// The first column must be of type TIMESTAMP, it is added as "_ts" when missing.
+func CreateSTableSQL(name string, columns, tags []Column) (string, error) {
+	stable, err := QuoteIdent(name)
+	if err != nil {
+		return "", err
+	}
+	if len(columns) == 0 || len(tags) == 0 {
+		return "", errors.New("invalid args: super table needs columns and tags")
+	}
+	if columns[0].Type != TypeTimestamp {
+		columns = append([]Column{{Name: DefaultStmtTimestampColumn, Type: TypeTimestamp}}, columns...)
+	}
+
+	cols, err := columnDefinitions(columns)
+	if err != nil {
+		return "", err
+	}
+	tagDefs, err := columnDefinitions(tags)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("create stable if not exists %s (%s) tags (%s)", stable, cols, tagDefs), nil
+}
+
+func columnDefinitions(columns []Column) (string, error) {
+	defs := make([]string, len(columns))
+	for i, c := range columns {
+		def, err := c.definition()
+		if err != nil {
+			return "", err
+		}
+		defs[i] = def
+	}
+	return strings.Join(defs, ", "), nil
+}
+
+// CreateChildTableSQL returns the DDL creating the child table of the super table
+// if it does not exist, with the tags set to the values by name.
+func CreateChildTableSQL(name, stable string, tagValues map[string]interface{}) (string, error) {
+	table, err := QuoteIdent(name)
+	if err != nil {
+		return "", err
+	}
+	st, err := QuoteIdent(stable)
+	if err != nil {
+		return "", err
+	}
+	if len(tagValues) == 0 {
+		return "", errors.New("invalid args: child table needs tag values")
+	}
+
+	names := make([]string, 0, len(tagValues))
+	for k := range tagValues {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	tags := make([]string, len(names))
+	values := make([]string, len(names))
+	for i, k := range names {
+		if tags[i], err = QuoteIdent(k); err != nil {
+			return "", err
+		}
+		if values[i], err = formatParameter(tagValues[k]); err != nil {
+			return "", fmt.Errorf("tag %s: %v", k, err)
+		}
+	}
+	return fmt.Sprintf("create table if not exists %s using %s (%s) tags (%s)",
+		table, st, strings.Join(tags, ", "), strings.Join(values, ", ")), nil
+}
+
+// exec runs a statement without result rows, in the client database unless database is empty.
+func (client *tsdbClient) exec(sql string, database string) error {
+	if client.httpClient == nil || client.initialErr != nil {
+		return fmt.Errorf("not created http client for tdengine: %v", client.initialErr)
+	}
+	resp, err := client.httpClient.Query(NewQuery(sql, database, client.dbConfig.Precision))
+	if err != nil {
+		return err
+	}
+	return resp.Error()
+}
+
+// execContext runs a statement without result rows in the client database, with a
+// context controlling cancellation and deadline.
+func (client *tsdbClient) execContext(ctx context.Context, sql string) error {
+	if client.httpClient == nil || client.initialErr != nil {
+		return fmt.Errorf("not created http client for tdengine: %v", client.initialErr)
+	}
+	resp, err := client.httpClient.QueryContext(ctx, withContextOptions(ctx, NewQuery(sql, client.dbConfig.DBName, client.dbConfig.Precision)))
+	if err != nil {
+		return err
+	}
+	return resp.Error()
+}
+
+// CreateDatabase creates the database if it does not exist.
+func (client *tsdbClient) CreateDatabase(name string, opts DatabaseOptions) error {
+	sql, err := CreateDatabaseSQL(name, opts)
+	if err != nil {
+		return err
+	}
+	return client.exec(sql, "")
+}
+
+// CreateSTable creates the super table in the client database if it does not exist.
+func (client *tsdbClient) CreateSTable(name string, columns, tags []Column) error {
+	sql, err := CreateSTableSQL(name, columns, tags)
+	if err != nil {
+		return err
+	}
+	return client.exec(sql, client.dbConfig.DBName)
+}
+
+// CreateChildTable creates the child table in the client database if it does not exist.
+func (client *tsdbClient) CreateChildTable(name, stable string, tagValues map[string]interface{}) error {
+	sql, err := CreateChildTableSQL(name, stable, tagValues)
+	if err != nil {
+		return err
+	}
+	return client.exec(sql, client.dbConfig.DBName)
+}
+
+func CreateDatabase(name string, opts DatabaseOptions) error {
+	return clientWrapper.CreateDatabase(name, opts)
+}
+
+func CreateSTable(name string, columns, tags []Column) error {
+	return clientWrapper.CreateSTable(name, columns, tags)
+}
+
+func CreateChildTable(name, stable string, tagValues map[string]interface{}) error {
+	return clientWrapper.CreateChildTable(name, stable, tagValues)
+}
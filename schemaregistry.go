@@ -0,0 +1,295 @@
+package tsdbclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const defaultSchemaTTL = 5 * time.Minute
+
+// STableSchema is the schema of a super table, as reported by DESCRIBE.
+type STableSchema struct {
+	Name string
+
+	// Columns are the columns in order, the first is the timestamp.
+	Columns []Column
+
+	// Tags are the tags in order.
+	Tags []Column
+}
+
+// Lookup returns the column or tag of the name.
+func (s *STableSchema) Lookup(name string) (Column, bool) {
+	for _, c := range s.Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	for _, c := range s.Tags {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+// Check reports whether v can be written to the column or tag of the name.
+func (s *STableSchema) Check(name string, v interface{}) error {
+	_, err := s.Coerce(name, v)
+	return err
+}
+
+// Coerce converts v to the Go type written to the column or tag of the name:
+// int64 or uint64 for the integers, float64, bool, string or time.Time. Numbers
+// are converted when they fit, strings parsed, and strings longer than the
+// column refused.
+func (s *STableSchema) Coerce(name string, v interface{}) (interface{}, error) {
+	c, ok := s.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("column %s not found in %s", name, s.Name)
+	}
+	out, err := coerceValue(v, c)
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %w", name, err)
+	}
+	return out, nil
+}
+
+func coerceValue(v interface{}, c Column) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if n, ok := v.(json.Number); ok {
+		v = string(n)
+	}
+	switch c.Type {
+	case TypeTimestamp:
+		switch v := v.(type) {
+		case time.Time, int64:
+			return v, nil
+		case string:
+			return time.Parse(time.RFC3339Nano, v)
+		}
+	case TypeBool:
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+	case TypeTinyInt, TypeSmallInt, TypeInt, TypeBigInt:
+		bits := c.Type.bits()
+		var n int64
+		switch v := v.(type) {
+		case string:
+			return strconv.ParseInt(v, 10, bits)
+		case int64:
+			n = v
+		case int:
+			n = int64(v)
+		case uint64:
+			if v > math.MaxInt64 {
+				return nil, fmt.Errorf("value %d out of range of %s", v, c.Type)
+			}
+			n = int64(v)
+		case float64:
+			if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+				return nil, fmt.Errorf("value %v is not an integer of %s", v, c.Type)
+			}
+			n = int64(v)
+		default:
+			return nil, fmt.Errorf("unable to write %T to %s", v, c.Type)
+		}
+		if bits < 64 && (n < -1<<(bits-1) || n >= 1<<(bits-1)) {
+			return nil, fmt.Errorf("value %d out of range of %s", n, c.Type)
+		}
+		return n, nil
+	case TypeUTinyInt, TypeUSmallInt, TypeUInt, TypeUBigInt:
+		bits := c.Type.bits()
+		var n uint64
+		switch v := v.(type) {
+		case string:
+			return strconv.ParseUint(v, 10, bits)
+		case uint64:
+			n = v
+		case int64:
+			if v < 0 {
+				return nil, fmt.Errorf("value %d out of range of %s", v, c.Type)
+			}
+			n = uint64(v)
+		case int:
+			if v < 0 {
+				return nil, fmt.Errorf("value %d out of range of %s", v, c.Type)
+			}
+			n = uint64(v)
+		case float64:
+			if v != math.Trunc(v) || v < 0 || v >= math.MaxUint64 {
+				return nil, fmt.Errorf("value %v is not an integer of %s", v, c.Type)
+			}
+			n = uint64(v)
+		default:
+			return nil, fmt.Errorf("unable to write %T to %s", v, c.Type)
+		}
+		if bits < 64 && n >= 1<<bits {
+			return nil, fmt.Errorf("value %d out of range of %s", n, c.Type)
+		}
+		return n, nil
+	case TypeFloat, TypeDouble:
+		if s, ok := v.(string); ok {
+			return strconv.ParseFloat(s, 64)
+		}
+		if f, ok, _ := numericValue(v); ok {
+			if c.Type == TypeFloat && math.Abs(f) > math.MaxFloat32 {
+				return nil, fmt.Errorf("value %v out of range of %s", v, c.Type)
+			}
+			return f, nil
+		}
+	default:
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		default:
+			return nil, fmt.Errorf("unable to write %T to %s", v, c.Type)
+		}
+		size := len(s)
+		if c.Type == TypeNchar {
+			size = utf8.RuneCountInString(s)
+		}
+		if c.Length > 0 && size > c.Length {
+			return nil, fmt.Errorf("value of length %d longer than %s(%d)", size, c.Type, c.Length)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unable to write %T to %s", v, c.Type)
+}
+
+// bits returns the size of an integer type.
+func (t ColumnType) bits() int {
+	switch t {
+	case TypeTinyInt, TypeUTinyInt:
+		return 8
+	case TypeSmallInt, TypeUSmallInt:
+		return 16
+	case TypeInt, TypeUInt:
+		return 32
+	}
+	return 64
+}
+
+// schemaRegistry caches the schemas of the super tables described, by database
+// and name, for ttl. It is shared by a client and its derived clients.
+type schemaRegistry struct {
+	ttl time.Duration
+
+	lock    sync.Mutex
+	entries map[string]schemaEntry
+}
+
+type schemaEntry struct {
+	schema  *STableSchema
+	expires time.Time
+}
+
+func newSchemaRegistry(ttl time.Duration) *schemaRegistry {
+	if ttl <= 0 {
+		ttl = defaultSchemaTTL
+	}
+	return &schemaRegistry{ttl: ttl, entries: make(map[string]schemaEntry)}
+}
+
+func (r *schemaRegistry) get(key string) (*STableSchema, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	e, ok := r.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.schema, true
+}
+
+func (r *schemaRegistry) put(key string, schema *STableSchema) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.entries[key] = schemaEntry{schema: schema, expires: time.Now().Add(r.ttl)}
+}
+
+func (r *schemaRegistry) invalidate(key string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.entries, key)
+}
+
+// Schema returns the schema of the super table in the client database, described
+// once per schema TTL, see SchemaCacheTTL.
+func (client *tsdbClient) Schema(ctx context.Context, stable string) (*STableSchema, error) {
+	if client.httpClient == nil || client.initialErr != nil {
+		return nil, fmt.Errorf("not created http client for tdengine: %v", client.initialErr)
+	}
+	key := client.dbConfig.DBName + "." + stable
+	if schema, ok := client.schemas.get(key); ok {
+		return schema, nil
+	}
+	schema, err := describeSTable(ctx, client.httpClient, client.dbConfig.DBName, stable)
+	if err != nil {
+		return nil, err
+	}
+	client.schemas.put(key, schema)
+	return schema, nil
+}
+
+// InvalidateSchema drops the cached schema of the super table, after altering it.
+func (client *tsdbClient) InvalidateSchema(stable string) {
+	client.schemas.invalidate(client.dbConfig.DBName + "." + stable)
+}
+
+// Schema returns the schema of the super table with the default client.
+func Schema(ctx context.Context, stable string) (*STableSchema, error) {
+	return clientWrapper.Schema(ctx, stable)
+}
+
+// describeSTable runs DESCRIBE, whose rows are field, type, length and note,
+// TAG for the tags.
+func describeSTable(ctx context.Context, c Client, database, stable string) (*STableSchema, error) {
+	name, err := QuoteIdent(stable)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.QueryContext(ctx, Query{Command: "describe " + name, Database: database, NoCache: true})
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Error(); err != nil {
+		return nil, err
+	}
+
+	schema := &STableSchema{Name: stable}
+	for _, row := range resp.Data {
+		if len(row) < 4 {
+			return nil, fmt.Errorf("describe %s: row has %d values, expected 4", stable, len(row))
+		}
+		col := Column{}
+		col.Name, _ = row[0].(string)
+		typ, _ := row[1].(string)
+		col.Type = ColumnType(strings.ToUpper(typ))
+		if n, ok := row[2].(json.Number); ok {
+			length, _ := n.Int64()
+			col.Length = int(length)
+		}
+		if note, _ := row[3].(string); note == "TAG" {
+			schema.Tags = append(schema.Tags, col)
+		} else {
+			schema.Columns = append(schema.Columns, col)
+		}
+	}
+	return schema, nil
+}
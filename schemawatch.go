@@ -0,0 +1,145 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// SchemaChangeKind is the kind of a column change.
+type SchemaChangeKind int8
+
+const (
+	_ SchemaChangeKind = iota
+	// ColumnAdded is a column or tag added.
+	ColumnAdded
+	// ColumnRemoved is a column or tag dropped.
+	ColumnRemoved
+	// ColumnRetyped is a column or tag whose type or length changed.
+	ColumnRetyped
+)
+
+func (k SchemaChangeKind) String() string {
+	switch k {
+	case ColumnAdded:
+		return "added"
+	case ColumnRemoved:
+		return "removed"
+	case ColumnRetyped:
+		return "retyped"
+	}
+	return "unknown"
+}
+
+// SchemaChange is the change of a column or tag, Old is zero for an added one
+// and New for a removed one.
+type SchemaChange struct {
+	Kind SchemaChangeKind
+	Tag  bool
+	Old  Column
+	New  Column
+}
+
+// SchemaChanged reports the changes of a super table between two describes.
+type SchemaChanged struct {
+	STable  string
+	Changes []SchemaChange
+
+	// Schema is the new schema, also stored in the schema registry.
+	Schema *STableSchema
+}
+
+// WatchSchemas describes the super tables every interval and calls onChange with
+// the differences from their previous schema, so long running writers learn
+// about the DDL run by others. The registry of Schema is updated with the new
+// schemas. It runs until ctx is done, a failed describe is retried at the next
+// interval.
+func (client *tsdbClient) WatchSchemas(ctx context.Context, stables []string, interval time.Duration, onChange func(SchemaChanged)) error {
+	if len(stables) == 0 {
+		return errors.New("invalid args: `stables` is empty")
+	}
+	if interval <= 0 {
+		return errors.New("invalid args: `interval` must be positive")
+	}
+	if onChange == nil {
+		return errors.New("invalid args: `onChange` is nil")
+	}
+
+	known := make(map[string]*STableSchema, len(stables))
+	for _, stable := range stables {
+		if schema, err := client.Schema(ctx, stable); err == nil {
+			known[stable] = schema
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		for _, stable := range stables {
+			key := client.dbConfig.DBName + "." + stable
+			schema, err := describeSTable(ctx, client.httpClient, client.dbConfig.DBName, stable)
+			switch {
+			case err == nil:
+				client.schemas.put(key, schema)
+			case errors.Is(err, ErrNotExistsTable) && known[stable] != nil:
+				// dropped, its columns are reported removed
+				client.schemas.invalidate(key)
+				schema = &STableSchema{Name: stable}
+			default:
+				client.log().Warn("describe for schema watch failed", "stable", stable, "error", err)
+				continue
+			}
+
+			old, ok := known[stable]
+			known[stable] = schema
+			if !ok {
+				continue
+			}
+			if changes := diffSchemas(old, schema); len(changes) > 0 {
+				onChange(SchemaChanged{STable: stable, Changes: changes, Schema: schema})
+			}
+		}
+	}
+}
+
+// WatchSchemas watches the super tables with the default client.
+func WatchSchemas(ctx context.Context, stables []string, interval time.Duration, onChange func(SchemaChanged)) error {
+	return clientWrapper.WatchSchemas(ctx, stables, interval, onChange)
+}
+
+// diffSchemas returns the columns then the tags changed from old to new, in
+// the order of new followed by those removed.
+func diffSchemas(old, new *STableSchema) []SchemaChange {
+	changes := diffColumns(old.Columns, new.Columns, false)
+	return append(changes, diffColumns(old.Tags, new.Tags, true)...)
+}
+
+func diffColumns(old, new []Column, tag bool) []SchemaChange {
+	before := make(map[string]Column, len(old))
+	for _, c := range old {
+		before[c.Name] = c
+	}
+	var changes []SchemaChange
+	for _, c := range new {
+		prev, ok := before[c.Name]
+		switch {
+		case !ok:
+			changes = append(changes, SchemaChange{Kind: ColumnAdded, Tag: tag, New: c})
+		case prev != c:
+			changes = append(changes, SchemaChange{Kind: ColumnRetyped, Tag: tag, Old: prev, New: c})
+		}
+		delete(before, c.Name)
+	}
+	for _, c := range old {
+		if _, ok := before[c.Name]; ok {
+			changes = append(changes, SchemaChange{Kind: ColumnRemoved, Tag: tag, Old: c})
+		}
+	}
+	return changes
+}
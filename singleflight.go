@@ -0,0 +1,107 @@
+package tsdbclient
+
+import (
+	"context"
+	"sync"
+)
+
+// queryFlight collapses identical read-only queries running at the same time
+// into one request to the server, the callers share its result.
+type queryFlight struct {
+	policy LimitPolicy
+
+	lock  sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is a request in flight and the callers waiting for it. The request
+// is cancelled when all of them gave up.
+type flightCall struct {
+	done    chan struct{}
+	resp    *Response
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// newQueryFlight returns the flights of the queries of a client limited by
+// policy. The queries share a flight only with the same limit and label.
+func newQueryFlight(policy LimitPolicy) *queryFlight {
+	return &queryFlight{policy: policy, calls: make(map[string]*flightCall)}
+}
+
+// Wrap returns a client collapsing the identical queries. The shared responses
+// share their rows, which must not be modified.
+func (f *queryFlight) Wrap(client Client) Client {
+	return &collapsingClient{Client: client, flight: f}
+}
+
+// do runs fn once for the callers of key arriving before it completes.
+func (f *queryFlight) do(ctx context.Context, key string, fn func(ctx context.Context) (*Response, error)) (*Response, error) {
+	f.lock.Lock()
+	call, ok := f.calls[key]
+	if !ok {
+		// the request outlives the caller starting it while others wait for it,
+		// bounded by its deadline
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		if deadline, ok := ctx.Deadline(); ok {
+			cancel()
+			callCtx, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+		}
+		call = &flightCall{done: make(chan struct{}), cancel: cancel}
+		f.calls[key] = call
+		go func() {
+			call.resp, call.err = fn(callCtx)
+			f.lock.Lock()
+			if f.calls[key] == call {
+				delete(f.calls, key)
+			}
+			f.lock.Unlock()
+			cancel()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	f.lock.Unlock()
+
+	select {
+	case <-call.done:
+		if call.resp == nil {
+			return nil, call.err
+		}
+		resp := *call.resp
+		return &resp, call.err
+	case <-ctx.Done():
+		f.lock.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// the callers arriving next start a new request
+			call.cancel()
+			if f.calls[key] == call {
+				delete(f.calls, key)
+			}
+		}
+		f.lock.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+type collapsingClient struct {
+	Client
+	flight *queryFlight
+}
+
+func (c *collapsingClient) Query(q Query) (*Response, error) {
+	return c.QueryContext(context.Background(), q)
+}
+
+func (c *collapsingClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	q = withContextOptions(ctx, q)
+	if q.NoCache || !cacheableQuery(q.Command) {
+		return c.Client.QueryContext(ctx, q)
+	}
+	key := queryCacheKey(q, policyLimit(ctx, q, c.flight.policy)) + "\x00" + queryLabel(ctx)
+	return c.flight.do(ctx, key, func(ctx context.Context) (*Response, error) {
+		return c.Client.QueryContext(ctx, q)
+	})
+}
@@ -0,0 +1,83 @@
+package tsdbclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// SkewReport is the result of SkewCheck.
+type SkewReport struct {
+	// ServerTime is the server NOW() and LocalTime the client time estimated at the
+	// same instant, halfway through the round trip.
+	ServerTime time.Time
+	LocalTime  time.Time
+
+	// Offset is the server time minus the local time, positive when the local clock is behind.
+	Offset time.Duration
+
+	// RoundTrip bounds the error of Offset.
+	RoundTrip time.Duration
+}
+
+// clockOffset is the measured server clock offset, shared by derived clients.
+type clockOffset struct {
+	adjust bool
+	offset atomic.Int64
+}
+
+// now returns the local time corrected by the measured offset when adjusting.
+func (c *clockOffset) now() time.Time {
+	if c == nil || !c.adjust {
+		return time.Now()
+	}
+	return time.Now().Add(time.Duration(c.offset.Load()))
+}
+
+// SkewCheck compares the server NOW() against the local time. With AdjustClockSkew
+// the measured offset then corrects the timestamps the client assigns to points.
+func (client *tsdbClient) SkewCheck(ctx context.Context) (SkewReport, error) {
+	if client.httpClient == nil || client.initialErr != nil {
+		return SkewReport{}, fmt.Errorf("not created http client for tdengine: %v", client.initialErr)
+	}
+
+	start := time.Now()
+	resp, err := client.httpClient.QueryContext(ctx, NewQuery("select cast(now() as bigint)", "", "ms"))
+	rtt := time.Since(start)
+	if err != nil {
+		return SkewReport{}, err
+	}
+	if err := resp.Error(); err != nil {
+		return SkewReport{}, err
+	}
+	if resp.Rows == 0 || len(resp.Data[0]) == 0 {
+		return SkewReport{}, errors.New("get server time response empty")
+	}
+	num, ok := resp.Data[0][0].(json.Number)
+	if !ok {
+		return SkewReport{}, fmt.Errorf("unexpected server time %v", resp.Data[0][0])
+	}
+	ms, err := num.Int64()
+	if err != nil {
+		return SkewReport{}, fmt.Errorf("unexpected server time %v", num)
+	}
+
+	report := SkewReport{
+		ServerTime: time.UnixMilli(ms),
+		LocalTime:  start.Add(rtt / 2),
+		RoundTrip:  rtt,
+	}
+	report.Offset = report.ServerTime.Sub(report.LocalTime)
+	if client.clock != nil && client.clock.adjust {
+		client.clock.offset.Store(int64(report.Offset))
+	}
+	return report, nil
+}
+
+// SkewCheck compares the server time against the local time with the default client.
+func SkewCheck(ctx context.Context) (SkewReport, error) {
+	return clientWrapper.SkewCheck(ctx)
+}
@@ -0,0 +1,311 @@
+package tsdbclient
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	spoolFileExt           = ".batch"
+	spoolRejectedExt       = ".rejected"
+	defaultSpoolReplayWait = 10 * time.Second
+)
+
+// diskSpool keeps the batches which could not be written because the server
+// was unreachable in segment files of dir, one per batch, and replays them
+// oldest first once the server is reachable again. A segment holds the database
+// and precision on its first two lines followed by the points in line protocol,
+// encrypted with AES-GCM when keys is set.
+type diskSpool struct {
+	dir      string
+	maxBytes int64
+	interval time.Duration
+	keys     SpoolKeyProvider
+	logger   Logger
+
+	seq   atomic.Uint64
+	lock  sync.Mutex // serializes replays
+	size  atomic.Int64
+	stop  chan struct{}
+	done  chan struct{}
+	close sync.Once
+}
+
+func newDiskSpool(dir string, maxBytes int64, keys SpoolKeyProvider, logger Logger) (*diskSpool, error) {
+	if keys != nil {
+		// fail now rather than when the server is unreachable
+		if _, key, err := keys.CurrentKey(); err != nil {
+			return nil, err
+		} else if _, err = newSpoolAEAD(key); err != nil {
+			return nil, fmt.Errorf("invalid args: spool key %v", err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &diskSpool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		interval: defaultSpoolReplayWait,
+		keys:     keys,
+		logger:   logger,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range segments {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			s.size.Add(fi.Size())
+		}
+	}
+	return s, nil
+}
+
+// segments returns the names of the spooled segments, oldest first.
+func (s *diskSpool) segments() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), spoolFileExt) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// put writes the batch to a new segment, points without timestamp are stamped
+// with now so they keep their time when replayed.
+func (s *diskSpool) put(bps BatchPoints, now time.Time) error {
+	precision := bps.Precision()
+	var b bytes.Buffer
+	b.WriteString(bps.Database() + "\n" + precision + "\n")
+	for _, p := range bps.Points() {
+		if p == nil {
+			continue
+		}
+		if p.Time().IsZero() {
+			p.pt.SetTime(now)
+		}
+		b.WriteString(p.pt.PrecisionString(precision))
+		b.WriteByte('\n')
+	}
+	data := b.Bytes()
+	if s.keys != nil {
+		var err error
+		if data, err = sealSegment(s.keys, data); err != nil {
+			return err
+		}
+	}
+
+	if s.maxBytes > 0 && s.size.Load()+int64(len(data)) > s.maxBytes {
+		return fmt.Errorf("spool %s full", s.dir)
+	}
+
+	// names sort by creation, the sequence orders segments of the same nanosecond
+	name := fmt.Sprintf("%020d-%010d%s", now.UnixNano(), s.seq.Add(1), spoolFileExt)
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		return err
+	}
+	s.size.Add(int64(len(data)))
+	return nil
+}
+
+// read loads a segment as a batch.
+func (s *diskSpool) read(name string) (BatchPoints, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	// the segments spooled before the encryption was enabled are plain
+	if data, err = openSegment(s.keys, data); err != nil {
+		return nil, fmt.Errorf("spool segment %s: %w", name, err)
+	}
+	r := bufio.NewReader(bytes.NewReader(data))
+	db, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("spool segment %s: %v", name, err)
+	}
+	precision, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("spool segment %s: %v", name, err)
+	}
+	bps, err := NewBatchPoints(BatchPointsConfig{Database: strings.TrimSuffix(db, "\n"), Precision: strings.TrimSuffix(precision, "\n")})
+	if err != nil {
+		return nil, err
+	}
+	rest := data[len(db)+len(precision):]
+	points, err := ParsePoints(rest, bps.Precision())
+	if err != nil {
+		return nil, fmt.Errorf("spool segment %s: %v", name, err)
+	}
+	bps.AddPoints(points)
+	return bps, nil
+}
+
+func (s *diskSpool) remove(name string) {
+	path := filepath.Join(s.dir, name)
+	if fi, err := os.Stat(path); err == nil {
+		s.size.Add(-fi.Size())
+	}
+	if err := os.Remove(path); err != nil {
+		s.logger.Error("spool remove failed", "segment", name, "error", err)
+	}
+}
+
+// replay writes the segments oldest first with write, stopping at the first
+// batch failing because the server is unreachable or its key can't be found.
+// Batches refused by the server are renamed with the .rejected extension and
+// kept for inspection.
+func (s *diskSpool) replay(write func(bps BatchPoints) error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	segments, err := s.segments()
+	if err != nil {
+		s.logger.Error("spool read failed", "dir", s.dir, "error", err)
+		return
+	}
+	for _, name := range segments {
+		bps, err := s.read(name)
+		if err == nil {
+			err = write(bps)
+		}
+		if err == nil {
+			s.remove(name)
+			continue
+		}
+		if isUnreachable(err) {
+			return
+		}
+		var keyErr *spoolKeyError
+		if errors.As(err, &keyErr) {
+			s.logger.Warn("spool segment key not found, retrying later", "segment", name, "error", err)
+			return
+		}
+		s.logger.Warn("spool segment rejected", "segment", name, "error", err)
+		path := filepath.Join(s.dir, name)
+		if fi, e := os.Stat(path); e == nil {
+			s.size.Add(-fi.Size())
+		}
+		if e := os.Rename(path, path+spoolRejectedExt); e != nil {
+			s.logger.Error("spool rename failed", "segment", name, "error", e)
+		}
+	}
+}
+
+// run replays the spool periodically until closed.
+func (s *diskSpool) run(write func(bps BatchPoints) error) {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.replay(write)
+		}
+	}
+}
+
+func (s *diskSpool) shutdown() {
+	s.close.Do(func() {
+		close(s.stop)
+		<-s.done
+	})
+}
+
+// isUnreachable reports whether the write failed because the server could not be
+// reached, as opposed to refusing the batch.
+func isUnreachable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.code {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// deliver writes the batch, spooling it to disk when the server is unreachable.
+func (client *tsdbClient) deliver(bps BatchPoints) error {
+	err := client.writeBackendBatch(bps)
+	if err == nil || client.spool == nil || !isUnreachable(err) {
+		return err
+	}
+	if e := client.spool.put(bps, client.clock.now()); e != nil {
+		return errors.Join(err, e)
+	}
+	client.log().Warn("spooled points after error", "points", len(bps.Points()), "dir", client.spool.dir, "error", err)
+	return nil
+}
+
+// replaySpooled writes a spooled batch through the client of its database.
+func (client *tsdbClient) replaySpooled(bps BatchPoints) error {
+	target := client
+	if bps.Database() != client.dbConfig.DBName {
+		target = client.ForDatabase(bps.Database()).(*tsdbClient)
+	}
+	// the spool keeps the points, not the write parameters of their batch
+	bps.SetTTL(target.writeTTL)
+	bps.SetTableNameKey(target.tableNameKey)
+	return target.writeBackendBatch(bps)
+}
+
+// ReplaySpool writes the batches spooled while the server was unreachable now,
+// instead of waiting for the periodic replay.
+func (client *tsdbClient) ReplaySpool() {
+	if client.spool != nil {
+		client.spool.replay(client.rootClient().replaySpooled)
+	}
+}
+
+func (client *tsdbClient) rootClient() *tsdbClient {
+	if client.parent != nil {
+		return client.parent
+	}
+	return client
+}
+
+// SpooledBytes returns the size of the batches waiting in the spool.
+func (client *tsdbClient) SpooledBytes() int64 {
+	if client.spool == nil {
+		return 0
+	}
+	return client.spool.size.Load()
+}
+
+func ReplaySpool() {
+	clientWrapper.ReplaySpool()
+}
+
+func SpooledBytes() int64 {
+	return clientWrapper.SpooledBytes()
+}
@@ -0,0 +1,130 @@
+package tsdbclient
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// spoolSealMagic starts the encrypted segments, the plain ones start with a
+// database name which can't hold a NUL.
+var spoolSealMagic = []byte("\x00aesgcm\n")
+
+// SpoolKeyProvider provides the AES keys encrypting the spooled segments, of 16,
+// 24 or 32 bytes for AES-128, AES-192 or AES-256. The segments record the id of
+// their key, so the keys may be rotated: the previous ones must still be found
+// by Key until their segments are replayed.
+type SpoolKeyProvider interface {
+	// CurrentKey returns the key encrypting the new segments and its id.
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns the key of id.
+	Key(id string) ([]byte, error)
+}
+
+// StaticSpoolKey returns a provider of a single key, its id derived from it.
+func StaticSpoolKey(key []byte) SpoolKeyProvider {
+	sum := sha256.Sum256(key)
+	return staticSpoolKey{id: hex.EncodeToString(sum[:8]), key: key}
+}
+
+type staticSpoolKey struct {
+	id  string
+	key []byte
+}
+
+func (k staticSpoolKey) CurrentKey() (string, []byte, error) {
+	return k.id, k.key, nil
+}
+
+func (k staticSpoolKey) Key(id string) ([]byte, error) {
+	if id != k.id {
+		return nil, fmt.Errorf("unknown spool key %s", id)
+	}
+	return k.key, nil
+}
+
+// spoolKeyError is an error of the SpoolKeyProvider reading a segment, or its
+// absence. The segment is kept for a later replay: the key may become available.
+type spoolKeyError struct {
+	id  string
+	err error
+}
+
+func (e *spoolKeyError) Error() string {
+	return fmt.Sprintf("spool key %s: %v", e.id, e.err)
+}
+
+func (e *spoolKeyError) Unwrap() error {
+	return e.err
+}
+
+func newSpoolAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealSegment encrypts a segment as the magic, the key id on its own line, the
+// nonce and the sealed data authenticated with the key id.
+func sealSegment(keys SpoolKeyProvider, data []byte) ([]byte, error) {
+	id, key, err := keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte([]byte(id), '\n') >= 0 {
+		return nil, fmt.Errorf("invalid spool key id %q", id)
+	}
+	aead, err := newSpoolAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(spoolSealMagic)+len(id)+1+aead.NonceSize()+len(data)+aead.Overhead())
+	out = append(out, spoolSealMagic...)
+	out = append(out, id...)
+	out = append(out, '\n')
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, []byte(id)), nil
+}
+
+// openSegment decrypts a segment, the plain ones are returned as is.
+func openSegment(keys SpoolKeyProvider, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, spoolSealMagic) {
+		return data, nil
+	}
+	data = data[len(spoolSealMagic):]
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		return nil, errors.New("encrypted segment without key id")
+	}
+	id := string(data[:i])
+	if keys == nil {
+		// kept until a provider is configured
+		return nil, &spoolKeyError{id: id, err: errors.New("no spool key provider")}
+	}
+	key, err := keys.Key(id)
+	if err != nil {
+		return nil, &spoolKeyError{id: id, err: err}
+	}
+	aead, err := newSpoolAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	data = data[i+1:]
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("encrypted segment truncated")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(id))
+}
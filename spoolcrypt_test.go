@@ -0,0 +1,105 @@
+package tsdbclient
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOpenSegment(t *testing.T) {
+	key := StaticSpoolKey(bytes.Repeat([]byte{1}, 32))
+	other := StaticSpoolKey(bytes.Repeat([]byte{2}, 16))
+	data := []byte("power\nms\nmeters,location=california current=10.3 1700000000000\n")
+	sealed, err := sealSegment(key, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name    string
+		keys    SpoolKeyProvider
+		segment []byte
+		keyErr  bool
+		err     bool
+	}{
+		{"sealed", key, sealed, false, false},
+		{"plain", key, data, false, false},
+		{"plain without keys", nil, data, false, false},
+		{"sealed without keys", nil, sealed, true, true},
+		{"unknown key", other, sealed, true, true},
+		{"tampered", key, tampered, false, true},
+		{"truncated", key, sealed[:len(spoolSealMagic)+4], false, true},
+	}
+	for _, tt := range tests {
+		got, err := openSegment(tt.keys, tt.segment)
+		var keyErr *spoolKeyError
+		if isKeyErr := errors.As(err, &keyErr); isKeyErr != tt.keyErr {
+			t.Errorf("%s: key error = %v, want %v", tt.name, isKeyErr, tt.keyErr)
+		}
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && !bytes.Equal(got, data) {
+			t.Errorf("%s: opened %q, want %q", tt.name, got, data)
+		}
+	}
+	if bytes.Contains(sealed, data) {
+		t.Error("sealed segment holds the plain data")
+	}
+}
+
+func TestDiskSpoolReplayEncrypted(t *testing.T) {
+	keys := StaticSpoolKey(bytes.Repeat([]byte{1}, 32))
+	s, err := newDiskSpool(t.TempDir(), 0, keys, defaultLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	points, err := ParsePoints([]byte("meters,location=california current=10.3 1700000000000\nmeters,location=nevada current=11.2 1700000000001\n"), "ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bps, _ := NewBatchPoints(BatchPointsConfig{Database: "power", Precision: "ms"})
+	bps.AddPoints(points)
+	if err := s.put(bps, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the segment is kept while its key can't be found
+	s.keys = nil
+	s.replay(func(BatchPoints) error {
+		t.Error("segment replayed without key")
+		return nil
+	})
+	if segments, _ := s.segments(); len(segments) != 1 {
+		t.Fatalf("%d segments after a replay without key, want 1", len(segments))
+	}
+
+	s.keys = keys
+	var replayed []BatchPoints
+	s.replay(func(bps BatchPoints) error {
+		replayed = append(replayed, bps)
+		return nil
+	})
+	if len(replayed) != 1 {
+		t.Fatalf("%d batches replayed, want 1", len(replayed))
+	}
+	got := replayed[0]
+	if got.Database() != "power" || got.Precision() != "ms" {
+		t.Errorf("replayed batch of %s in %s, want power in ms", got.Database(), got.Precision())
+	}
+	if len(got.Points()) != len(points) {
+		t.Fatalf("%d points replayed, want %d", len(got.Points()), len(points))
+	}
+	for i, p := range got.Points() {
+		if p.pt.String() != points[i].pt.String() {
+			t.Errorf("point %d replayed as %s, want %s", i, p.pt.String(), points[i].pt.String())
+		}
+	}
+	if segments, _ := s.segments(); len(segments) != 0 || s.size.Load() != 0 {
+		t.Errorf("%d segments of %d bytes left after the replay", len(segments), s.size.Load())
+	}
+}
@@ -0,0 +1,87 @@
+package tsdbclient
+
+import "strings"
+
+// FormatSQL returns the canonical form of a statement, so logically identical
+// statements compare equal: whitespace runs become one space, none after "(" or
+// before ")" and ",", one after ",", the words outside quotes are lowercased
+// (TDengine identifiers are case insensitive unless quoted) and the trailing ";"
+// removed. Quoted strings, quoted identifiers and comments are kept verbatim.
+func FormatSQL(sql string) string {
+	var b strings.Builder
+	b.Grow(len(sql))
+	space := false
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := quotedEnd(sql, i)
+			writeSQLSpace(&b, space, sql[i])
+			b.WriteString(sql[i:end])
+			i, space = end, false
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql)
+			} else {
+				end += i + 4
+			}
+			writeSQLSpace(&b, space, c)
+			b.WriteString(sql[i:end])
+			i, space = end, false
+		case isSQLSpace(c):
+			space = b.Len() > 0
+			i++
+		case c == ',':
+			b.WriteString(", ")
+			i, space = i+1, false
+			for i < len(sql) && isSQLSpace(sql[i]) {
+				i++
+			}
+		default:
+			writeSQLSpace(&b, space, c)
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			b.WriteByte(c)
+			i, space = i+1, false
+		}
+	}
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(b.String()), ";"))
+}
+
+// writeSQLSpace writes the space pending before c, unless it follows "(" or ", "
+// or precedes ")" or ",".
+func writeSQLSpace(b *strings.Builder, space bool, c byte) {
+	if !space || c == ')' || c == ',' {
+		return
+	}
+	s := b.String()
+	if strings.HasSuffix(s, "(") || strings.HasSuffix(s, " ") {
+		return
+	}
+	b.WriteByte(' ')
+}
+
+// quotedEnd returns the index after the quote closing the one at i, a quote is
+// escaped by a backslash or by doubling it.
+func quotedEnd(sql string, i int) int {
+	q := sql[i]
+	for j := i + 1; j < len(sql); j++ {
+		switch sql[j] {
+		case '\\':
+			j++
+		case q:
+			if j+1 < len(sql) && sql[j+1] == q {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(sql)
+}
+
+func isSQLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
@@ -0,0 +1,26 @@
+package tsdbclient
+
+import "testing"
+
+func TestFormatSQL(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"select * from meters", "select * from meters"},
+		{"  SELECT  *\n\tFROM   Meters ;  ", "select * from meters"},
+		{"select avg( current ) ,max(voltage)from meters", "select avg(current), max(voltage)from meters"},
+		{"select a,b , c from t", "select a, b, c from t"},
+		{"select * from t where name = 'Foo  Bar'", "select * from t where name = 'Foo  Bar'"},
+		{"select `Col` from T", "select `Col` from t"},
+		{"select \"A\" from t", "select \"A\" from t"},
+		{"/* Job=Rollup */  SELECT 1", "/* Job=Rollup */ select 1"},
+		{"select 1;;", "select 1"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := FormatSQL(tt.sql); got != tt.want {
+			t.Errorf("FormatSQL(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
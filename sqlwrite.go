@@ -0,0 +1,175 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxInsertSQLLength keeps the statements of SQLBackend below the default
+// maxSQLLength of the server, 1MB.
+const maxInsertSQLLength = 1000 * 1000
+
+// InsertSQL renders the batch as INSERT INTO ... USING ... TAGS ... VALUES
+// statements, one child table per series named as by the stmt backend, so the
+// super tables must exist and are never altered. Statements are split to stay
+// below the server limit of SQL length.
+func InsertSQL(bp BatchPoints, tsColumn string) ([]string, error) {
+	return insertSQL(bp, tsColumn, nil)
+}
+
+// insertSQL is InsertSQL coercing the values of the super tables with a schema
+// to the types of their columns.
+func insertSQL(bp BatchPoints, tsColumn string, schemas map[string]*STableSchema) ([]string, error) {
+	if len(tsColumn) == 0 {
+		tsColumn = DefaultStmtTimestampColumn
+	}
+	groups, order := groupStmtPoints(bp.Points())
+
+	var statements []string
+	var b strings.Builder
+	for _, key := range order {
+		g := groups[key]
+		header, rows, err := g.insertClause(tsColumn, schemas[g.stable])
+		if err != nil {
+			return nil, err
+		}
+		// the rows of a child table are split over statements too, each repeating the header
+		open := false
+		for _, row := range rows {
+			size := len(row) + 1
+			if !open {
+				size += len(header) + 1
+			}
+			if b.Len() > 0 && b.Len()+size > maxInsertSQLLength {
+				statements = append(statements, b.String())
+				b.Reset()
+				open = false
+			}
+			if b.Len() == 0 {
+				b.WriteString("insert into")
+			}
+			if !open {
+				b.WriteString(" " + header)
+				open = true
+			}
+			b.WriteString(" " + row)
+		}
+	}
+	if b.Len() > 0 {
+		statements = append(statements, b.String())
+	}
+	return statements, nil
+}
+
+// insertClause renders the header of the child table, up to "values", and its
+// rows, points without timestamp are written at the server time. The values are
+// checked against schema when not nil.
+func (g *stmtGroup) insertClause(tsColumn string, schema *STableSchema) (string, []string, error) {
+	if len(g.tags) == 0 {
+		return "", nil, fmt.Errorf("sql write requires at least one tag, measurement: %s", g.stable)
+	}
+	stable, err := QuoteIdent(g.stable)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tagNames := make([]string, len(g.tags))
+	tagValues := make([]string, len(g.tags))
+	for i, t := range g.tags {
+		if tagNames[i], err = QuoteIdent(t[0]); err != nil {
+			return "", nil, err
+		}
+		if schema == nil {
+			tagValues[i] = QuoteString(t[1])
+			continue
+		}
+		v, err := schema.Coerce(t[0], t[1])
+		if err != nil {
+			return "", nil, err
+		}
+		if tagValues[i], err = formatParameter(v); err != nil {
+			return "", nil, fmt.Errorf("tag %s: %v", t[0], err)
+		}
+	}
+	ts, err := QuoteIdent(tsColumn)
+	if err != nil {
+		return "", nil, err
+	}
+	colNames := []string{ts}
+	for _, f := range g.fields {
+		name, err := QuoteIdent(f)
+		if err != nil {
+			return "", nil, err
+		}
+		colNames = append(colNames, name)
+	}
+
+	header := fmt.Sprintf("`%s` using %s (%s) tags (%s) (%s) values",
+		g.table, stable, strings.Join(tagNames, ", "), strings.Join(tagValues, ", "), strings.Join(colNames, ", "))
+	rows := make([]string, 0, len(g.rows))
+	for i, row := range g.rows {
+		values := make([]string, 0, len(g.fields)+1)
+		if g.times[i].IsZero() {
+			values = append(values, "now")
+		} else {
+			lit, err := formatParameter(g.times[i])
+			if err != nil {
+				return "", nil, err
+			}
+			values = append(values, lit)
+		}
+		for _, f := range g.fields {
+			v := row[f]
+			if schema != nil {
+				if v, err = schema.Coerce(f, v); err != nil {
+					return "", nil, err
+				}
+			}
+			lit, err := formatParameter(v)
+			if err != nil {
+				return "", nil, fmt.Errorf("field %s: %v", f, err)
+			}
+			values = append(values, lit)
+		}
+		rows = append(rows, "("+strings.Join(values, ", ")+")")
+	}
+	return header, rows, nil
+}
+
+// insertBatch writes the batch as INSERT statements through the sql endpoint.
+// The values are checked against the schemas of the super tables which exist,
+// so a bad point fails the batch before it is sent.
+func (client *tsdbClient) insertBatch(ctx context.Context, bps BatchPoints) error {
+	schemas := make(map[string]*STableSchema)
+	for _, p := range bps.Points() {
+		if p == nil {
+			continue
+		}
+		name := p.Name()
+		if _, ok := schemas[name]; ok {
+			continue
+		}
+		schema, err := client.Schema(ctx, name)
+		if err != nil && !errors.Is(err, ErrNotExistsTable) {
+			return err
+		}
+		schemas[name] = schema
+	}
+
+	statements, err := insertSQL(bps, DefaultStmtTimestampColumn, schemas)
+	if err != nil {
+		return err
+	}
+	for _, sql := range statements {
+		resp, err := client.httpClient.QueryContext(ctx, NewQuery(sql, bps.Database(), bps.Precision()))
+		if err != nil {
+			return err
+		}
+		if err := resp.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
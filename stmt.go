@@ -0,0 +1,346 @@
+package tsdbclient
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taosdata/driver-go/v3/common"
+	"github.com/taosdata/driver-go/v3/common/param"
+	"github.com/taosdata/driver-go/v3/ws/stmt"
+)
+
+type BackendMode int8
+
+const (
+	_ BackendMode = iota
+	LineProtocolBackend
+	StmtBackend
+	// SQLBackend writes INSERT statements through the sql endpoint, see InsertSQL.
+	SQLBackend
+)
+
+// DefaultStmtTimestampColumn is the timestamp column name of super tables
+// created by schemaless (line protocol) writes.
+const DefaultStmtTimestampColumn = "_ts"
+
+// StmtWriter writes points through the websocket stmt interface of taosAdapter.
+// Points are grouped per child table and bound as one columnar batch, the
+// super table must already exist.
+type StmtWriter struct {
+	// TimestampColumn is the name of the timestamp column in the super tables,
+	// defaults to DefaultStmtTimestampColumn.
+	TimestampColumn string
+
+	connector *stmt.Connector
+	precision int
+
+	lock  sync.Mutex
+	stmts map[string]*stmt.Stmt
+
+	// users counts the writes and prepared statements of a client using the
+	// connection, see retire.
+	users sync.WaitGroup
+}
+
+// NewStmtWriter connects to the stmt endpoint of taosAdapter at addr (http:// or ws:// form).
+func NewStmtWriter(addr, user, pass, database, precision string) (*StmtWriter, error) {
+	if len(database) == 0 {
+		return nil, errors.New("invalid args: `database` is empty")
+	}
+
+	var prec int
+	switch precision {
+	case "", "s", "ms":
+		prec = common.PrecisionMilliSecond
+	case "us", "u":
+		prec = common.PrecisionMicroSecond
+	case "ns", "n":
+		prec = common.PrecisionNanoSecond
+	default:
+		return nil, fmt.Errorf("unsupported stmt precision: %s", precision)
+	}
+
+	conf := stmt.NewConfig(toWebsocketAddr(addr), 0)
+	_ = conf.SetConnectUser(user)
+	_ = conf.SetConnectPass(pass)
+	_ = conf.SetConnectDB(database)
+	conf.SetAutoReconnect(true)
+
+	connector, err := stmt.NewConnector(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StmtWriter{
+		TimestampColumn: DefaultStmtTimestampColumn,
+		connector:       connector,
+		precision:       prec,
+		stmts:           make(map[string]*stmt.Stmt),
+	}, nil
+}
+
+func toWebsocketAddr(addr string) string {
+	addr = strings.Replace(addr, "https:", "wss:", 1)
+	return strings.Replace(addr, "http:", "ws:", 1)
+}
+
+// Write binds all points of the batch and executes the prepared statements. On
+// an error all statements of the batch are discarded, so none keeps rows bound
+// for the next Write.
+func (w *StmtWriter) Write(bp BatchPoints) (err error) {
+	groups, order := groupStmtPoints(bp.Points())
+	if len(order) == 0 {
+		return nil
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var used []string
+	defer func() {
+		if err != nil {
+			for _, sql := range used {
+				w.discard(sql)
+			}
+		}
+	}()
+
+	for _, key := range order {
+		g := groups[key]
+		sql := g.insertSQL(w.TimestampColumn)
+
+		s, err := w.prepare(sql)
+		if err != nil {
+			return err
+		}
+		used = appendUnique(used, sql)
+		if err = g.bind(s, w.precision); err != nil {
+			return err
+		}
+	}
+
+	for _, sql := range used {
+		if err := w.stmts[sql].Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the prepared statements and the websocket connection.
+func (w *StmtWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for sql := range w.stmts {
+		w.discard(sql)
+	}
+	return w.connector.Close()
+}
+
+// retire closes the writer once its users are done.
+func (w *StmtWriter) retire() {
+	go func() {
+		w.users.Wait()
+		_ = w.Close()
+	}()
+}
+
+func (w *StmtWriter) prepare(sql string) (*stmt.Stmt, error) {
+	if s, ok := w.stmts[sql]; ok {
+		return s, nil
+	}
+	s, err := w.connector.Init()
+	if err != nil {
+		return nil, err
+	}
+	if err = s.Prepare(sql); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	w.stmts[sql] = s
+	return s, nil
+}
+
+func (w *StmtWriter) discard(sql string) {
+	if s, ok := w.stmts[sql]; ok {
+		_ = s.Close()
+		delete(w.stmts, sql)
+	}
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, e := range s {
+		if e == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
+
+// stmtGroup holds the points of one child table.
+type stmtGroup struct {
+	stable string
+	table  string
+	tags   [][2]string
+	fields []string
+	times  []time.Time
+	rows   []map[string]interface{}
+}
+
+func groupStmtPoints(points []*DataPoint) (map[string]*stmtGroup, []string) {
+	groups := make(map[string]*stmtGroup)
+	var order []string
+
+	for _, p := range points {
+		if p == nil {
+			continue
+		}
+		fields, err := p.Fields()
+		if err != nil {
+			continue
+		}
+
+		key := string(p.pt.Key())
+		g, ok := groups[key]
+		if !ok {
+			g = &stmtGroup{
+				stable: p.Name(),
+				table:  fmt.Sprintf("t_%x", md5.Sum([]byte(key))),
+			}
+			for _, t := range p.pt.Tags() {
+				g.tags = append(g.tags, [2]string{string(t.Key), string(t.Value)})
+			}
+			groups[key] = g
+			order = append(order, key)
+		}
+
+		for k := range fields {
+			if !containsString(g.fields, k) {
+				g.fields = append(g.fields, k)
+			}
+		}
+		g.times = append(g.times, p.Time())
+		g.rows = append(g.rows, fields)
+	}
+
+	for _, g := range groups {
+		sort.Strings(g.fields)
+	}
+	return groups, order
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *stmtGroup) insertSQL(tsColumn string) string {
+	tagNames := make([]string, len(g.tags))
+	for i, t := range g.tags {
+		tagNames[i] = "`" + t[0] + "`"
+	}
+	colNames := []string{"`" + tsColumn + "`"}
+	for _, f := range g.fields {
+		colNames = append(colNames, "`"+f+"`")
+	}
+
+	return fmt.Sprintf("insert into ? using `%s` (%s) tags(%s) (%s) values(%s)",
+		g.stable,
+		strings.Join(tagNames, ","), placeholders(len(tagNames)),
+		strings.Join(colNames, ","), placeholders(len(colNames)))
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func (g *stmtGroup) bind(s *stmt.Stmt, precision int) error {
+	if len(g.tags) == 0 {
+		return fmt.Errorf("stmt write requires at least one tag, measurement: %s", g.stable)
+	}
+
+	if err := s.SetTableName(g.table); err != nil {
+		return err
+	}
+
+	tagValues := param.NewParam(len(g.tags))
+	tagTypes := param.NewColumnType(len(g.tags))
+	for i, t := range g.tags {
+		tagValues.SetNchar(i, t[1])
+		tagTypes.AddNchar(len(t[1]))
+	}
+	if err := s.SetTags(tagValues, tagTypes); err != nil {
+		return err
+	}
+
+	n := len(g.rows)
+	params := make([]*param.Param, 0, len(g.fields)+1)
+	types := param.NewColumnType(len(g.fields) + 1).AddTimestamp()
+
+	ts := param.NewParam(n)
+	for i, t := range g.times {
+		ts.SetTimestamp(i, t, precision)
+	}
+	params = append(params, ts)
+
+	for _, f := range g.fields {
+		col := param.NewParam(n)
+		var kind interface{}
+		maxLen := 0
+		for i, row := range g.rows {
+			switch v := row[f].(type) {
+			case nil:
+				col.SetNull(i)
+				continue
+			case float64:
+				col.SetDouble(i, v)
+			case int64:
+				col.SetBigint(i, int(v))
+			case uint64:
+				col.SetUBigint(i, uint(v))
+			case bool:
+				col.SetBool(i, v)
+			case string:
+				col.SetBinary(i, []byte(v))
+				if len(v) > maxLen {
+					maxLen = len(v)
+				}
+			default:
+				return fmt.Errorf("unsupported field type %T of %s", v, f)
+			}
+			if kind == nil {
+				kind = row[f]
+			} else if fmt.Sprintf("%T", kind) != fmt.Sprintf("%T", row[f]) {
+				return fmt.Errorf("conflicting field types of %s: %T and %T", f, kind, row[f])
+			}
+		}
+
+		switch kind.(type) {
+		case float64:
+			types.AddDouble()
+		case int64:
+			types.AddBigint()
+		case uint64:
+			types.AddUBigint()
+		case bool:
+			types.AddBool()
+		default:
+			types.AddBinary(maxLen)
+		}
+		params = append(params, col)
+	}
+
+	if err := s.BindParam(params, types); err != nil {
+		return err
+	}
+	return s.AddBatch()
+}
@@ -0,0 +1,196 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StreamTrigger is when a stream computes the results of its windows.
+type StreamTrigger int8
+
+const (
+	_ StreamTrigger = iota
+	// TriggerAtOnce computes the results on every write.
+	TriggerAtOnce
+	// TriggerWindowClose computes the results of a window when it closes.
+	TriggerWindowClose
+	// TriggerMaxDelay computes the results when a window closes or MaxDelay after a write.
+	TriggerMaxDelay
+)
+
+// StreamOptions are the options of CreateStream, zero values use the server defaults.
+type StreamOptions struct {
+	Trigger StreamTrigger
+
+	// MaxDelay is the delay of TriggerMaxDelay, from 5 seconds.
+	MaxDelay time.Duration
+
+	// Watermark is how late the rows may arrive and still be computed.
+	Watermark time.Duration
+
+	// DeleteMark is how long the intermediate results of windows are kept.
+	DeleteMark time.Duration
+
+	// FillHistory also computes the rows written before the stream was created.
+	FillHistory bool
+
+	// KeepExpired computes the rows arriving after the watermark instead of ignoring them.
+	KeepExpired bool
+
+	// IgnoreUpdate does not compute again the windows of updated rows.
+	IgnoreUpdate bool
+
+	// SubTable is the expression naming the target child table of each group.
+	SubTable string
+
+	// Tags are the tags of the target super table when it is created.
+	Tags []Column
+}
+
+// CreateStreamSQL returns the DDL creating the stream if it does not exist. The
+// stream writes the results of sql into the super table target, created when
+// missing, optionally qualified as "db.stable".
+func CreateStreamSQL(name, target, sql string, opts StreamOptions) (string, error) {
+	stream, err := QuoteIdent(name)
+	if err != nil {
+		return "", err
+	}
+	if len(target) == 0 {
+		return "", errors.New("miss args: `target`")
+	}
+	into, err := quoteQualified(target)
+	if err != nil {
+		return "", err
+	}
+	sql = strings.TrimSpace(sql)
+	if !strings.HasPrefix(strings.ToLower(sql), "select") {
+		return "", fmt.Errorf("invalid args: stream sql must be a select, got %q", sql)
+	}
+
+	var b strings.Builder
+	b.WriteString("create stream if not exists " + stream)
+	switch opts.Trigger {
+	case 0:
+	case TriggerAtOnce:
+		b.WriteString(" trigger at_once")
+	case TriggerWindowClose:
+		b.WriteString(" trigger window_close")
+	case TriggerMaxDelay:
+		if opts.MaxDelay <= 0 {
+			return "", errors.New("invalid args: trigger max_delay without `MaxDelay`")
+		}
+		b.WriteString(" trigger max_delay " + Interval(opts.MaxDelay))
+	default:
+		return "", fmt.Errorf("not support trigger: %d", opts.Trigger)
+	}
+	if opts.Watermark > 0 {
+		b.WriteString(" watermark " + Interval(opts.Watermark))
+	}
+	if opts.KeepExpired {
+		b.WriteString(" ignore expired 0")
+	}
+	if opts.DeleteMark > 0 {
+		b.WriteString(" delete_mark " + Interval(opts.DeleteMark))
+	}
+	if opts.FillHistory {
+		b.WriteString(" fill_history 1")
+	}
+	if opts.IgnoreUpdate {
+		b.WriteString(" ignore update 1")
+	}
+
+	b.WriteString(" into " + into)
+	if len(opts.Tags) > 0 {
+		defs := make([]string, len(opts.Tags))
+		for i, tag := range opts.Tags {
+			if defs[i], err = tag.definition(); err != nil {
+				return "", err
+			}
+		}
+		b.WriteString(" tags (" + strings.Join(defs, ", ") + ")")
+	}
+	if subTable := strings.TrimSpace(opts.SubTable); len(subTable) > 0 {
+		b.WriteString(" subtable(" + subTable + ")")
+	}
+	b.WriteString(" as " + sql)
+	return b.String(), nil
+}
+
+func CreateStream(name, target, sql string, opts StreamOptions) error {
+	return CreateStreamContext(context.Background(), name, target, sql, opts)
+}
+
+// CreateStreamContext is CreateStream with a context controlling cancellation and deadline.
+func CreateStreamContext(ctx context.Context, name, target, sql string, opts StreamOptions) error {
+	stmt, err := CreateStreamSQL(name, target, sql, opts)
+	if err != nil {
+		return err
+	}
+	return execContext(ctx, stmt)
+}
+
+func DropStream(name string) error {
+	return DropStreamContext(context.Background(), name)
+}
+
+// DropStreamContext is DropStream with a context controlling cancellation and deadline.
+func DropStreamContext(ctx context.Context, name string) error {
+	stream, err := QuoteIdent(name)
+	if err != nil {
+		return fmt.Errorf("invalid args: stream %v", err)
+	}
+	return execContext(ctx, "drop stream if exists "+stream)
+}
+
+// StreamInfo is a stream as listed by "show streams".
+type StreamInfo struct {
+	Name    string
+	Created time.Time
+
+	// SQL is the statement the stream was created with.
+	SQL string
+
+	// Status is "ready", "paused" or "failed".
+	Status string
+
+	SourceDB    string
+	TargetDB    string
+	TargetTable string
+
+	// Watermark and Trigger are the options as reported by the server.
+	Watermark string
+	Trigger   string
+}
+
+func ListStreams() ([]StreamInfo, error) {
+	return ListStreamsContext(context.Background())
+}
+
+// ListStreamsContext is ListStreams with a context controlling cancellation and deadline.
+func ListStreamsContext(ctx context.Context) ([]StreamInfo, error) {
+	rows, err := ReadDataContext(ctx, "show streams")
+	if err != nil {
+		return nil, err
+	}
+
+	streams := make([]StreamInfo, 0, len(rows))
+	for _, row := range rows {
+		streams = append(streams, StreamInfo{
+			Name:        adminString(row["stream_name"]),
+			Created:     adminTime(row["create_time"]),
+			SQL:         adminString(row["sql"]),
+			Status:      adminString(row["status"]),
+			SourceDB:    adminString(row["source_db"]),
+			TargetDB:    adminString(row["target_db"]),
+			TargetTable: adminString(row["target_table"]),
+			Watermark:   adminString(row["watermark"]),
+			Trigger:     adminString(row["trigger"]),
+		})
+	}
+	sort.Slice(streams, func(i, j int) bool { return streams[i].Name < streams[j].Name })
+	return streams, nil
+}
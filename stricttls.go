@@ -0,0 +1,78 @@
+package tsdbclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// ErrCertificatePinMismatch is returned by connections to servers whose certificate
+// public key matches none of HTTPConfig.SPKIPins.
+var ErrCertificatePinMismatch = errors.New("server certificate matches no pinned public key")
+
+// SPKIPin returns the pin of the certificate for HTTPConfig.SPKIPins, the base64
+// SHA-256 of its DER SubjectPublicKeyInfo.
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// strictTLSConfig checks the config against StrictTLS and returns the TLS config
+// enforcing it: https only, TLS 1.2 or later, certificate verification against a
+// pinned CA or public key, and a client certificate.
+func strictTLSConfig(conf HTTPConfig, urls ...*url.URL) (*tls.Config, error) {
+	for _, u := range urls {
+		if u.Scheme != "https" {
+			return nil, fmt.Errorf("strict tls: address %s must use https", u.Redacted())
+		}
+	}
+	if conf.InsecureSkipVerify {
+		return nil, errors.New("strict tls: InsecureSkipVerify is not allowed")
+	}
+	if conf.TLSConfig == nil {
+		return nil, errors.New("strict tls: TLSConfig with a client certificate is required")
+	}
+
+	tc := conf.TLSConfig.Clone()
+	if tc.InsecureSkipVerify {
+		return nil, errors.New("strict tls: InsecureSkipVerify is not allowed")
+	}
+	if tc.MaxVersion != 0 && tc.MaxVersion < tls.VersionTLS12 {
+		return nil, errors.New("strict tls: TLS 1.2 or later is required")
+	}
+	if tc.MinVersion < tls.VersionTLS12 {
+		tc.MinVersion = tls.VersionTLS12
+	}
+	if len(tc.Certificates) == 0 && tc.GetClientCertificate == nil {
+		return nil, errors.New("strict tls: a client certificate is required")
+	}
+	if tc.RootCAs == nil && len(conf.SPKIPins) == 0 {
+		return nil, errors.New("strict tls: a pinned CA in TLSConfig.RootCAs or SPKIPins is required")
+	}
+
+	if len(conf.SPKIPins) > 0 {
+		pins := make(map[string]bool, len(conf.SPKIPins))
+		for _, p := range conf.SPKIPins {
+			pins[p] = true
+		}
+		verify := tc.VerifyConnection
+		tc.VerifyConnection = func(cs tls.ConnectionState) error {
+			if verify != nil {
+				if err := verify(cs); err != nil {
+					return err
+				}
+			}
+			for _, cert := range cs.PeerCertificates {
+				if pins[SPKIPin(cert)] {
+					return nil
+				}
+			}
+			return ErrCertificatePinMismatch
+		}
+	}
+	return tc, nil
+}
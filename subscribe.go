@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math/rand"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	tmqcommon "github.com/taosdata/driver-go/v3/common/tmq"
 	"github.com/taosdata/driver-go/v3/ws/tmq"
 )
@@ -28,42 +30,461 @@ type TSDBSubscribedMessage interface {
 	Offset() tmqcommon.Offset
 }
 
+// SubscribeFilter reports whether a subscribed message should be delivered.
+type SubscribeFilter func(msg TSDBSubscribedMessage) bool
+
+// RebalanceFunc receives the vgroup partitions assigned to or revoked from a subscription.
+type RebalanceFunc func(topic string, partitions []tmqcommon.TopicPartition)
+
+const defaultAssignmentCheckInterval = 10 * time.Second
+
+// SubscribeConfig configures a subscription.
+type SubscribeConfig struct {
+	// Filter drops the messages it rejects before delivery, optional.
+	Filter SubscribeFilter
+
+	// OnAssign is called with the partitions newly assigned to the consumer, optional.
+	OnAssign RebalanceFunc
+
+	// OnRevoke is called with the partitions no longer assigned to the consumer,
+	// including all remaining partitions when the subscription stops, optional.
+	OnRevoke RebalanceFunc
+
+	// AssignmentCheckInterval is how often the assignment is checked for changes
+	// when OnAssign or OnRevoke is set, defaults to 10s. The websocket consumer
+	// never invokes rebalance callbacks itself, so changes are found by polling.
+	AssignmentCheckInterval time.Duration
+
+	// ClientIDTemplate renders the consumer client.id, `{host}` is replaced by the
+	// hostname and `{id}` by a value of IDSource, defaults to "iot_{host}-{id}".
+	ClientIDTemplate string
+
+	// IDSource generates the `{id}` of ClientIDTemplate, defaults to a random UUID.
+	// Return a fixed value for a reproducible client.id.
+	IDSource func() string
+
+	// PollTimeout bounds each poll so cancellation of ctx is noticed in time, defaults to 500ms.
+	PollTimeout time.Duration
+
+	// IdleSleep is slept after a poll returned no message, reducing CPU on idle topics, optional.
+	IdleSleep time.Duration
+
+	// Reconnect recreates the consumer after it failed, reconnect is disabled by
+	// default. The new consumer joins the same consumer group and resumes from
+	// its committed offsets: the messages received after the last commit, auto
+	// committed every CommitInterval unless ManualCommit, are delivered again. A
+	// group without committed offset starts at OffsetReset again.
+	Reconnect ReconnectPolicy
+
+	// Status receives the connection state changes of the subscription, optional.
+	// Changes are dropped when the channel is full, it is never closed.
+	Status chan<- SubscriptionStatus
+
+	// ManualCommit disables auto commit, the delivered messages are CommittableMessage
+	// and their offsets are committed only by calling Commit. The commits are made
+	// by the goroutine polling the consumer, Commit waits for its next poll.
+	ManualCommit bool
+
+	// GroupID is the consumer group, the consumers of a group share the partitions
+	// of the topic. Defaults to the topic, so the subscriptions of a topic by
+	// different services must set their own to each receive all messages.
+	GroupID string
+
+	// ClientID is the consumer client.id, it replaces ClientIDTemplate.
+	ClientID string
+
+	// OffsetReset is where a group without committed offset starts, defaults to OffsetLatest.
+	OffsetReset OffsetResetPolicy
+
+	// CommitInterval is how often the offsets are auto committed, defaults to the
+	// server default of 5s.
+	CommitInterval time.Duration
+
+	// TLS connects with wss, also used when the address of the client is https.
+	TLS bool
+}
+
+// OffsetResetPolicy is where a consumer group starts reading without committed offset.
+type OffsetResetPolicy string
+
+const (
+	// OffsetLatest reads the messages written after the subscription.
+	OffsetLatest OffsetResetPolicy = "latest"
+	// OffsetEarliest reads the topic from its beginning.
+	OffsetEarliest OffsetResetPolicy = "earliest"
+	// OffsetNone fails the subscription of a group without committed offset.
+	OffsetNone OffsetResetPolicy = "none"
+)
+
+// SubscribeOption sets a field of a SubscribeConfig.
+type SubscribeOption func(conf *SubscribeConfig)
+
+// NewSubscribeConfig returns a config with the options applied.
+func NewSubscribeConfig(opts ...SubscribeOption) SubscribeConfig {
+	return SubscribeConfig{}.With(opts...)
+}
+
+// With returns the config with the options applied.
+func (conf SubscribeConfig) With(opts ...SubscribeOption) SubscribeConfig {
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return conf
+}
+
+// ConsumerGroup sets the consumer group, see SubscribeConfig.GroupID.
+func ConsumerGroup(id string) SubscribeOption {
+	return func(conf *SubscribeConfig) {
+		conf.GroupID = id
+	}
+}
+
+// ConsumerClientID sets a fixed client.id.
+func ConsumerClientID(id string) SubscribeOption {
+	return func(conf *SubscribeConfig) {
+		conf.ClientID = id
+	}
+}
+
+// ConsumerOffsetReset sets where a group without committed offset starts.
+func ConsumerOffsetReset(policy OffsetResetPolicy) SubscribeOption {
+	return func(conf *SubscribeConfig) {
+		conf.OffsetReset = policy
+	}
+}
+
+// ConsumerCommitInterval sets how often the offsets are auto committed.
+func ConsumerCommitInterval(d time.Duration) SubscribeOption {
+	return func(conf *SubscribeConfig) {
+		conf.CommitInterval = d
+	}
+}
+
+// ConsumerPollTimeout bounds each poll.
+func ConsumerPollTimeout(d time.Duration) SubscribeOption {
+	return func(conf *SubscribeConfig) {
+		conf.PollTimeout = d
+	}
+}
+
+// ConsumerTLS connects the consumer with wss.
+func ConsumerTLS() SubscribeOption {
+	return func(conf *SubscribeConfig) {
+		conf.TLS = true
+	}
+}
+
+func (conf SubscribeConfig) pollTimeoutMs() int {
+	if conf.PollTimeout <= 0 {
+		return taosPollTimeoutMs
+	}
+	if ms := int(conf.PollTimeout / time.Millisecond); ms > 0 {
+		return ms
+	}
+	return 1
+}
+
+const defaultClientIDTemplate = "iot_{host}-{id}"
+
+func (conf SubscribeConfig) clientID() string {
+	if len(conf.ClientID) > 0 {
+		return conf.ClientID
+	}
+	tmpl := conf.ClientIDTemplate
+	if len(tmpl) == 0 {
+		tmpl = defaultClientIDTemplate
+	}
+	idSource := conf.IDSource
+	if idSource == nil {
+		idSource = uuid.NewString
+	}
+
+	hn, _ := os.Hostname()
+	return strings.NewReplacer("{host}", hn, "{id}", idSource()).Replace(tmpl)
+}
+
 type taosConsumer interface {
 	Subscribe(topic string, rebalanceCb tmq.RebalanceCb) error
+	SubscribeTopics(topics []string, rebalanceCb tmq.RebalanceCb) error
 	Poll(timeoutMs int) tmqcommon.Event
+	Assignment() ([]tmqcommon.TopicPartition, error)
+	Commit() ([]tmqcommon.TopicPartition, error)
+	CommitOffsets(offsets []tmqcommon.TopicPartition) ([]tmqcommon.TopicPartition, error)
+	Position(partitions []tmqcommon.TopicPartition) ([]tmqcommon.TopicPartition, error)
+	Seek(partition tmqcommon.TopicPartition, ignoredTimeoutMs int) error
+	Committed(partitions []tmqcommon.TopicPartition, timeoutMs int) ([]tmqcommon.TopicPartition, error)
 	Unsubscribe() error
 	Close() error
 }
 
-func newConsumer(dbAddr, dbUser, dbPass, topic string) (consumer taosConsumer, err error) {
+// assignmentTracker reports the differences between successive consumer assignments.
+type assignmentTracker struct {
+	topic    string
+	conf     SubscribeConfig
+	current  map[partitionKey]tmqcommon.TopicPartition
+	nextTime time.Time
+}
 
-	hn, _ := os.Hostname()
+// partitionKey identifies a vgroup of a topic, vgroup ids repeat across topics.
+type partitionKey struct {
+	topic string
+	vg    int32
+}
 
-	consumer, err = tmq.NewConsumer(&tmqcommon.ConfigMap{
-		"ws.url":             fmt.Sprintf("%s/rest/tmq", strings.ReplaceAll(dbAddr, "http:", "ws:")),
+func newAssignmentTracker(topic string, conf SubscribeConfig) *assignmentTracker {
+	if conf.OnAssign == nil && conf.OnRevoke == nil {
+		return nil
+	}
+	if conf.AssignmentCheckInterval <= 0 {
+		conf.AssignmentCheckInterval = defaultAssignmentCheckInterval
+	}
+	return &assignmentTracker{
+		topic:   topic,
+		conf:    conf,
+		current: make(map[partitionKey]tmqcommon.TopicPartition),
+	}
+}
+
+func (t *assignmentTracker) topicOf(p tmqcommon.TopicPartition) string {
+	if p.Topic != nil {
+		return *p.Topic
+	}
+	return t.topic
+}
+
+// check compares the assignment with the previous one once the interval has passed,
+// it returns whether the assignment changed.
+func (t *assignmentTracker) check(consumer taosConsumer) (bool, error) {
+	now := time.Now()
+	if now.Before(t.nextTime) {
+		return false, nil
+	}
+	t.nextTime = now.Add(t.conf.AssignmentCheckInterval)
+
+	partitions, err := consumer.Assignment()
+	if err != nil {
+		return false, err
+	}
+
+	latest := make(map[partitionKey]tmqcommon.TopicPartition, len(partitions))
+	var assigned, revoked []tmqcommon.TopicPartition
+	for _, p := range partitions {
+		key := partitionKey{t.topicOf(p), p.Partition}
+		latest[key] = p
+		if _, ok := t.current[key]; !ok {
+			assigned = append(assigned, p)
+		}
+	}
+	for key, p := range t.current {
+		if _, ok := latest[key]; !ok {
+			revoked = append(revoked, p)
+		}
+	}
+	t.current = latest
+
+	if t.conf.OnRevoke != nil {
+		t.notify(t.conf.OnRevoke, revoked)
+	}
+	if t.conf.OnAssign != nil {
+		t.notify(t.conf.OnAssign, assigned)
+	}
+	return len(assigned) > 0 || len(revoked) > 0, nil
+}
+
+// notify calls fn once per topic of the partitions.
+func (t *assignmentTracker) notify(fn RebalanceFunc, partitions []tmqcommon.TopicPartition) {
+	byTopic := make(map[string][]tmqcommon.TopicPartition)
+	var topics []string
+	for _, p := range partitions {
+		topic := t.topicOf(p)
+		if _, ok := byTopic[topic]; !ok {
+			topics = append(topics, topic)
+		}
+		byTopic[topic] = append(byTopic[topic], p)
+	}
+	for _, topic := range topics {
+		fn(topic, byTopic[topic])
+	}
+}
+
+// revokeAll reports every remaining partition as revoked.
+func (t *assignmentTracker) revokeAll() {
+	if len(t.current) == 0 || t.conf.OnRevoke == nil {
+		return
+	}
+	revoked := make([]tmqcommon.TopicPartition, 0, len(t.current))
+	for _, p := range t.current {
+		revoked = append(revoked, p)
+	}
+	t.current = make(map[partitionKey]tmqcommon.TopicPartition)
+	t.notify(t.conf.OnRevoke, revoked)
+}
+
+// newConsumer creates a consumer of the consumer group named groupID.
+func newConsumer(dbAddr, dbUser, dbPass, groupID string, conf SubscribeConfig) (consumer taosConsumer, err error) {
+
+	autoCommit := "true"
+	if conf.ManualCommit {
+		autoCommit = "false"
+	}
+	if len(conf.GroupID) > 0 {
+		groupID = conf.GroupID
+	}
+	offsetReset := conf.OffsetReset
+	if len(offsetReset) == 0 {
+		offsetReset = OffsetLatest
+	}
+	wsAddr := toWebsocketAddr(dbAddr)
+	if conf.TLS {
+		wsAddr = strings.Replace(wsAddr, "ws:", "wss:", 1)
+	}
+
+	configMap := tmqcommon.ConfigMap{
+		"ws.url":             fmt.Sprintf("%s/rest/tmq", wsAddr),
 		"td.connect.user":    dbUser,
 		"td.connect.pass":    dbPass,
-		"group.id":           topic,
-		"client.id":          fmt.Sprintf("iot_%s-%d", hn, rand.Intn(86400)),
-		"auto.offset.reset":  "latest",
-		"enable.auto.commit": "true",
-		//"auto.commit.interval.ms": "5000",
-	})
+		"group.id":           groupID,
+		"client.id":          conf.clientID(),
+		"auto.offset.reset":  string(offsetReset),
+		"enable.auto.commit": autoCommit,
+	}
+	if conf.CommitInterval > 0 {
+		configMap["auto.commit.interval.ms"] = strconv.FormatInt(conf.CommitInterval.Milliseconds(), 10)
+	}
+	consumer, err = tmq.NewConsumer(&configMap)
 
 	return
 }
 
-func Subscribe(ctx context.Context, topic string, chMessage chan<- TSDBSubscribedMessage, chError chan<- error) error {
+// Subscription is a subscription running in the background, started by StartSubscription.
+type Subscription struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// StartSubscription subscribes to the topic with the default client in a new goroutine.
+// chMessage belongs to the subscription and is closed once it has ended, however it
+// ended, the caller must not close it nor share it with another subscription.
+// A panic of the subscription is recovered and reported by Err.
+func StartSubscription(ctx context.Context, topic string, conf SubscribeConfig, chMessage chan<- TSDBSubscribedMessage) *Subscription {
+	return startSubscription(ctx, topic, func(ctx context.Context) error {
+		if chMessage != nil {
+			defer close(chMessage)
+		}
+		return clientWrapper.SubscribeWithConfig(ctx, topic, conf, chMessage)
+	})
+}
+
+// startSubscription runs fn in a new goroutine.
+func startSubscription(ctx context.Context, name string, fn func(ctx context.Context) error) *Subscription {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Subscription{cancel: cancel, done: make(chan struct{})}
 	go func() {
-		chError <- clientWrapper.Subscribe(ctx, topic, chMessage)
+		defer close(s.done)
+		defer cancel()
+		s.err = runRecovered(ctx, name, fn)
 	}()
+	return s
+}
+
+// Done returns a channel closed when the subscription has ended.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that ended the subscription, nil while running or if it
+// was stopped by its context or Stop, the error of the context if it was stopped
+// while waiting to reconnect.
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Stop ends the subscription, waits until it has ended and returns Err.
+func (s *Subscription) Stop() error {
+	s.cancel()
+	<-s.done
+	return s.err
+}
+
+// SubscribeTopics subscribes to the topics with one consumer of the default client,
+// like Subscribe. The consumer group is named after the topics joined by "_".
+func SubscribeTopics(ctx context.Context, topics []string, chMessage chan<- TSDBSubscribedMessage, chError chan<- error) error {
+	startSubscription(ctx, strings.Join(topics, ","), func(ctx context.Context) error {
+		return clientWrapper.SubscribeTopics(ctx, topics, chMessage)
+	}).notify(chError)
+	return nil
+}
+
+// notify sends the result of the subscription to chError once it has ended.
+func (s *Subscription) notify(chError chan<- error) {
+	go func() {
+		<-s.done
+		chError <- s.err
+	}()
+}
+
+// Subscribe subscribes to the topic with the default client in a new goroutine.
+// Exactly one value, the result of Subscription.Err, is sent to chError when
+// the subscription has ended. chMessage and chError are owned by the caller and
+// never closed. See StartSubscription for a handle instead.
+func Subscribe(ctx context.Context, topic string, chMessage chan<- TSDBSubscribedMessage, chError chan<- error) error {
+	return SubscribeWithConfig(ctx, topic, SubscribeConfig{}, chMessage, chError)
+}
+
+// SubscribeWithConfig is Subscribe with a configuration.
+func SubscribeWithConfig(ctx context.Context, topic string, conf SubscribeConfig, chMessage chan<- TSDBSubscribedMessage, chError chan<- error) error {
+	startSubscription(ctx, topic, func(ctx context.Context) error {
+		return clientWrapper.SubscribeWithConfig(ctx, topic, conf, chMessage)
+	}).notify(chError)
 	return nil
 }
 
+// SubscribeWithFilter is Subscribe delivering only the messages accepted by filter.
+func SubscribeWithFilter(ctx context.Context, topic string, filter SubscribeFilter, chMessage chan<- TSDBSubscribedMessage, chError chan<- error) error {
+	return SubscribeWithConfig(ctx, topic, SubscribeConfig{Filter: filter}, chMessage, chError)
+}
+
 func CreateTopic(topic, content string, mode TopicMode) error {
+	return CreateTopicContext(context.Background(), topic, content, mode)
+}
+
+// CreateTopicContext is CreateTopic with a context controlling cancellation and deadline.
+func CreateTopicContext(ctx context.Context, topic, content string, mode TopicMode) error {
+
+	sql, err := createTopicSQL(topic, content, mode)
+	if err != nil {
+		return err
+	}
+
+	return execContext(ctx, sql)
+}
+
+// execContext runs a statement without result rows with the default client. It
+// reports every server error, ReadDataContext reads a missing table or database
+// as no rows.
+func execContext(ctx context.Context, sql string) error {
+	client := clientWrapper.GetHttpClient()
+	if client == nil {
+		return errors.New("default http client is nil")
+	}
+	dbOpt := newDBOptions()
+	resp, err := client.QueryContext(ctx, withContextOptions(ctx, NewQuery(sql, dbOpt.DatabaseName, dbOpt.PrecisionUnit)))
+	if err != nil {
+		return err
+	}
+	return resp.Error()
+}
+
+func createTopicSQL(topic, content string, mode TopicMode) (string, error) {
 
 	if len(topic) == 0 || len(content) == 0 {
-		return errors.New("miss args: `topic` or `content`")
+		return "", errors.New("miss args: `topic` or `content`")
 	}
 
 	var sql string
@@ -76,21 +497,31 @@ func CreateTopic(topic, content string, mode TopicMode) error {
 	case SQLMode: // sql
 		sql = fmt.Sprintf("create topic if not exists %s as %s", topic, content)
 	default:
-		return fmt.Errorf("not support mode: %d", mode)
+		return "", fmt.Errorf("not support mode: %d", mode)
 	}
 
-	_, err := ReadData(sql)
+	return sql, nil
+}
 
-	return err
+// SubscribeEnsureTopic creates the topic if missing, then subscribes like Subscribe.
+// An error creating the topic is returned directly instead of through chError.
+func SubscribeEnsureTopic(ctx context.Context, spec TopicSpec, chMessage chan<- TSDBSubscribedMessage, chError chan<- error) error {
+	if err := CreateTopicSpec(ctx, spec); err != nil {
+		return err
+	}
+	return Subscribe(ctx, spec.Name, chMessage, chError)
 }
 
 func DropTopic(topic string) error {
+	return DropTopicContext(context.Background(), topic)
+}
+
+// DropTopicContext is DropTopic with a context controlling cancellation and deadline.
+func DropTopicContext(ctx context.Context, topic string) error {
 
 	if len(topic) == 0 {
 		return fmt.Errorf("invalid args: `topic` is empty")
 	}
 
-	_, err := ReadData(fmt.Sprintf("drop topic if exists %s", topic))
-
-	return err
+	return execContext(ctx, fmt.Sprintf("drop topic if exists %s", topic))
 }
@@ -0,0 +1,106 @@
+package tsdbclient
+
+import (
+	"errors"
+
+	tmqcommon "github.com/taosdata/driver-go/v3/common/tmq"
+)
+
+// CommittableMessage is a message of a subscription with ManualCommit.
+type CommittableMessage interface {
+	TSDBSubscribedMessage
+
+	// Commit commits the offset of the vgroup of the message up to and including it,
+	// so it is not redelivered to the consumer group. Call it once the message was
+	// processed successfully for at-least-once delivery.
+	Commit() error
+}
+
+// errSubscriptionEnded is returned by Commit once the subscription of the message has ended.
+var errSubscriptionEnded = errors.New("subscription ended, offset not committed")
+
+type committableMessage struct {
+	TSDBSubscribedMessage
+	commit   func(tmqcommon.TopicPartition) error
+	position tmqcommon.TopicPartition
+}
+
+// newCommittableMessage captures the consumer position of the vgroup of the message,
+// it must be called before the next poll. commit commits the position.
+func newCommittableMessage(consumer taosConsumer, msg TSDBSubscribedMessage, commit func(tmqcommon.TopicPartition) error) (*committableMessage, error) {
+	topic := msg.Topic()
+	positions, err := consumer.Position([]tmqcommon.TopicPartition{{Topic: &topic, Partition: messageVGroup(msg)}})
+	if err != nil {
+		return nil, err
+	}
+	m := &committableMessage{TSDBSubscribedMessage: msg, commit: commit}
+	if len(positions) > 0 {
+		m.position = positions[0]
+	}
+	return m, nil
+}
+
+func (m *committableMessage) Commit() error {
+	if m.position.Topic == nil {
+		return nil
+	}
+	return m.commit(m.position)
+}
+
+// commitOffset commits the position with the consumer on the calling goroutine.
+func commitOffset(consumer taosConsumer) func(tmqcommon.TopicPartition) error {
+	return func(position tmqcommon.TopicPartition) error {
+		_, err := consumer.CommitOffsets([]tmqcommon.TopicPartition{position})
+		return err
+	}
+}
+
+// offsetCommitter passes the commits of the messages to the goroutine polling the
+// consumer, the tmq consumer is not safe for concurrent use.
+type offsetCommitter struct {
+	requests chan commitRequest
+	done     chan struct{}
+}
+
+type commitRequest struct {
+	position tmqcommon.TopicPartition
+	result   chan error
+}
+
+func newOffsetCommitter() *offsetCommitter {
+	return &offsetCommitter{requests: make(chan commitRequest), done: make(chan struct{})}
+}
+
+// commit waits until the polling goroutine committed the position, at most until
+// the next poll returned.
+func (c *offsetCommitter) commit(position tmqcommon.TopicPartition) error {
+	req := commitRequest{position: position, result: make(chan error, 1)}
+	select {
+	case c.requests <- req:
+		return <-req.result
+	case <-c.done:
+		return errSubscriptionEnded
+	}
+}
+
+// serve commits the pending requests, it is called by the polling goroutine.
+func (c *offsetCommitter) serve(consumer taosConsumer) {
+	for {
+		select {
+		case req := <-c.requests:
+			c.handle(consumer, req)
+		default:
+			return
+		}
+	}
+}
+
+func (c *offsetCommitter) handle(consumer taosConsumer, req commitRequest) {
+	_, err := consumer.CommitOffsets([]tmqcommon.TopicPartition{req.position})
+	req.result <- err
+}
+
+// close fails the commits requested from now on.
+func (c *offsetCommitter) close() {
+	close(c.done)
+}
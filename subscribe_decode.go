@@ -0,0 +1,134 @@
+package tsdbclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+
+	tmqcommon "github.com/taosdata/driver-go/v3/common/tmq"
+)
+
+// DecodeInto appends the rows of a subscribed message to dest, a pointer to a
+// slice of structs or of pointers to structs mapped by their tsdb tags as for
+// WriteStruct. The messages carry no column names: the ts, tag and field
+// fields of the struct receive the values of a row in the order they are
+// declared, which must be the order of the columns selected by the topic. A
+// field tagged "table" receives the name of the child table.
+//
+//	type Meter struct {
+//		Table    string    `tsdb:"table"`
+//		Time     time.Time `tsdb:"ts"`
+//		Current  float64   `tsdb:"field"`
+//		Location string    `tsdb:"tag"`
+//	}
+//	var rows []Meter
+//	err := DecodeInto(msg, &rows)
+func DecodeInto(msg TSDBSubscribedMessage, dest interface{}) error {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() || dv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("invalid args: destination not a pointer to a slice: %T", dest)
+	}
+	slice := dv.Elem()
+	elem := slice.Type().Elem()
+	isPtr := elem.Kind() == reflect.Pointer
+	if isPtr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return fmt.Errorf("invalid args: %s is not a struct", elem)
+	}
+	l, err := layoutOf(elem)
+	if err != nil {
+		return err
+	}
+
+	blocks, ok := msg.Value().([]*tmqcommon.Data)
+	if !ok {
+		return nil
+	}
+	for _, b := range blocks {
+		if b == nil {
+			continue
+		}
+		for _, row := range b.Data {
+			v := reflect.New(elem)
+			if err := decodeRow(v.Elem(), l, b.TableName, row); err != nil {
+				return fmt.Errorf("table %s: %w", b.TableName, err)
+			}
+			if !isPtr {
+				v = v.Elem()
+			}
+			slice = reflect.Append(slice, v)
+		}
+	}
+	dv.Elem().Set(slice)
+	return nil
+}
+
+func decodeRow(sv reflect.Value, l *structLayout, table string, row []driver.Value) error {
+	if len(row) != len(l.columns) {
+		return fmt.Errorf("row has %d values, struct %s has %d columns", len(row), sv.Type(), len(l.columns))
+	}
+	if l.table != nil {
+		sv.FieldByIndex(l.table).SetString(table)
+	}
+	for i, c := range l.columns {
+		if err := setValue(sv.FieldByIndex(c.index), messageValue(row[i])); err != nil {
+			return fmt.Errorf("column %s: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+// messageValue converts a value of a message to the types set by setValue.
+func messageValue(v driver.Value) interface{} {
+	switch v := v.(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case float32:
+		return float64(v)
+	case []byte:
+		return string(v)
+	}
+	return v
+}
+
+// SubscribeAs subscribes the topic through a consumer pool and calls handle
+// with the rows of each message decoded into T as by DecodeInto. The offset of
+// a message is committed once handle returned nil.
+func SubscribeAs[T any](ctx context.Context, c TSDBClient, topic string, conf ConsumerPoolConfig, handle func(ctx context.Context, rows []T) error) error {
+	if c == nil {
+		return errors.New("invalid args: client is nil")
+	}
+	if handle == nil {
+		return errors.New("invalid args: handle is nil")
+	}
+	var zero T
+	t := reflect.TypeOf(zero)
+	if t == nil || (t.Kind() != reflect.Struct && (t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct)) {
+		return fmt.Errorf("invalid args: %T is not a struct", zero)
+	}
+
+	return c.SubscribePool(ctx, topic, conf, func(ctx context.Context, msg TSDBSubscribedMessage) error {
+		var rows []T
+		if err := DecodeInto(msg, &rows); err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return handle(ctx, rows)
+	})
+}
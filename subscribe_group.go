@@ -0,0 +1,115 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// SubscriptionGroup runs several subscriptions under one context. The errors of
+// all members are aggregated, panics are recovered as errors, and Stop shuts the
+// members down one by one in reverse order of start.
+type SubscriptionGroup struct {
+	// FailFast stops the whole group when a member fails.
+	FailFast bool
+
+	client TSDBClient
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock    sync.Mutex
+	members []*groupMember
+	errs    []error
+	wg      sync.WaitGroup
+}
+
+type groupMember struct {
+	name   string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSubscriptionGroup returns a group subscribing through client, the default
+// client if nil. The members stop when ctx is done.
+func NewSubscriptionGroup(ctx context.Context, client TSDBClient) *SubscriptionGroup {
+	if client == nil {
+		client = clientWrapper
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &SubscriptionGroup{client: client, ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn as a member of the group, name identifies it in errors.
+func (g *SubscriptionGroup) Go(name string, fn func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(g.ctx)
+	m := &groupMember{name: name, cancel: cancel, done: make(chan struct{})}
+
+	g.lock.Lock()
+	g.members = append(g.members, m)
+	g.lock.Unlock()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer close(m.done)
+		defer cancel()
+
+		if err := runRecovered(ctx, name, fn); err != nil {
+			g.lock.Lock()
+			g.errs = append(g.errs, fmt.Errorf("%s: %w", name, err))
+			g.lock.Unlock()
+			if g.FailFast {
+				go g.Stop()
+			}
+		}
+	}()
+}
+
+func runRecovered(ctx context.Context, name string, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			defaultLogger().Error("subscription panicked", "name", name, "panic", r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// Subscribe adds a subscription to the topic delivering to chMessage.
+func (g *SubscriptionGroup) Subscribe(topic string, conf SubscribeConfig, chMessage chan<- TSDBSubscribedMessage) {
+	g.Go(topic, func(ctx context.Context) error {
+		return g.client.SubscribeWithConfig(ctx, topic, conf, chMessage)
+	})
+}
+
+// SubscribePool adds a pooled subscription to the topic handled by handler.
+func (g *SubscriptionGroup) SubscribePool(topic string, conf ConsumerPoolConfig, handler MessageHandler) {
+	g.Go(topic, func(ctx context.Context) error {
+		return g.client.SubscribePool(ctx, topic, conf, handler)
+	})
+}
+
+// Stop stops the members in reverse order of start, waiting for each before
+// stopping the next, and returns the aggregated errors.
+func (g *SubscriptionGroup) Stop() error {
+	g.lock.Lock()
+	members := append([]*groupMember(nil), g.members...)
+	g.lock.Unlock()
+
+	for i := len(members) - 1; i >= 0; i-- {
+		members[i].cancel()
+		<-members[i].done
+	}
+	g.cancel()
+	return g.Wait()
+}
+
+// Wait waits until all members returned and returns their aggregated errors.
+func (g *SubscriptionGroup) Wait() error {
+	g.wg.Wait()
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return errors.Join(g.errs...)
+}
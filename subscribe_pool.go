@@ -0,0 +1,188 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+
+	tmqcommon "github.com/taosdata/driver-go/v3/common/tmq"
+)
+
+// MessageHandler processes one subscribed message.
+type MessageHandler func(ctx context.Context, msg TSDBSubscribedMessage) error
+
+const defaultPoolBatchSize = 100
+
+// ConsumerPoolConfig configures SubscribePool.
+type ConsumerPoolConfig struct {
+	SubscribeConfig
+
+	// Workers is the number of processing goroutines, defaults to runtime.NumCPU().
+	Workers int
+
+	// BatchSize is the number of messages dispatched before waiting for the workers
+	// and committing the offsets, defaults to 100. A partial batch is committed
+	// when a poll returns no message.
+	BatchSize int
+}
+
+// consumerPool dispatches messages of one consumer to its workers, messages of
+// the same vgroup always go to the same worker so their order is preserved.
+type consumerPool struct {
+	handler MessageHandler
+	queues  []chan TSDBSubscribedMessage
+
+	pending sync.WaitGroup
+	count   int
+
+	errLock sync.Mutex
+	err     error
+}
+
+func newConsumerPool(ctx context.Context, workers, batchSize int, handler MessageHandler, done *sync.WaitGroup) *consumerPool {
+	p := &consumerPool{
+		handler: handler,
+		queues:  make([]chan TSDBSubscribedMessage, workers),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan TSDBSubscribedMessage, batchSize)
+		done.Add(1)
+		go func(queue <-chan TSDBSubscribedMessage) {
+			defer done.Done()
+			for msg := range queue {
+				if p.firstErr() == nil {
+					if err := handler(ctx, msg); err != nil {
+						p.setErr(err)
+					}
+				}
+				p.pending.Done()
+			}
+		}(p.queues[i])
+	}
+	return p
+}
+
+func (p *consumerPool) dispatch(msg TSDBSubscribedMessage) {
+	worker := int(messageVGroup(msg)) % len(p.queues)
+	if worker < 0 {
+		worker = -worker
+	}
+	p.pending.Add(1)
+	p.count++
+	p.queues[worker] <- msg
+}
+
+// wait blocks until every dispatched message has been handled.
+func (p *consumerPool) wait() error {
+	p.pending.Wait()
+	p.count = 0
+	return p.firstErr()
+}
+
+func (p *consumerPool) stop() {
+	for _, q := range p.queues {
+		close(q)
+	}
+}
+
+func (p *consumerPool) setErr(err error) {
+	p.errLock.Lock()
+	defer p.errLock.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *consumerPool) firstErr() error {
+	p.errLock.Lock()
+	defer p.errLock.Unlock()
+	return p.err
+}
+
+func messageVGroup(msg TSDBSubscribedMessage) int32 {
+	switch m := msg.(type) {
+	case *tmqcommon.DataMessage:
+		return m.TopicPartition.Partition
+	case *tmqcommon.MetaMessage:
+		return m.TopicPartition.Partition
+	case *tmqcommon.MetaDataMessage:
+		return m.TopicPartition.Partition
+	}
+	return 0
+}
+
+// SubscribePool subscribes the topic with one consumer and processes the messages with
+// a pool of workers. Offsets are committed once all workers finished a batch, so a
+// message is redelivered if its handler fails; the first handler error ends the
+// subscription and is returned.
+func (client *tsdbClient) SubscribePool(ctx context.Context, topic string, conf ConsumerPoolConfig, handler MessageHandler) error {
+
+	if len(topic) == 0 {
+		return errors.New("invalid args: topic is empty")
+	}
+
+	if handler == nil {
+		return errors.New("invalid args: handler is nil")
+	}
+
+	if conf.Workers <= 0 {
+		conf.Workers = runtime.NumCPU()
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = defaultPoolBatchSize
+	}
+	conf.ManualCommit = true
+
+	creds := client.creds.load()
+	tsdbCons, err := newConsumer(client.dbConfig.DBAddr, creds.user, creds.password, topic, conf.SubscribeConfig)
+	if err != nil {
+		return err
+	}
+	defer tsdbCons.Close()
+
+	if err = tsdbCons.Subscribe(topic, nil); err != nil {
+		return err
+	}
+	defer tsdbCons.Unsubscribe()
+
+	var workers sync.WaitGroup
+	pool := newConsumerPool(ctx, conf.Workers, conf.BatchSize, handler, &workers)
+	defer func() {
+		pool.stop()
+		workers.Wait()
+	}()
+
+	commit := func() error {
+		if pool.count == 0 {
+			return nil
+		}
+		if err := pool.wait(); err != nil {
+			return err
+		}
+		_, err := tsdbCons.Commit()
+		return err
+	}
+
+	err = client.consume(ctx, tsdbCons, topic, conf.SubscribeConfig, func(msg TSDBSubscribedMessage) error {
+		pool.dispatch(msg)
+		if pool.count >= conf.BatchSize {
+			return commit()
+		}
+		return nil
+	}, commit)
+	if err != nil {
+		return err
+	}
+
+	if err = commit(); err != nil {
+		client.log().Error("subscribe pool commit error", "topic", topic, "error", err)
+		return err
+	}
+	return nil
+}
+
+// SubscribePool processes the messages of the topic with a pool of workers of the default client.
+func SubscribePool(ctx context.Context, topic string, conf ConsumerPoolConfig, handler MessageHandler) error {
+	return clientWrapper.SubscribePool(ctx, topic, conf, handler)
+}
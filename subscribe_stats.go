@@ -0,0 +1,174 @@
+package tsdbclient
+
+import (
+	"database/sql/driver"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tmqcommon "github.com/taosdata/driver-go/v3/common/tmq"
+)
+
+// SubscriptionStats are the counters of the subscriptions to one topic.
+type SubscriptionStats struct {
+	Topic      string
+	Polls      uint64
+	Messages   uint64
+	Bytes      uint64
+	Filtered   uint64
+	Dropped    uint64
+	PollErrors uint64
+	Rebalances uint64
+	Reconnects uint64
+
+	// LastMessage is when the last message was received, zero if none.
+	LastMessage time.Time
+
+	// Lag is how far behind the subscription is: the time from the newest row of
+	// the last message with rows to its receipt, zero before one.
+	Lag time.Duration
+}
+
+type subscriptionCounters struct {
+	polls      atomic.Uint64
+	messages   atomic.Uint64
+	bytes      atomic.Uint64
+	filtered   atomic.Uint64
+	dropped    atomic.Uint64
+	pollErrors atomic.Uint64
+	rebalances atomic.Uint64
+	reconnects atomic.Uint64
+
+	lastMessage atomic.Int64
+	lag         atomic.Int64
+}
+
+type subscriptionRegistry struct {
+	lock     sync.RWMutex
+	counters map[string]*subscriptionCounters
+}
+
+func (r *subscriptionRegistry) get(topic string) *subscriptionCounters {
+	r.lock.RLock()
+	c, ok := r.counters[topic]
+	r.lock.RUnlock()
+	if ok {
+		return c
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.counters == nil {
+		r.counters = make(map[string]*subscriptionCounters)
+	}
+	if c, ok = r.counters[topic]; !ok {
+		c = &subscriptionCounters{}
+		r.counters[topic] = c
+	}
+	return c
+}
+
+func (r *subscriptionRegistry) snapshot() []SubscriptionStats {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	stats := make([]SubscriptionStats, 0, len(r.counters))
+	for topic, c := range r.counters {
+		s := SubscriptionStats{
+			Topic:      topic,
+			Polls:      c.polls.Load(),
+			Messages:   c.messages.Load(),
+			Bytes:      c.bytes.Load(),
+			Filtered:   c.filtered.Load(),
+			Dropped:    c.dropped.Load(),
+			PollErrors: c.pollErrors.Load(),
+			Rebalances: c.rebalances.Load(),
+			Reconnects: c.reconnects.Load(),
+		}
+		if ns := c.lastMessage.Load(); ns > 0 {
+			s.LastMessage = time.Unix(0, ns)
+		}
+		s.Lag = time.Duration(c.lag.Load())
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Topic < stats[j].Topic })
+	return stats
+}
+
+// record counts a message received at now.
+func (c *subscriptionCounters) record(msg TSDBSubscribedMessage, now time.Time) {
+	c.messages.Add(1)
+	c.lastMessage.Store(now.UnixNano())
+	c.bytes.Add(messageSize(msg))
+	if newest := newestRow(msg); !newest.IsZero() {
+		c.lag.Store(int64(max(now.Sub(newest), 0)))
+	}
+}
+
+// newestRow returns the latest timestamp of the first column of the rows of a
+// message, zero if none.
+func newestRow(msg TSDBSubscribedMessage) time.Time {
+	blocks, _ := msg.Value().([]*tmqcommon.Data)
+	var newest time.Time
+	for _, b := range blocks {
+		if b == nil {
+			continue
+		}
+		for _, row := range b.Data {
+			if len(row) == 0 {
+				continue
+			}
+			if ts, ok := row[0].(time.Time); ok && ts.After(newest) {
+				newest = ts
+			}
+		}
+	}
+	return newest
+}
+
+// messageSize estimates the payload size of a message from its decoded values.
+func messageSize(msg TSDBSubscribedMessage) uint64 {
+	blocks, ok := msg.Value().([]*tmqcommon.Data)
+	if !ok {
+		return 0
+	}
+
+	var n uint64
+	for _, b := range blocks {
+		if b == nil {
+			continue
+		}
+		n += uint64(len(b.TableName))
+		for _, row := range b.Data {
+			for _, v := range row {
+				n += valueSize(v)
+			}
+		}
+	}
+	return n
+}
+
+func valueSize(v driver.Value) uint64 {
+	switch x := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return uint64(len(x))
+	case []byte:
+		return uint64(len(x))
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// GetSubscriptionStats returns the counters of every topic subscribed through the default client.
+func GetSubscriptionStats() []SubscriptionStats {
+	return clientWrapper.SubscriptionStats()
+}
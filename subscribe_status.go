@@ -0,0 +1,94 @@
+package tsdbclient
+
+import (
+	"time"
+)
+
+// SubscriptionState is the connection state of a subscription.
+type SubscriptionState int8
+
+const (
+	_ SubscriptionState = iota
+	// StateConnected is reported when the consumer subscribed, initially and after reconnects.
+	StateConnected
+	// StateDisconnected is reported when the consumer failed and will be reconnected.
+	StateDisconnected
+	// StateReconnecting is reported when a new consumer is created after a failure.
+	StateReconnecting
+	// StateGaveUp is reported when the consumer failed and is not reconnected.
+	StateGaveUp
+)
+
+func (s SubscriptionState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateGaveUp:
+		return "gave up"
+	}
+	return "unknown"
+}
+
+// SubscriptionStatus is a connection state change of a subscription.
+type SubscriptionStatus struct {
+	Topic string
+	State SubscriptionState
+
+	// Attempt is the number of the connection attempt since the last successful one.
+	Attempt int
+
+	// Err is the failure of the consumer, if any.
+	Err error
+
+	Time time.Time
+}
+
+// ReconnectPolicy is the backoff of a subscription recreating its consumer after a failure.
+type ReconnectPolicy struct {
+	// MaxAttempts is the number of consecutive failed connections before giving up,
+	// values below 2 disable reconnects.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first reconnect, defaults to 100ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between reconnects, defaults to 5s.
+	MaxBackoff time.Duration
+
+	// Multiplier grows the wait after each failed reconnect, defaults to 2.
+	Multiplier float64
+
+	// Jitter randomizes each wait by up to this fraction of it, in [0, 1], defaults to 0.2.
+	Jitter float64
+}
+
+// backoff returns the policy as a RetryPolicy, the backoff of both is the same.
+func (p ReconnectPolicy) backoff() RetryPolicy {
+	jitter := p.Jitter
+	if jitter == 0 {
+		// spread the reconnects of consumers failed together, e.g. by a restart of taosAdapter
+		jitter = defaultReconnectJitter
+	}
+	return RetryPolicy{
+		MaxAttempts:    p.MaxAttempts,
+		InitialBackoff: p.InitialBackoff,
+		MaxBackoff:     p.MaxBackoff,
+		Multiplier:     p.Multiplier,
+		Jitter:         jitter,
+	}
+}
+
+func (conf SubscribeConfig) notifyStatus(status SubscriptionStatus) {
+	if conf.Status == nil {
+		return
+	}
+	status.Time = time.Now()
+	select {
+	case conf.Status <- status:
+	default:
+	}
+}
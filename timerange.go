@@ -0,0 +1,119 @@
+package tsdbclient
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// TimeRange is the half-open range of timestamps [Start, End), so consecutive
+// ranges neither overlap nor leave gaps.
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// LastN returns the range of the last d up to now.
+func LastN(d time.Duration) TimeRange {
+	now := time.Now()
+	return TimeRange{Start: now.Add(-d), End: now}
+}
+
+// Today returns the range of the current day in loc, the local time zone if nil.
+func Today(loc *time.Location) TimeRange {
+	if loc == nil {
+		loc = time.Local
+	}
+	y, m, d := time.Now().In(loc).Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	return TimeRange{Start: start, End: start.AddDate(0, 0, 1)}
+}
+
+// ThisMonth returns the range of the current month in loc, the local time zone if nil.
+func ThisMonth(loc *time.Location) TimeRange {
+	if loc == nil {
+		loc = time.Local
+	}
+	y, m, _ := time.Now().In(loc).Date()
+	start := time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	return TimeRange{Start: start, End: start.AddDate(0, 1, 0)}
+}
+
+// Between returns the range from a included to b excluded.
+func Between(a, b time.Time) TimeRange {
+	return TimeRange{Start: a, End: b}
+}
+
+// Contains reports whether t is in the range.
+func (r TimeRange) Contains(t time.Time) bool {
+	return !t.Before(r.Start) && t.Before(r.End)
+}
+
+// Duration returns the length of the range.
+func (r TimeRange) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Where renders the condition of the range on the timestamp column, with the
+// bounds as integer timestamps of the precision ("ms" if empty) so they don't
+// depend on the time zone of the server.
+//
+//	r.Where("ts", "ms") // `ts` >= 1700000000000 and `ts` < 1700086400000
+func (r TimeRange) Where(column, precision string) (string, error) {
+	if !r.Start.Before(r.End) {
+		return "", errors.New("invalid args: time range is empty")
+	}
+	name, err := QuoteIdent(column)
+	if err != nil {
+		return "", err
+	}
+	start, err := timestampLiteral(r.Start, precision)
+	if err != nil {
+		return "", err
+	}
+	end, err := timestampLiteral(r.End, precision)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s >= %s and %s < %s", name, start, name, end), nil
+}
+
+// timestampLiteral returns t as an integer timestamp of the precision, a fraction
+// of the unit rounds up so the bounds of a half-open range select the same rows.
+func timestampLiteral(t time.Time, precision string) (string, error) {
+	unit, err := precisionUnit(precision)
+	if err != nil {
+		return "", err
+	}
+	ns := t.UnixNano()
+	n := ns / int64(unit)
+	if ns%int64(unit) > 0 {
+		n++
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+// precisionUnit returns the duration of a unit of the database precision.
+func precisionUnit(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "ms":
+		return time.Millisecond, nil
+	case "us", "u":
+		return time.Microsecond, nil
+	case "ns", "n":
+		return time.Nanosecond, nil
+	}
+	return 0, fmt.Errorf("unsupported precision: %s", precision)
+}
+
+// Range adds the condition of the range on the timestamp column, in the precision
+// set by Precision.
+func (b *SelectBuilder) Range(column string, r TimeRange) *SelectBuilder {
+	cond, err := r.Where(column, b.precision)
+	if err != nil {
+		return b.setErr(err)
+	}
+	b.where = append(b.where, cond)
+	return b
+}
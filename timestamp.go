@@ -0,0 +1,162 @@
+package tsdbclient
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimestampPolicy decides how points written without a timestamp are handled.
+type TimestampPolicy int8
+
+const (
+	_ TimestampPolicy = iota
+	// TimestampServerAssign sends the points without timestamp, so the server assigns
+	// its time of arrival. It is the behavior when no policy is set. The stmt backend
+	// has no server-side default and writes such points with time zero.
+	TimestampServerAssign
+	// TimestampClientNow stamps the points with the client time when written.
+	TimestampClientNow
+	// TimestampRejectMissing fails the write of batches containing points without timestamp.
+	TimestampRejectMissing
+)
+
+// ErrMissingTimestamp is returned by writes under TimestampRejectMissing of points without timestamp.
+var ErrMissingTimestamp = errors.New("point has no timestamp")
+
+// applyTimestampPolicy stamps with now or rejects the points of the batch without timestamp.
+func applyTimestampPolicy(policy TimestampPolicy, bps BatchPoints, now time.Time) error {
+	if policy != TimestampClientNow && policy != TimestampRejectMissing {
+		return nil
+	}
+
+	for _, p := range bps.Points() {
+		if p == nil || !p.Time().IsZero() {
+			continue
+		}
+		if policy == TimestampRejectMissing {
+			return fmt.Errorf("%w: point %s", ErrMissingTimestamp, p.Name())
+		}
+		p.pt.SetTime(now)
+	}
+	return nil
+}
+
+// ErrTimestampOutOfRange is matched by errors.Is for every TimestampOutOfRangeError.
+var ErrTimestampOutOfRange = errors.New("timestamp out of range")
+
+// TimestampOutOfRangeError reports a point whose timestamp is outside the write bounds.
+type TimestampOutOfRangeError struct {
+	Measurement string
+	Time        time.Time
+	Min         time.Time
+	Max         time.Time
+}
+
+func (e *TimestampOutOfRangeError) Error() string {
+	return fmt.Sprintf("timestamp out of range: point %s at %s, allowed [%s, %s]", e.Measurement,
+		e.Time.Format(time.RFC3339Nano), e.Min.Format(time.RFC3339Nano), e.Max.Format(time.RFC3339Nano))
+}
+
+func (e *TimestampOutOfRangeError) Is(target error) bool {
+	return target == ErrTimestampOutOfRange
+}
+
+// TimestampBounds rejects points too far from the client time, zero values are unbounded.
+type TimestampBounds struct {
+	// MaxFuture is how far ahead of now a timestamp may be.
+	MaxFuture time.Duration
+
+	// MaxPast is how far behind now a timestamp may be.
+	MaxPast time.Duration
+}
+
+func (b TimestampBounds) enabled() bool {
+	return b.MaxFuture > 0 || b.MaxPast > 0
+}
+
+// check fails on the first point of the batch outside the bounds around now,
+// points without timestamp are left to the TimestampPolicy.
+func (b TimestampBounds) check(bps BatchPoints, now time.Time) error {
+	if !b.enabled() {
+		return nil
+	}
+
+	var min, max time.Time
+	if b.MaxPast > 0 {
+		min = now.Add(-b.MaxPast)
+	}
+	if b.MaxFuture > 0 {
+		max = now.Add(b.MaxFuture)
+	}
+	for _, p := range bps.Points() {
+		if p == nil {
+			continue
+		}
+		t := p.Time()
+		if t.IsZero() {
+			continue
+		}
+		if !min.IsZero() && t.Before(min) || !max.IsZero() && t.After(max) {
+			return &TimestampOutOfRangeError{Measurement: p.Name(), Time: t, Min: min, Max: max}
+		}
+	}
+	return nil
+}
+
+// TimestampFormat is the Go value of the TIMESTAMP columns decoded by QueryData
+// with convertNumber.
+type TimestampFormat int8
+
+const (
+	_ TimestampFormat = iota
+	// TimestampUnix decodes to int64 Unix seconds, the default.
+	TimestampUnix
+	// TimestampUnixMilli decodes to int64 Unix milliseconds.
+	TimestampUnixMilli
+	// TimestampTime decodes to time.Time in the location.
+	TimestampTime
+	// TimestampRFC3339 decodes to a RFC3339 string with nanoseconds in the location.
+	TimestampRFC3339
+)
+
+// DecodeTimestamps sets how QueryData decodes the TIMESTAMP columns of the query,
+// timestamps without zone are read in loc which defaults to UTC.
+func DecodeTimestamps(format TimestampFormat, loc *time.Location) QueryOption {
+	return func(q *Query) {
+		q.TimestampFormat = format
+		q.Location = loc
+	}
+}
+
+// decodeTimestamp parses a TIMESTAMP value of a response and returns it in the format.
+func decodeTimestamp(v interface{}, format TimestampFormat, loc *time.Location) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("not a timestamp: %v of type %T", v, v)
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		var e error
+		if t, e = time.ParseInLocation("2006-01-02 15:04:05.999999999", s, loc); e != nil {
+			return nil, fmt.Errorf("not a timestamp: %w", err)
+		}
+	}
+
+	switch format {
+	case TimestampUnixMilli:
+		return t.UnixMilli(), nil
+	case TimestampTime:
+		return t.In(loc), nil
+	case TimestampRFC3339:
+		return t.In(loc).Format(time.RFC3339Nano), nil
+	default:
+		return t.Unix(), nil
+	}
+}
@@ -0,0 +1,151 @@
+package tsdbclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+)
+
+const (
+	LoginURL = "rest/login"
+
+	defaultTokenRefresh = 30 * time.Minute
+)
+
+// tokenSource logs in once and caches the token of taosAdapter, so reconnects
+// and concurrent requests don't each pay the login. The token is refreshed in
+// the background before it is due, and logins are serialized so a restart of
+// taosAdapter is not hit by a login per request. The websocket endpoints of tmq
+// and stmt authenticate the connection with the credentials only.
+type tokenSource struct {
+	httpClient *http.Client
+	url        url.URL
+	creds      *credentials
+	refresh    time.Duration
+	logger     Logger
+
+	lock       sync.Mutex
+	token      string
+	obtained   time.Time
+	obtainedBy *userPassword
+	refreshing bool
+}
+
+func newTokenSource(httpClient *http.Client, u url.URL, creds *credentials, refresh time.Duration, logger Logger) *tokenSource {
+	if refresh <= 0 {
+		refresh = defaultTokenRefresh
+	}
+	return &tokenSource{httpClient: httpClient, url: u, creds: creds, refresh: refresh, logger: logger}
+}
+
+// get returns the cached token, logging in when there is none, it is expired or
+// the credentials changed since.
+func (s *tokenSource) get(ctx context.Context) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	creds := s.creds.load()
+	age := time.Since(s.obtained)
+	if len(s.token) > 0 && age < s.refresh && s.obtainedBy == creds {
+		// refresh ahead in the last tenth of the period, the current token still works
+		if age > s.refresh-s.refresh/10 && !s.refreshing {
+			s.refreshing = true
+			go s.refreshAhead(creds)
+		}
+		return s.token, nil
+	}
+
+	token, err := s.login(ctx, creds)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.obtained, s.obtainedBy = token, time.Now(), creds
+	return token, nil
+}
+
+func (s *tokenSource) refreshAhead(creds *userPassword) {
+	token, err := s.login(context.Background(), creds)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.refreshing = false
+	if err != nil {
+		logger := s.logger
+		if logger == nil {
+			logger = defaultLogger()
+		}
+		logger.Warn("token refresh failed", "error", err)
+		return
+	}
+	s.token, s.obtained, s.obtainedBy = token, time.Now(), creds
+}
+
+// invalidate drops the token if it is still the cached one, after it was refused.
+func (s *tokenSource) invalidate(token string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+func (s *tokenSource) login(ctx context.Context, creds *userPassword) (string, error) {
+	u := s.url
+	u.Path = path.Join(u.Path, LoginURL, url.PathEscape(creds.user), url.PathEscape(creds.password))
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("unable to decode json: received status code %d err: %s", resp.StatusCode, err)
+	}
+	if response.Code != 0 {
+		return "", fmt.Errorf("login failed: %s", response.Desc)
+	}
+	if len(response.Desc) == 0 {
+		return "", errors.New("login failed: empty token")
+	}
+	return response.Desc, nil
+}
+
+// do sends the request authorized with the token or the basic credentials,
+// a refused token is dropped so the next request logs in again.
+func (c *client) do(req *http.Request) (*http.Response, error) {
+	creds := c.creds.load()
+	var token string
+	if c.tokens != nil {
+		var err error
+		if token, err = c.tokens.get(req.Context()); err != nil {
+			return nil, redactError(err, creds.password)
+		}
+		req.Header.Set("Authorization", "Taosd "+token)
+	} else if creds.user != "" {
+		req.SetBasicAuth(creds.user, creds.password)
+	}
+
+	httpClient := c.httpClient
+	if _, ok := req.Context().Value(queryTimeoutKey{}).(time.Duration); ok {
+		httpClient = c.untimedClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, redactError(err, creds.password)
+	}
+	if c.tokens != nil && resp.StatusCode == http.StatusUnauthorized {
+		c.tokens.invalidate(token)
+	}
+	return resp, nil
+}
@@ -0,0 +1,146 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,191}$`)
+
+// ValidIdent reports whether s is a legal unquoted TDengine identifier.
+func ValidIdent(s string) bool {
+	return identPattern.MatchString(s)
+}
+
+// TopicSpec describes a topic. According to Mode the topic subscribes Database,
+// STable or the SQL query.
+type TopicSpec struct {
+	Name string
+	Mode TopicMode
+
+	// Content is the database, super table or sql according to Mode, rendered unchecked.
+	//
+	// Deprecated: use Database, STable or SQL.
+	Content string
+
+	// Database is the database of a DBMode topic.
+	Database string
+
+	// STable is the super table of a STMode topic, optionally qualified as "db.stable".
+	STable string
+
+	// Where filters the rows of a STMode topic, it is rendered as is.
+	Where string
+
+	// SQL is the select query of a SQLMode topic.
+	SQL string
+
+	// WithMeta also delivers the meta data changes of DBMode and STMode topics.
+	WithMeta bool
+}
+
+// Validate checks the spec without rendering it.
+func (spec TopicSpec) Validate() error {
+	_, err := spec.Statement()
+	return err
+}
+
+// Statement renders the DDL creating the topic if it does not exist.
+func (spec TopicSpec) Statement() (string, error) {
+	if !ValidIdent(spec.Name) {
+		return "", fmt.Errorf("invalid args: topic name %q", spec.Name)
+	}
+
+	var b strings.Builder
+	b.WriteString("create topic if not exists `" + spec.Name + "`")
+	if spec.WithMeta {
+		if spec.Mode == SQLMode {
+			return "", errors.New("invalid args: `with meta` is not supported by sql topics")
+		}
+		b.WriteString(" with meta")
+	}
+
+	switch spec.Mode {
+	case DBMode:
+		db, err := spec.identContent(spec.Database)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(" as database " + db)
+	case STMode:
+		stable, err := spec.identContent(spec.STable)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(" as stable " + stable)
+		if where := strings.TrimSpace(spec.Where); len(where) > 0 {
+			b.WriteString(" where " + where)
+		}
+	case SQLMode:
+		sql := strings.TrimSpace(spec.SQL)
+		if len(sql) == 0 {
+			sql = spec.Content
+		} else if !strings.HasPrefix(strings.ToLower(sql), "select") {
+			return "", fmt.Errorf("invalid args: topic sql must be a select, got %q", sql)
+		}
+		if len(sql) == 0 {
+			return "", errors.New("miss args: `sql`")
+		}
+		b.WriteString(" as " + sql)
+	default:
+		return "", fmt.Errorf("not support mode: %d", spec.Mode)
+	}
+	return b.String(), nil
+}
+
+// identContent quotes the possibly db qualified identifier, or returns Content if empty.
+func (spec TopicSpec) identContent(ident string) (string, error) {
+	if len(ident) == 0 {
+		if len(spec.Content) == 0 {
+			return "", fmt.Errorf("miss args: content of topic %s", spec.Name)
+		}
+		return spec.Content, nil
+	}
+	return quoteQualified(ident)
+}
+
+// quoteQualified quotes the identifier, optionally qualified by its database as "db.name".
+func quoteQualified(ident string) (string, error) {
+	parts := strings.Split(ident, ".")
+	if len(parts) > 2 {
+		return "", fmt.Errorf("invalid args: identifier %q", ident)
+	}
+	for i, p := range parts {
+		if !ValidIdent(p) {
+			return "", fmt.Errorf("invalid args: identifier %q", ident)
+		}
+		parts[i] = "`" + p + "`"
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// CreateTopicSpec creates the topic described by spec if it does not exist.
+func CreateTopicSpec(ctx context.Context, spec TopicSpec) error {
+	sql, err := spec.Statement()
+	if err != nil {
+		return err
+	}
+	return execContext(ctx, sql)
+}
+
+// UpdateTopic replaces the topic with the one described by spec. TDengine has no
+// ALTER TOPIC, so the topic is dropped and created again, which the server refuses
+// while consumer groups still subscribe it.
+func UpdateTopic(ctx context.Context, spec TopicSpec) error {
+	sql, err := spec.Statement()
+	if err != nil {
+		return err
+	}
+	if err = DropTopicContext(ctx, spec.Name); err != nil {
+		return err
+	}
+	return execContext(ctx, sql)
+}
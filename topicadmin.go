@@ -0,0 +1,225 @@
+package tsdbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TopicInfo is a topic as listed by "show topics".
+type TopicInfo struct {
+	Name     string
+	Database string
+	Created  time.Time
+
+	// SQL is the statement the topic was created with.
+	SQL string
+
+	// Type is the kind of topic, "column", "stable" or "db".
+	Type string
+
+	// WithMeta reports whether the topic delivers meta data changes.
+	WithMeta bool
+}
+
+// ConsumerInfo is a consumer as listed by "show consumers".
+type ConsumerInfo struct {
+	ID       string
+	Group    string
+	ClientID string
+
+	// Status is "ready", "lost" or "rebalancing".
+	Status string
+	Topics []string
+
+	UpTime        time.Time
+	SubscribeTime time.Time
+	RebalanceTime time.Time
+}
+
+// ConsumerGroupInfo is the consumers of a group.
+type ConsumerGroupInfo struct {
+	Name      string
+	Consumers []ConsumerInfo
+}
+
+// SubscriptionOffset is the progress of a consumer group on a vgroup of a topic,
+// as listed by "show subscriptions".
+type SubscriptionOffset struct {
+	Topic      string
+	Group      string
+	VGroupID   int
+	ConsumerID string
+
+	// Offset is the committed WAL version, -1 when nothing is committed yet.
+	Offset int64
+
+	// Rows is the number of rows consumed.
+	Rows int64
+}
+
+func ListTopics() ([]TopicInfo, error) {
+	return ListTopicsContext(context.Background())
+}
+
+// ListTopicsContext is ListTopics with a context controlling cancellation and deadline.
+func ListTopicsContext(ctx context.Context) ([]TopicInfo, error) {
+	rows, err := ReadDataContext(ctx, "show topics")
+	if err != nil {
+		return nil, err
+	}
+
+	topics := make([]TopicInfo, 0, len(rows))
+	for _, row := range rows {
+		topics = append(topics, TopicInfo{
+			Name:     adminString(row["topic_name"]),
+			Database: adminString(row["db_name"]),
+			Created:  adminTime(row["create_time"]),
+			SQL:      adminString(row["sql"]),
+			Type:     adminString(row["type"]),
+			WithMeta: adminString(row["meta"]) == "with_meta",
+		})
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+	return topics, nil
+}
+
+func DescribeTopic(name string) (*TopicInfo, error) {
+	return DescribeTopicContext(context.Background(), name)
+}
+
+// DescribeTopicContext is DescribeTopic with a context controlling cancellation and deadline.
+func DescribeTopicContext(ctx context.Context, name string) (*TopicInfo, error) {
+	if len(name) == 0 {
+		return nil, errors.New("invalid args: `topic` is empty")
+	}
+	topics, err := ListTopicsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range topics {
+		if topics[i].Name == name {
+			return &topics[i], nil
+		}
+	}
+	return nil, fmt.Errorf("topic %s not found", name)
+}
+
+func ListConsumerGroups() ([]ConsumerGroupInfo, error) {
+	return ListConsumerGroupsContext(context.Background())
+}
+
+// ListConsumerGroupsContext is ListConsumerGroups with a context controlling cancellation and deadline.
+func ListConsumerGroupsContext(ctx context.Context) ([]ConsumerGroupInfo, error) {
+	rows, err := ReadDataContext(ctx, "show consumers")
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []ConsumerGroupInfo
+	index := map[string]int{}
+	for _, row := range rows {
+		c := ConsumerInfo{
+			ID:            adminString(row["consumer_id"]),
+			Group:         adminString(row["consumer_group"]),
+			ClientID:      adminString(row["client_id"]),
+			Status:        adminString(row["status"]),
+			UpTime:        adminTime(row["up_time"]),
+			SubscribeTime: adminTime(row["subscribe_time"]),
+			RebalanceTime: adminTime(row["rebalance_time"]),
+		}
+		for _, t := range strings.Split(adminString(row["topics"]), ",") {
+			if t = strings.TrimSpace(t); len(t) > 0 {
+				c.Topics = append(c.Topics, t)
+			}
+		}
+
+		i, ok := index[c.Group]
+		if !ok {
+			i = len(groups)
+			index[c.Group] = i
+			groups = append(groups, ConsumerGroupInfo{Name: c.Group})
+		}
+		groups[i].Consumers = append(groups[i].Consumers, c)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups, nil
+}
+
+// ConsumerLag returns the offsets of the group on each vgroup of the topic. The
+// server reports the committed WAL version, not the end of the WAL, so the lag
+// is observed as the progress of Offset and Rows between calls.
+func ConsumerLag(group, topic string) ([]SubscriptionOffset, error) {
+	return ConsumerLagContext(context.Background(), group, topic)
+}
+
+// ConsumerLagContext is ConsumerLag with a context controlling cancellation and deadline.
+func ConsumerLagContext(ctx context.Context, group, topic string) ([]SubscriptionOffset, error) {
+	if len(group) == 0 || len(topic) == 0 {
+		return nil, errors.New("miss args: `group` or `topic`")
+	}
+	rows, err := ReadDataContext(ctx, "show subscriptions")
+	if err != nil {
+		return nil, err
+	}
+
+	var offsets []SubscriptionOffset
+	for _, row := range rows {
+		if adminString(row["consumer_group"]) != group || adminString(row["topic_name"]) != topic {
+			continue
+		}
+		offsets = append(offsets, SubscriptionOffset{
+			Topic:      topic,
+			Group:      group,
+			VGroupID:   int(adminInt(row["vgroup_id"])),
+			ConsumerID: adminString(row["consumer_id"]),
+			Offset:     parseSubscriptionOffset(adminString(row["offset"])),
+			Rows:       adminInt(row["rows"]),
+		})
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i].VGroupID < offsets[j].VGroupID })
+	return offsets, nil
+}
+
+// subscriptionOffsetPattern matches the WAL version of an offset, formatted as
+// "offset(log) ver:42" by 3.0 servers and "wal:42" by later ones.
+var subscriptionOffsetPattern = regexp.MustCompile(`(?:ver|wal):(-?\d+)`)
+
+// parseSubscriptionOffset returns the WAL version of the offset, -1 for the
+// reset policies and snapshot offsets.
+func parseSubscriptionOffset(s string) int64 {
+	m := subscriptionOffsetPattern.FindStringSubmatch(s)
+	if m == nil {
+		return -1
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func adminString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(v))
+}
+
+func adminInt(v interface{}) int64 {
+	n, _ := strconv.ParseInt(adminString(v), 10, 64)
+	return n
+}
+
+func adminTime(v interface{}) time.Time {
+	if v == nil {
+		return time.Time{}
+	}
+	t, _ := timestampValue(v)
+	return t
+}
@@ -0,0 +1,194 @@
+//go:build integration
+
+// Package tsdbtest runs a TDengine server in docker, through testcontainers-go,
+// for the integration tests of the users of tsdbclient, built with the
+// integration tag:
+//
+//	go test -tags integration ./...
+//
+// A test starts a server, writes through a client and asserts the rows its
+// subscriptions receive:
+//
+//	srv, err := tsdbtest.Start(ctx, tsdbtest.DefaultImage)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer srv.Stop(context.Background())
+//	c, err := srv.Client(ctx, "power")
+//	...
+//	msgs, err := tsdbtest.ExpectSubscribed(ctx, c, spec, 2, func() error {
+//		return c.WriteData(0, "meters", tags, fields)
+//	})
+package tsdbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeagle929/tsdbclient"
+	tmqcommon "github.com/taosdata/driver-go/v3/common/tmq"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+const (
+	// DefaultImage is the TDengine image started by default, with taosAdapter.
+	DefaultImage = "tdengine/tdengine:3.3.2.0"
+
+	adapterPort  = "6041/tcp"
+	readyTimeout = 2 * time.Minute
+)
+
+// Server is a TDengine container.
+type Server struct {
+	// ID is the id of the container.
+	ID string
+
+	// Addr is the http address of taosAdapter.
+	Addr string
+
+	container testcontainers.Container
+}
+
+// Start runs the image, publishing taosAdapter on a random port, and waits for
+// the server to answer queries.
+func Start(ctx context.Context, image string) (*Server, error) {
+	if len(image) == 0 {
+		image = DefaultImage
+	}
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        image,
+			ExposedPorts: []string{adapterPort},
+		},
+		Started: true,
+	})
+	if err != nil {
+		if container != nil {
+			_ = container.Terminate(context.Background())
+		}
+		return nil, err
+	}
+	s := &Server{ID: container.GetContainerID(), container: container}
+
+	endpoint, err := container.PortEndpoint(ctx, adapterPort, "http")
+	if err != nil {
+		_ = s.Stop(context.Background())
+		return nil, err
+	}
+	s.Addr = endpoint
+
+	if err := s.waitReady(ctx); err != nil {
+		_ = s.Stop(context.Background())
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Server) waitReady(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+	c, err := tsdbclient.NewHTTPClient(tsdbclient.HTTPConfig{Addr: s.Addr, Username: "root", Password: "taosdata", Timeout: 5 * time.Second})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for {
+		_, err := c.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("tdengine not ready: %v", err)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Stop removes the container and its volumes.
+func (s *Server) Stop(ctx context.Context) error {
+	return s.container.Terminate(ctx)
+}
+
+// Client creates the database and returns a client of it, with the default
+// credentials of the image.
+func (s *Server) Client(ctx context.Context, database string, opts ...tsdbclient.DBOption) (tsdbclient.TSDBClient, error) {
+	admin := tsdbclient.NewTDEngineClient(tsdbclient.DatabaseAddr(s.Addr),
+		tsdbclient.DatabaseUser("root"), tsdbclient.DatabasePass("taosdata"))
+	defer admin.Close()
+	if err := admin.CreateDatabase(database, tsdbclient.DatabaseOptions{}); err != nil {
+		return nil, err
+	}
+
+	opts = append([]tsdbclient.DBOption{
+		tsdbclient.DatabaseAddr(s.Addr),
+		tsdbclient.DatabaseName(database),
+		tsdbclient.DatabaseUser("root"),
+		tsdbclient.DatabasePass("taosdata"),
+	}, opts...)
+	return tsdbclient.NewTDEngineClient(opts...), nil
+}
+
+// ExpectSubscribed creates the topic, subscribes it from its beginning in a
+// new consumer group, runs write and returns the messages received until they
+// hold rows rows. It fails when ctx is done before.
+func ExpectSubscribed(ctx context.Context, c tsdbclient.TSDBClient, spec tsdbclient.TopicSpec, rows int, write func() error) ([]tsdbclient.TSDBSubscribedMessage, error) {
+	sql, err := spec.Statement()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.QueryDataContext(ctx, sql, false); err != nil {
+		return nil, err
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ch := make(chan tsdbclient.TSDBSubscribedMessage, 64)
+	conf := tsdbclient.NewSubscribeConfig(
+		tsdbclient.ConsumerGroup("tsdbtest_"+strings.ReplaceAll(uuid.NewString(), "-", "")),
+		tsdbclient.ConsumerOffsetReset(tsdbclient.OffsetEarliest),
+	)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.SubscribeWithConfig(subCtx, spec.Name, conf, ch)
+	}()
+
+	if err := write(); err != nil {
+		return nil, err
+	}
+
+	var msgs []tsdbclient.TSDBSubscribedMessage
+	received := 0
+	for received < rows {
+		select {
+		case msg := <-ch:
+			msgs = append(msgs, msg)
+			received += RowCount(msg)
+		case err := <-done:
+			if err == nil {
+				err = errors.New("subscription ended")
+			}
+			return msgs, err
+		case <-ctx.Done():
+			return msgs, fmt.Errorf("received %d rows of %d: %w", received, rows, ctx.Err())
+		}
+	}
+	return msgs, nil
+}
+
+// RowCount returns the number of rows of a subscribed message.
+func RowCount(msg tsdbclient.TSDBSubscribedMessage) int {
+	blocks, _ := msg.Value().([]*tmqcommon.Data)
+	n := 0
+	for _, b := range blocks {
+		if b != nil {
+			n += len(b.Data)
+		}
+	}
+	return n
+}
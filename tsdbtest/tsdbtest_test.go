@@ -0,0 +1,57 @@
+//go:build integration
+
+package tsdbtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jeagle929/tsdbclient"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+func TestExpectSubscribed(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	srv, err := Start(ctx, DefaultImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop(context.Background())
+
+	c, err := srv.Client(ctx, "power")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tags := map[string]string{"location": "california"}
+	now := time.Now().UnixMilli()
+	// the super table must exist before its topic
+	if err := c.WriteData(now, "meters", tags, map[string]interface{}{"current": 10.3}); err != nil {
+		t.Fatal(err)
+	}
+
+	spec := tsdbclient.TopicSpec{Name: "meters_topic", Mode: tsdbclient.SQLMode, SQL: "select * from meters"}
+	msgs, err := ExpectSubscribed(ctx, c, spec, 3, func() error {
+		if err := c.WriteData(now+1, "meters", tags, map[string]interface{}{"current": 10.4}); err != nil {
+			return err
+		}
+		return c.WriteData(now+2, "meters", tags, map[string]interface{}{"current": 10.5})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := 0
+	for _, msg := range msgs {
+		rows += RowCount(msg)
+	}
+	if rows != 3 {
+		t.Errorf("received %d rows, expected 3", rows)
+	}
+}
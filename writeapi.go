@@ -0,0 +1,214 @@
+package tsdbclient
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultWriteBatchSize     = 5000
+	defaultWriteFlushInterval = time.Second
+)
+
+// WriteOptions configures a WriteAPI.
+type WriteOptions struct {
+	// BatchSize is the number of points flushed in one write, defaults to 5000.
+	BatchSize int
+
+	// FlushInterval is the maximum time points stay buffered, defaults to 1s.
+	FlushInterval time.Duration
+
+	// BufferLimit is the number of points queued before WritePoint blocks,
+	// defaults to 2 * BatchSize.
+	BufferLimit int
+
+	// LatencyTarget enables the adaptive mode: the batch size and the flush
+	// interval are tuned to keep the p99 latency of the flushes under the target.
+	// Batches start at MinBatchSize and grow up to BatchSize while the latency
+	// allows it, they are halved when it exceeds the target. The flush interval
+	// follows the batch size, FlushInterval at BatchSize.
+	LatencyTarget time.Duration
+
+	// MinBatchSize is the smallest batch of the adaptive mode, defaults to
+	// BatchSize / 50.
+	MinBatchSize int
+}
+
+// WriteAPI writes points asynchronously, buffering them and flushing on batch size
+// or interval. WriteAPI is safe for concurrent use by multiple goroutines.
+type WriteAPI interface {
+	// WritePoint queues the point, it blocks while the buffer is full.
+	WritePoint(p *DataPoint)
+
+	// Flush writes all queued points and waits until done.
+	Flush()
+
+	// Errors returns the channel receiving the errors of failed flushes. Errors are
+	// dropped when the channel is not read and its buffer is full.
+	Errors() <-chan error
+
+	// Close flushes the queued points and stops the background writer,
+	// the error channel is closed afterwards.
+	Close()
+
+	// Stats returns the operating point of the writer.
+	Stats() BatchingStats
+}
+
+type writeAPI struct {
+	client   *tsdbClient
+	opts     WriteOptions
+	batching *adaptiveBatching
+
+	points  chan *DataPoint
+	flushes chan chan struct{}
+	errors  chan error
+	done    chan struct{}
+
+	lock   sync.RWMutex
+	closed bool
+}
+
+// WriteAPI returns a new asynchronous writer of this client.
+func (client *tsdbClient) WriteAPI(opts WriteOptions) WriteAPI {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultWriteBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultWriteFlushInterval
+	}
+	if opts.BufferLimit <= 0 {
+		opts.BufferLimit = 2 * opts.BatchSize
+	}
+
+	w := &writeAPI{
+		client:   client,
+		opts:     opts,
+		batching: newAdaptiveBatching(opts.BatchSize, opts.FlushInterval, opts.LatencyTarget, opts.MinBatchSize),
+		points:   make(chan *DataPoint, opts.BufferLimit),
+		flushes:  make(chan chan struct{}),
+		errors:   make(chan error, 16),
+		done:     make(chan struct{}),
+	}
+	client.metrics.addWriteAPI(w)
+	go w.run()
+	return w
+}
+
+func (w *writeAPI) WritePoint(p *DataPoint) {
+	if p == nil {
+		return
+	}
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if w.closed {
+		w.client.log().Warn("write api point dropped, writer closed")
+		return
+	}
+	w.points <- p
+}
+
+func (w *writeAPI) Flush() {
+	w.lock.RLock()
+	if w.closed {
+		w.lock.RUnlock()
+		return
+	}
+	ack := make(chan struct{})
+	w.flushes <- ack
+	w.lock.RUnlock()
+	<-ack
+}
+
+func (w *writeAPI) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *writeAPI) Stats() BatchingStats {
+	return w.batching.stats()
+}
+
+func (w *writeAPI) Close() {
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		<-w.done
+		return
+	}
+	w.closed = true
+	close(w.points)
+	w.lock.Unlock()
+	<-w.done
+}
+
+func (w *writeAPI) run() {
+	defer close(w.done)
+	defer close(w.errors)
+	defer w.client.metrics.removeWriteAPI(w)
+
+	size, interval := w.batching.operatingPoint()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	batch := make([]*DataPoint, 0, size)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		bps := w.client.newBatch()
+		bps.AddPoints(batch)
+		start := time.Now()
+		err := w.client.write(bps)
+		if err != nil {
+			select {
+			case w.errors <- err:
+			default:
+				w.client.log().Warn("write api error dropped", "error", err)
+			}
+		}
+		if s, i := w.batching.observe(time.Since(start)); s != size || i != interval {
+			size, interval = s, i
+			ticker.Reset(interval)
+		}
+		batch = make([]*DataPoint, 0, size)
+	}
+
+	for {
+		select {
+		case p, ok := <-w.points:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, p)
+			if len(batch) >= size {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case ack := <-w.flushes:
+		drain:
+			for {
+				select {
+				case p, ok := <-w.points:
+					if !ok {
+						break drain
+					}
+					batch = append(batch, p)
+					if len(batch) >= size {
+						flush()
+					}
+				default:
+					break drain
+				}
+			}
+			flush()
+			close(ack)
+		}
+	}
+}
+
+// NewWriteAPI returns a new asynchronous writer of the default client.
+func NewWriteAPI(opts WriteOptions) WriteAPI {
+	return clientWrapper.WriteAPI(opts)
+}
@@ -0,0 +1,129 @@
+package tsdbclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	taoserrors "github.com/taosdata/driver-go/v3/errors"
+)
+
+// WriteResult reports the points of a batch written and those refused by the
+// server, so the refused points can be dead-lettered alone.
+type WriteResult struct {
+	// Accepted is the number of points written.
+	Accepted int
+
+	// Failed is the number of points refused, described in Errors.
+	Failed int
+
+	// Errors describes the points refused, in the order of the batch.
+	Errors []PointError
+}
+
+// PointError is a point refused by the server.
+type PointError struct {
+	// Index is the index of the point in the batch.
+	Index int
+
+	// Line is the point in line protocol.
+	Line string
+
+	// Code is the error code of the server, 0 if it did not report one.
+	Code int
+
+	// Message is the error message of the server.
+	Message string
+}
+
+func (e PointError) Error() string {
+	return fmt.Sprintf("point %d: %s", e.Index, e.Message)
+}
+
+// adapterError is the body of an error response of taosAdapter.
+type adapterError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Desc    string `json:"desc"`
+}
+
+// WriteWithResult writes the batch and reports the points refused. A batch
+// refused by the server is split in halves written again, down to the points
+// refused, the points written twice replace themselves. The error is not nil
+// only when the write could not complete, e.g. the server was unreachable, the
+// result then counts the points written before it.
+func (c *client) WriteWithResult(ctx context.Context, bp BatchPoints) (*WriteResult, error) {
+	return writeWithResult(ctx, c.WriteContext, bp)
+}
+
+func (c *wsClient) WriteWithResult(ctx context.Context, bp BatchPoints) (*WriteResult, error) {
+	return writeWithResult(ctx, c.WriteContext, bp)
+}
+
+func writeWithResult(ctx context.Context, write func(context.Context, BatchPoints) error, bp BatchPoints) (*WriteResult, error) {
+	result := &WriteResult{}
+	err := writeSplitting(ctx, write, bp, bp.Points(), 0, result)
+	return result, err
+}
+
+// writeSplitting writes the points of bp from offset, halving them while they are refused.
+func writeSplitting(ctx context.Context, write func(context.Context, BatchPoints) error, bp BatchPoints, points []*DataPoint, offset int, result *WriteResult) error {
+	if len(points) == 0 {
+		return nil
+	}
+	part, _ := NewBatchPoints(configOf(bp))
+	part.AddPoints(points)
+
+	err := write(ctx, part)
+	if err == nil {
+		result.Accepted += len(points)
+		return nil
+	}
+	if !isRefused(ctx, err) {
+		return err
+	}
+	if len(points) > 1 {
+		half := len(points) / 2
+		if err := writeSplitting(ctx, write, bp, points[:half], offset, result); err != nil {
+			return err
+		}
+		return writeSplitting(ctx, write, bp, points[half:], offset+half, result)
+	}
+
+	pe := PointError{Index: offset, Line: points[0].pt.PrecisionString(bp.Precision()), Message: err.Error()}
+	var statusErr *httpStatusError
+	var taosErr *taoserrors.TaosError
+	var body adapterError
+	switch {
+	case errors.As(err, &taosErr):
+		pe.Code, pe.Message = int(taosErr.Code), taosErr.ErrStr
+	case errors.As(err, &statusErr) && json.Unmarshal([]byte(statusErr.msg), &body) == nil && body.Code != 0:
+		pe.Code, pe.Message = body.Code, redact(body.Message+body.Desc)
+	}
+	result.Failed++
+	result.Errors = append(result.Errors, pe)
+	return nil
+}
+
+// isRefused reports whether the server refused the points written, as opposed
+// to the write not reaching it or not being allowed.
+func isRefused(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || isUnreachable(err) {
+		return false
+	}
+	var taosErr *taoserrors.TaosError
+	if errors.As(err, &taosErr) {
+		return true
+	}
+	var statusErr *httpStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	switch statusErr.code {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return statusErr.code >= http.StatusBadRequest
+}
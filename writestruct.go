@@ -0,0 +1,195 @@
+package tsdbclient
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Measurer names the measurement of the structs written by WriteStruct, the
+// lowercased type name is used otherwise.
+type Measurer interface {
+	Measurement() string
+}
+
+// structLayout is the mapping of a struct type to a point.
+type structLayout struct {
+	measurement string
+	tags        []structColumn
+	fields      []structColumn
+	ts          []int
+
+	// columns are the ts, tags and fields in the order of the struct, table
+	// receives the name of the table of a decoded row.
+	columns []structColumn
+	table   []int
+}
+
+type structColumn struct {
+	name  string
+	index []int
+}
+
+var structLayouts sync.Map // reflect.Type -> *structLayout
+
+// layoutOf parses the tsdb tags of the struct type, "tag", "field", "ts" or
+// "table" optionally preceded by a name: `tsdb:"location,tag"`. The fields
+// without tag are ignored, the names default to the lowercased field names.
+func layoutOf(t reflect.Type) (*structLayout, error) {
+	if l, ok := structLayouts.Load(t); ok {
+		return l.(*structLayout), nil
+	}
+
+	l := &structLayout{measurement: strings.ToLower(t.Name())}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		spec, ok := f.Tag.Lookup("tsdb")
+		if !ok || spec == "-" || !f.IsExported() {
+			continue
+		}
+		name, kind := strings.ToLower(f.Name), spec
+		if n, k, found := strings.Cut(spec, ","); found {
+			name, kind = n, k
+		}
+		col := structColumn{name: name, index: f.Index}
+		switch kind {
+		case "tag":
+			l.tags = append(l.tags, col)
+		case "field":
+			l.fields = append(l.fields, col)
+		case "table":
+			if f.Type.Kind() != reflect.String {
+				return nil, fmt.Errorf("invalid args: table field %s of struct %s is not a string", f.Name, t)
+			}
+			l.table = f.Index
+			continue
+		case "ts":
+			if l.ts != nil {
+				return nil, fmt.Errorf("invalid args: struct %s has several ts fields", t)
+			}
+			if ft := f.Type; ft != reflect.TypeOf(time.Time{}) && ft != reflect.TypeOf(&time.Time{}) {
+				return nil, fmt.Errorf("invalid args: ts field %s of struct %s is not a time.Time", f.Name, t)
+			}
+			l.ts = f.Index
+		default:
+			return nil, fmt.Errorf("invalid args: field %s of struct %s has unknown tsdb tag %q", f.Name, t, spec)
+		}
+		l.columns = append(l.columns, col)
+	}
+	if len(l.fields) == 0 {
+		return nil, fmt.Errorf("invalid args: struct %s has no tsdb field", t)
+	}
+	structLayouts.Store(t, l)
+	return l, nil
+}
+
+// WriteStruct writes a struct, a pointer to one, or a slice of them as points,
+// mapped by their tsdb tags:
+//
+//	type Meter struct {
+//		Location string    `tsdb:"tag"`
+//		Current  float64   `tsdb:"field"`
+//		Time     time.Time `tsdb:"ts"`
+//	}
+//
+// The nil pointer fields are not written, a zero ts leaves the timestamp to the
+// server. The tags are formatted with fmt.Sprint.
+func (client *tsdbClient) WriteStruct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return errors.New("invalid args: `v` is nil")
+	}
+	bps := client.newBatch()
+
+	if k := rv.Kind(); k == reflect.Slice || k == reflect.Array {
+		for i := 0; i < rv.Len(); i++ {
+			if err := client.addStruct(bps, rv.Index(i)); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+	} else if err := client.addStruct(bps, rv); err != nil {
+		return err
+	}
+	if len(bps.Points()) == 0 {
+		return nil
+	}
+	return client.write(bps)
+}
+
+// WriteStruct writes the structs with the default client.
+func WriteStruct(v interface{}) error {
+	return clientWrapper.WriteStruct(v)
+}
+
+func (client *tsdbClient) addStruct(bps BatchPoints, rv reflect.Value) error {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return errors.New("invalid args: nil struct")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("invalid args: %s is not a struct", rv.Type())
+	}
+	l, err := layoutOf(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	measurement := l.measurement
+	if m, ok := rv.Interface().(Measurer); ok {
+		measurement = m.Measurement()
+	} else if rv.CanAddr() {
+		if m, ok := rv.Addr().Interface().(Measurer); ok {
+			measurement = m.Measurement()
+		}
+	}
+
+	tags := make(map[string]string, len(l.tags))
+	for _, c := range l.tags {
+		if f, ok := structValue(rv, c.index); ok {
+			tags[c.name] = fmt.Sprint(f)
+		}
+	}
+	fields := make(map[string]interface{}, len(l.fields))
+	for _, c := range l.fields {
+		if f, ok := structValue(rv, c.index); ok {
+			fields[c.name] = f
+		}
+	}
+	var t time.Time
+	if l.ts != nil {
+		if f, ok := structValue(rv, l.ts); ok {
+			t = f.(time.Time)
+		}
+	}
+	return client.addPoints(bps, measurement, tags, fields, t)
+}
+
+// structValue returns the value of the struct field, the named basic types
+// converted to their kind, false for a nil pointer.
+func structValue(rv reflect.Value, index []int) (interface{}, bool) {
+	f := rv.FieldByIndex(index)
+	if f.Kind() == reflect.Pointer {
+		if f.IsNil() {
+			return nil, false
+		}
+		f = f.Elem()
+	}
+	switch f.Kind() {
+	case reflect.Bool:
+		return f.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return f.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return f.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		return f.Float(), true
+	case reflect.String:
+		return f.String(), true
+	}
+	return f.Interface(), true
+}
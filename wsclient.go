@@ -0,0 +1,399 @@
+package tsdbclient
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taosdata/driver-go/v3/common"
+	taoserrors "github.com/taosdata/driver-go/v3/errors"
+	"github.com/taosdata/driver-go/v3/taosWS"
+	"github.com/taosdata/driver-go/v3/ws/schemaless"
+)
+
+// ClientTransport selects how the client talks to the server.
+type ClientTransport int8
+
+const (
+	_ ClientTransport = iota
+	// RESTTransport sends queries and writes as http requests to taosAdapter.
+	RESTTransport
+	// WebsocketTransport keeps websocket connections to taosAdapter through driver-go.
+	WebsocketTransport
+)
+
+// wsClient is a Client over the websocket interface of taosAdapter. Queries go
+// through the taosWS sql driver, writes through the schemaless endpoint, one
+// connection per database. The connections are opened again after the
+// credentials changed.
+type wsClient struct {
+	conf      *taosWS.Config
+	wsURL     string
+	creds     *credentials
+	timeout   time.Duration
+	pingQuery string
+
+	limitPolicy LimitPolicy
+
+	lock     sync.Mutex
+	dbs      map[string]wsConn[*sql.DB]
+	writers  map[string]wsConn[*schemaless.Schemaless]
+	isClosed bool
+}
+
+// wsConn is a connection and the credentials it was opened with. users counts
+// the writes using a schemaless connection, it is closed once they are done
+// after it was replaced.
+type wsConn[T any] struct {
+	conn  T
+	creds *userPassword
+	users *sync.WaitGroup
+}
+
+// NewWebsocketClient returns a Client connecting to taosAdapter over websocket,
+// conf.Addr may be of the http:// or ws:// form. Only Addr, Username, Password,
+// Timeout, PingQuery, LimitPolicy and Logger of conf are supported: driver-go
+// opens the connections itself, without the TLS, dial, transport and write
+// settings of the REST client, setting them is an error.
+func NewWebsocketClient(conf HTTPConfig) (Client, error) {
+	if unsupported := websocketUnsupported(conf); len(unsupported) > 0 {
+		return nil, fmt.Errorf("invalid args: %s not supported by the websocket transport", strings.Join(unsupported, ", "))
+	}
+	u, err := url.Parse(toWebsocketAddr(conf.Addr))
+	if err != nil {
+		return nil, fmt.Errorf("invalid args: `addr` %v", err)
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return nil, fmt.Errorf("unsupported protocol scheme: %s, your address must start with http(s):// or ws(s)://", u.Scheme)
+	}
+
+	wsConf := taosWS.NewConfig()
+	wsConf.Net = u.Scheme
+	wsConf.Addr = u.Hostname()
+	if port := u.Port(); len(port) > 0 {
+		if wsConf.Port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("invalid args: `addr` port %s", port)
+		}
+	}
+	wsConf.ReadTimeout = conf.Timeout
+	wsConf.WriteTimeout = conf.Timeout
+
+	creds := conf.credentials
+	if creds == nil {
+		creds = newCredentials(conf.Username, conf.Password)
+	}
+	return &wsClient{
+		conf:      wsConf,
+		wsURL:     u.Scheme + "://" + u.Host,
+		creds:     creds,
+		timeout:   conf.Timeout,
+		pingQuery: conf.PingQuery,
+		dbs:       make(map[string]wsConn[*sql.DB]),
+		writers:   make(map[string]wsConn[*schemaless.Schemaless]),
+
+		limitPolicy: conf.LimitPolicy,
+	}, nil
+}
+
+// websocketUnsupported returns the names of the fields of conf set but not
+// supported by the websocket transport.
+func websocketUnsupported(conf HTTPConfig) []string {
+	fields := []struct {
+		name string
+		set  bool
+	}{
+		{"ReadAddr", len(conf.ReadAddr) > 0},
+		{"WriteAddr", len(conf.WriteAddr) > 0},
+		{"ReplicaAddrs", len(conf.ReplicaAddrs) > 0},
+		{"UserAgent", len(conf.UserAgent) > 0},
+		{"DialControl", conf.DialControl != nil},
+		{"LogDials", conf.LogDials},
+		{"Middlewares", len(conf.Middlewares) > 0},
+		{"InsecureSkipVerify", conf.InsecureSkipVerify},
+		{"TLSConfig", conf.TLSConfig != nil},
+		{"StrictTLS", conf.StrictTLS},
+		{"SPKIPins", len(conf.SPKIPins) > 0},
+		{"Proxy", conf.Proxy != nil},
+		{"WriteEncoding", len(conf.WriteEncoding) > 0},
+		{"GzipLevel", conf.GzipLevel != 0},
+		{"WriteProtocol", len(conf.WriteProtocol) > 0},
+		{"WriteRetry", conf.WriteRetry.MaxAttempts > 1},
+		{"WriteChecksum", conf.WriteChecksum},
+		{"VerifyContentLength", conf.VerifyContentLength},
+		{"TokenAuth", conf.TokenAuth},
+		{"MaxPointsPerRequest", conf.MaxPointsPerRequest > 0},
+		{"MaxBodySize", conf.MaxBodySize > 0},
+		{"WriteConcurrency", conf.WriteConcurrency > 0},
+		{"MaxIdleConns", conf.MaxIdleConns > 0},
+		{"MaxIdleConnsPerHost", conf.MaxIdleConnsPerHost > 0},
+		{"MaxConnsPerHost", conf.MaxConnsPerHost > 0},
+		{"IdleConnTimeout", conf.IdleConnTimeout > 0},
+		{"KeepAlive", conf.KeepAlive != 0},
+		{"DisableKeepAlives", conf.DisableKeepAlives},
+		{"HTTPClient", conf.HTTPClient != nil},
+		{"RoundTripper", conf.RoundTripper != nil},
+	}
+	var names []string
+	for _, f := range fields {
+		if f.set {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// db returns the connection pool of database, opening it on first use.
+func (c *wsClient) db(database string) (*sql.DB, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.isClosed {
+		return nil, errors.New("client is closed")
+	}
+	creds := c.creds.load()
+	if db, ok := c.dbs[database]; ok {
+		if db.creds == creds {
+			return db.conn, nil
+		}
+		// the queries running with the old password complete before it closes
+		go db.conn.Close()
+	}
+
+	conf := *c.conf
+	conf.DbName = database
+	conf.User, conf.Passwd = creds.user, creds.password
+	connector, err := taosWS.NewConnector(&conf)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+	c.dbs[database] = wsConn[*sql.DB]{conn: db, creds: creds}
+	return db, nil
+}
+
+// writer returns the schemaless connection of database, opening it on first use,
+// and the func to call when done with it.
+func (c *wsClient) writer(database string) (*schemaless.Schemaless, func(), error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.isClosed {
+		return nil, nil, errors.New("client is closed")
+	}
+	creds := c.creds.load()
+	if w, ok := c.writers[database]; ok {
+		if w.creds == creds {
+			w.users.Add(1)
+			return w.conn, w.users.Done, nil
+		}
+		// the writes running with the old password complete before it closes
+		go func() {
+			w.users.Wait()
+			w.conn.Close()
+		}()
+	}
+
+	w, err := schemaless.NewSchemaless(schemaless.NewConfig(c.wsURL, 0,
+		schemaless.SetUser(creds.user),
+		schemaless.SetPassword(creds.password),
+		schemaless.SetDb(database),
+		schemaless.SetReadTimeout(c.timeout),
+		schemaless.SetWriteTimeout(c.timeout),
+		schemaless.SetAutoReconnect(true),
+	))
+	if err != nil {
+		return nil, nil, err
+	}
+	users := new(sync.WaitGroup)
+	users.Add(1)
+	c.writers[database] = wsConn[*schemaless.Schemaless]{conn: w, creds: creds, users: users}
+	return w, users.Done, nil
+}
+
+func (c *wsClient) Ping() (time.Duration, string, error) {
+	res, err := c.PingContext(context.Background())
+	if err != nil {
+		return 0, "", err
+	}
+	return res.RTT, res.Version, nil
+}
+
+func (c *wsClient) PingContext(ctx context.Context) (*PingResult, error) {
+	return ping(ctx, c, c.pingQuery, c.timeout > 0)
+}
+
+func (c *wsClient) Health(ctx context.Context, database string, probes int) *HealthReport {
+	return health(ctx, c, database, probes)
+}
+
+func (c *wsClient) Write(bp BatchPoints) error {
+	return c.WriteContext(context.Background(), bp)
+}
+
+// WriteContext writes the batch as line protocol through the schemaless endpoint.
+func (c *wsClient) WriteContext(ctx context.Context, bp BatchPoints) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := encodeLineProtocol(&b, bp); err != nil {
+		return err
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+
+	w, release, err := c.writer(bp.Database())
+	if err != nil {
+		return err
+	}
+	defer release()
+	precision := wirePrecision(bp.Precision())
+	if len(precision) == 0 {
+		precision = "ns"
+	}
+	reqID := requestID(ctx)
+	return withReqID(w.Insert(b.String(), schemaless.InfluxDBLineProtocol, precision, bp.TTL(), reqID), reqID)
+}
+
+func (c *wsClient) Query(q Query) (*Response, error) {
+	return c.QueryContext(context.Background(), q)
+}
+
+// QueryContext runs the query and returns its result in the form of the REST
+// interface: numbers as json.Number, timestamps as RFC3339 strings. Server
+// errors are reported in the Code and Desc of the response.
+func (c *wsClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	reqID := requestID(ctx)
+	resp, err := c.query(WithReqID(ctx, reqID), q)
+	if resp != nil {
+		resp.ReqID = reqID
+	}
+	return resp, withReqID(err, reqID)
+}
+
+func (c *wsClient) query(ctx context.Context, q Query) (*Response, error) {
+	ctx, q, cancel := prepareQuery(ctx, q, c.limitPolicy)
+	defer cancel()
+	db, err := c.db(q.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	// taosWS reads the req_id from its string key
+	ctx = context.WithValue(ctx, common.ReqIDKey, ReqIDFrom(ctx))
+	rows, err := db.QueryContext(ctx, labelQuery(ctx, q.Command))
+	if err != nil {
+		return taosErrorResponse(err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	resp := &Response{ColumnMeta: make([][]interface{}, len(types)), Columns: make([]ColumnMeta, len(types))}
+	for i, t := range types {
+		length, _ := t.Length()
+		resp.ColumnMeta[i] = []interface{}{t.Name(), t.DatabaseTypeName(), json.Number(strconv.FormatInt(length, 10))}
+		resp.Columns[i] = ColumnMeta{Name: t.Name(), Type: ColumnType(t.DatabaseTypeName()), Size: int(length)}
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(types))
+		dest := make([]interface{}, len(types))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			values[i] = restValue(v)
+		}
+		resp.Data = append(resp.Data, values)
+	}
+	if err := rows.Err(); err != nil {
+		return taosErrorResponse(err)
+	}
+	resp.Rows = len(resp.Data)
+	truncateRows(resp, q.MaxRows)
+	return resp, nil
+}
+
+// taosErrorResponse reports a server error as a response, like the REST interface.
+func taosErrorResponse(err error) (*Response, error) {
+	var taosErr *taoserrors.TaosError
+	if errors.As(err, &taosErr) {
+		return &Response{Code: int(taosErr.Code), Desc: taosErr.ErrStr}, nil
+	}
+	return nil, err
+}
+
+// restValue converts a value of the sql driver to its decoding from the REST interface.
+func restValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int8:
+		return json.Number(strconv.FormatInt(int64(v), 10))
+	case int16:
+		return json.Number(strconv.FormatInt(int64(v), 10))
+	case int32:
+		return json.Number(strconv.FormatInt(int64(v), 10))
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10))
+	case uint8:
+		return json.Number(strconv.FormatUint(uint64(v), 10))
+	case uint16:
+		return json.Number(strconv.FormatUint(uint64(v), 10))
+	case uint32:
+		return json.Number(strconv.FormatUint(uint64(v), 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10))
+	case float32:
+		return json.Number(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return string(v)
+	}
+	return v
+}
+
+// QueryStream runs the query and iterates over its rows, they are read in full first.
+func (c *wsClient) QueryStream(ctx context.Context, q Query) (*QueryIterator, error) {
+	resp, err := c.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Error(); err != nil {
+		return nil, err
+	}
+	return newBufferedIterator(resp), nil
+}
+
+// Close closes the connections of all databases.
+func (c *wsClient) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.isClosed {
+		return nil
+	}
+	c.isClosed = true
+
+	var errs []error
+	for _, db := range c.dbs {
+		errs = append(errs, db.conn.Close())
+	}
+	for _, w := range c.writers {
+		w.conn.Close()
+	}
+	return errors.Join(errs...)
+}